@@ -1,19 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	// The reporting time zone must load on hosts without system tzdata.
+	_ "time/tzdata"
 
 	"github.com/nurpe/snowops-acts/internal/auth"
 	"github.com/nurpe/snowops-acts/internal/config"
 	"github.com/nurpe/snowops-acts/internal/db"
 	"github.com/nurpe/snowops-acts/internal/excel"
+	"github.com/nurpe/snowops-acts/internal/html"
 	httphandler "github.com/nurpe/snowops-acts/internal/http"
 	"github.com/nurpe/snowops-acts/internal/http/middleware"
 	"github.com/nurpe/snowops-acts/internal/logger"
+	"github.com/nurpe/snowops-acts/internal/mail"
 	"github.com/nurpe/snowops-acts/internal/pdf"
 	"github.com/nurpe/snowops-acts/internal/repository"
 	"github.com/nurpe/snowops-acts/internal/service"
+	"github.com/nurpe/snowops-acts/internal/storage"
 )
 
 func main() {
@@ -30,14 +36,94 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to connect database")
 	}
 
-	reportRepo := repository.NewReportRepository(database)
+	reportRepo := repository.NewReportRepository(database, cfg.Acts.ValidStatuses)
+	actRepo := repository.NewActRepository(database)
+	cameraRepo := repository.NewLandfillCameraRepository(database)
+	tariffRepo := repository.NewTariffRepository(database)
+	jobRepo := repository.NewExportJobRepository(database)
+	scheduledRepo := repository.NewScheduledActRepository(database)
+	deliveryRepo := repository.NewDeliveryRepository(database)
+	webhookRepo := repository.NewWebhookRepository(database)
+	auditRepo := repository.NewAuditRepository(database)
 	excelGenerator := excel.NewGenerator()
 	pdfGenerator := pdf.NewGenerator()
 
-	actService := service.NewActService(reportRepo, excelGenerator, pdfGenerator, cfg)
+	renderers := service.NewRendererRegistry()
+	renderers.Register(service.RenderFormat{
+		Name:      service.FormatXLSX,
+		MIMEType:  service.MIMETypeXLSX,
+		Extension: "xlsx",
+		Renderer:  excelGenerator,
+	})
+	renderers.Register(service.RenderFormat{
+		Name:      service.FormatPDF,
+		MIMEType:  service.MIMETypePDF,
+		Extension: "pdf",
+		Renderer:  pdfGenerator,
+	})
+	renderers.Register(service.RenderFormat{
+		Name:       service.FormatCompletedWorksPDF,
+		MIMEType:   service.MIMETypePDF,
+		Extension:  "pdf",
+		FilePrefix: "completed-works",
+		Renderer:   service.RendererFunc(pdfGenerator.GenerateCompletedWorks),
+	})
+	renderers.Register(service.RenderFormat{
+		Name:      service.FormatHTML,
+		MIMEType:  service.MIMETypeHTML,
+		Extension: "html",
+		Inline:    true,
+		Renderer:  html.NewGenerator(),
+	})
+
+	actService := service.NewActService(reportRepo, actRepo, renderers, excelGenerator, pdfGenerator, cfg)
+
+	cameraService := service.NewLandfillCameraService(cameraRepo, reportRepo)
+	tariffService := service.NewTariffService(tariffRepo, reportRepo)
+
+	driftChecker := service.NewDriftChecker(actService, scheduledRepo, cfg.Acts.DriftCheckInterval, cfg.Acts.DriftCheckMonths, log)
+	go driftChecker.Run(context.Background())
+
+	var store service.FileStore = storage.NewDisk(cfg.Storage.Dir)
+	if cfg.Storage.Kind == "s3" {
+		store, err = storage.NewS3(
+			cfg.Storage.S3Endpoint,
+			cfg.Storage.S3Bucket,
+			cfg.Storage.S3Region,
+			cfg.Storage.S3AccessKey,
+			cfg.Storage.S3SecretKey,
+		)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to configure act storage")
+		}
+	}
+
+	jobService := service.NewExportJobService(jobRepo, actService, store, cfg.Jobs.Workers, cfg.Jobs.PollInterval, log)
+	go jobService.Run(context.Background())
+
+	var mailer service.Mailer
+	if cfg.Mail.SMTPHost != "" {
+		mailer = mail.NewSMTP(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword)
+	}
+	deliveryService, err := service.NewDeliveryService(deliveryRepo, actService, mailer, cfg, log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to configure act emails")
+	}
+	go deliveryService.Run(context.Background())
+
+	webhookService := service.NewWebhookService(webhookRepo, actService, cfg, log)
+	go webhookService.Run(context.Background())
+
+	scheduler, err := service.NewActScheduler(actService, scheduledRepo, store, deliveryService, cfg, log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to configure act schedules")
+	}
+	go scheduler.Run(context.Background())
+
+	auditService := service.NewAuditService(auditRepo, actService, cfg, log)
 
 	tokenParser := auth.NewParser(cfg.Auth.AccessSecret)
-	handler := httphandler.NewHandler(actService, log)
+	handler := httphandler.NewHandler(actService, cameraService, tariffService, jobService, deliveryService, webhookService, auditService, log)
 	authMiddleware := middleware.Auth(tokenParser)
 	router := httphandler.NewRouter(handler, authMiddleware, cfg.Environment)
 
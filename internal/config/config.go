@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
 )
 
@@ -22,11 +25,93 @@ type AuthConfig struct {
 	AccessSecret string
 }
 
+type ActsConfig struct {
+	NumberPrefix    string
+	ValidStatuses   []string
+	VATRate         float64
+	WorkDescription string
+	// DriftCheckInterval is how often issued acts are compared with current
+	// ANPR data; zero disables the background check.
+	DriftCheckInterval time.Duration
+	// DriftCheckMonths is how many months back, counted from the start of
+	// the current month, the background check looks for acts by period end.
+	DriftCheckMonths int
+	// Location is the reporting time zone: period dates are calendar days in
+	// it and event times are shown in it.
+	Location *time.Location
+	// BatchWorkers is the number of acts of a batch export generated at
+	// the same time.
+	BatchWorkers int
+}
+
+// JobsConfig configures background export jobs.
+type JobsConfig struct {
+	// Workers is the number of jobs run at the same time by one instance.
+	Workers      int
+	PollInterval time.Duration
+}
+
+// ScheduleConfig configures the scheduled generation of monthly acts.
+type ScheduleConfig struct {
+	// Crons lists the cron expressions the acts of the previous month are
+	// issued on; none disables the scheduler.
+	Crons   []string
+	Formats []string
+	// OrgID is the organization scheduled acts are issued by.
+	OrgID uuid.UUID
+}
+
+// StorageConfig selects where the documents of scheduled acts and export
+// jobs are kept.
+type StorageConfig struct {
+	// Kind is "disk" or "s3".
+	Kind        string
+	Dir         string
+	S3Endpoint  string
+	S3Bucket    string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+}
+
+// MailConfig configures email delivery of acts. Delivery is disabled
+// without an SMTP host.
+type MailConfig struct {
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+	// Formats names the document formats attached to the emails.
+	Formats []string
+	// MaxAttempts limits how often a failing email is retried.
+	MaxAttempts  int
+	PollInterval time.Duration
+}
+
+// WebhookConfig configures the delivery of webhook events.
+type WebhookConfig struct {
+	// PublicURL is the base URL of the service the download links in events
+	// point to; links are relative without it.
+	PublicURL string
+	// MaxAttempts limits how often a failing event is retried.
+	MaxAttempts  int
+	PollInterval time.Duration
+	// Timeout limits one request to a webhook endpoint.
+	Timeout time.Duration
+}
+
 type Config struct {
 	Environment string
 	HTTP        HTTPConfig
 	DB          DBConfig
 	Auth        AuthConfig
+	Acts        ActsConfig
+	Jobs        JobsConfig
+	Schedule    ScheduleConfig
+	Storage     StorageConfig
+	Mail        MailConfig
+	Webhooks    WebhookConfig
 }
 
 func Load() (*Config, error) {
@@ -56,6 +141,43 @@ func Load() (*Config, error) {
 		Auth: AuthConfig{
 			AccessSecret: v.GetString("JWT_ACCESS_SECRET"),
 		},
+		Acts: ActsConfig{
+			NumberPrefix:     v.GetString("ACTS_NUMBER_PREFIX"),
+			ValidStatuses:    splitList(v.GetString("ACTS_VALID_STATUSES")),
+			VATRate:          v.GetFloat64("ACTS_VAT_RATE"),
+			WorkDescription:  v.GetString("ACTS_WORK_DESCRIPTION"),
+			BatchWorkers:     v.GetInt("ACTS_BATCH_WORKERS"),
+			DriftCheckMonths: v.GetInt("ACTS_DRIFT_CHECK_MONTHS"),
+		},
+		Jobs: JobsConfig{
+			Workers: v.GetInt("ACTS_JOB_WORKERS"),
+		},
+		Schedule: ScheduleConfig{
+			Crons:   splitSchedules(v.GetString("ACTS_SCHEDULES")),
+			Formats: splitList(v.GetString("ACTS_SCHEDULE_FORMATS")),
+		},
+		Storage: StorageConfig{
+			Kind:        strings.ToLower(strings.TrimSpace(v.GetString("ACTS_STORAGE"))),
+			Dir:         v.GetString("ACTS_STORAGE_DIR"),
+			S3Endpoint:  v.GetString("ACTS_S3_ENDPOINT"),
+			S3Bucket:    v.GetString("ACTS_S3_BUCKET"),
+			S3Region:    v.GetString("ACTS_S3_REGION"),
+			S3AccessKey: v.GetString("ACTS_S3_ACCESS_KEY"),
+			S3SecretKey: v.GetString("ACTS_S3_SECRET_KEY"),
+		},
+		Mail: MailConfig{
+			SMTPHost:     v.GetString("ACTS_SMTP_HOST"),
+			SMTPPort:     v.GetInt("ACTS_SMTP_PORT"),
+			SMTPUsername: v.GetString("ACTS_SMTP_USERNAME"),
+			SMTPPassword: v.GetString("ACTS_SMTP_PASSWORD"),
+			From:         v.GetString("ACTS_MAIL_FROM"),
+			Formats:      splitList(v.GetString("ACTS_MAIL_FORMATS")),
+			MaxAttempts:  v.GetInt("ACTS_MAIL_MAX_ATTEMPTS"),
+		},
+		Webhooks: WebhookConfig{
+			PublicURL:   strings.TrimRight(strings.TrimSpace(v.GetString("ACTS_PUBLIC_URL")), "/"),
+			MaxAttempts: v.GetInt("ACTS_WEBHOOK_MAX_ATTEMPTS"),
+		},
 	}
 
 	if cfg.Environment == "" {
@@ -67,6 +189,115 @@ func Load() (*Config, error) {
 	if cfg.HTTP.Port == 0 {
 		cfg.HTTP.Port = 7089
 	}
+	if cfg.Acts.NumberPrefix == "" {
+		cfg.Acts.NumberPrefix = "AKT"
+	}
+	if len(cfg.Acts.ValidStatuses) == 0 {
+		cfg.Acts.ValidStatuses = []string{"OK"}
+	}
+	if cfg.Acts.WorkDescription == "" {
+		cfg.Acts.WorkDescription = "Содержание мест складирования вывезенного снега"
+	}
+	if !v.IsSet("ACTS_VAT_RATE") {
+		cfg.Acts.VATRate = 12
+	}
+	driftInterval := v.GetString("ACTS_DRIFT_CHECK_INTERVAL")
+	if driftInterval == "" {
+		driftInterval = "24h"
+	}
+	interval, err := time.ParseDuration(driftInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACTS_DRIFT_CHECK_INTERVAL: %w", err)
+	}
+	cfg.Acts.DriftCheckInterval = interval
+
+	timeZone := v.GetString("ACTS_TIMEZONE")
+	if timeZone == "" {
+		timeZone = "Asia/Almaty"
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACTS_TIMEZONE: %w", err)
+	}
+	cfg.Acts.Location = location
+
+	if cfg.Acts.DriftCheckMonths <= 0 {
+		cfg.Acts.DriftCheckMonths = 3
+	}
+	if cfg.Acts.BatchWorkers <= 0 {
+		cfg.Acts.BatchWorkers = 4
+	}
+	if cfg.Jobs.Workers <= 0 {
+		cfg.Jobs.Workers = 2
+	}
+	pollInterval := v.GetString("ACTS_JOB_POLL_INTERVAL")
+	if pollInterval == "" {
+		pollInterval = "2s"
+	}
+	poll, err := time.ParseDuration(pollInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACTS_JOB_POLL_INTERVAL: %w", err)
+	}
+	cfg.Jobs.PollInterval = poll
+
+	if len(cfg.Schedule.Formats) == 0 {
+		cfg.Schedule.Formats = []string{"xlsx", "pdf"}
+	}
+	if raw := strings.TrimSpace(v.GetString("ACTS_SCHEDULE_ORG_ID")); raw != "" {
+		orgID, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ACTS_SCHEDULE_ORG_ID: %w", err)
+		}
+		cfg.Schedule.OrgID = orgID
+	}
+	if cfg.Storage.Kind == "" {
+		cfg.Storage.Kind = "disk"
+	}
+	if cfg.Storage.Dir == "" {
+		cfg.Storage.Dir = "./data/acts"
+	}
+
+	if cfg.Mail.SMTPPort == 0 {
+		cfg.Mail.SMTPPort = 25
+	}
+	if len(cfg.Mail.Formats) == 0 {
+		cfg.Mail.Formats = []string{"xlsx", "pdf"}
+	}
+	if cfg.Mail.MaxAttempts <= 0 {
+		cfg.Mail.MaxAttempts = 5
+	}
+	mailPoll := v.GetString("ACTS_MAIL_POLL_INTERVAL")
+	if mailPoll == "" {
+		mailPoll = "10s"
+	}
+	mailPollInterval, err := time.ParseDuration(mailPoll)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACTS_MAIL_POLL_INTERVAL: %w", err)
+	}
+	cfg.Mail.PollInterval = mailPollInterval
+
+	if cfg.Webhooks.MaxAttempts <= 0 {
+		cfg.Webhooks.MaxAttempts = 10
+	}
+	webhookPoll := v.GetString("ACTS_WEBHOOK_POLL_INTERVAL")
+	if webhookPoll == "" {
+		webhookPoll = "5s"
+	}
+	webhookPollInterval, err := time.ParseDuration(webhookPoll)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACTS_WEBHOOK_POLL_INTERVAL: %w", err)
+	}
+	cfg.Webhooks.PollInterval = webhookPollInterval
+	webhookTimeout := v.GetString("ACTS_WEBHOOK_TIMEOUT")
+	if webhookTimeout == "" {
+		webhookTimeout = "10s"
+	}
+	timeout, err := time.ParseDuration(webhookTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACTS_WEBHOOK_TIMEOUT: %w", err)
+	}
+	cfg.Webhooks.Timeout = timeout
+
 	if err := validate(cfg); err != nil {
 		return nil, err
 	}
@@ -80,6 +311,54 @@ func validate(cfg *Config) error {
 	if cfg.Auth.AccessSecret == "" {
 		return fmt.Errorf("JWT_ACCESS_SECRET is required")
 	}
+	if cfg.Acts.VATRate < 0 || cfg.Acts.VATRate >= 100 {
+		return fmt.Errorf("ACTS_VAT_RATE must be in [0, 100)")
+	}
+	if cfg.Jobs.PollInterval <= 0 {
+		return fmt.Errorf("ACTS_JOB_POLL_INTERVAL must be positive")
+	}
+	if len(cfg.Schedule.Crons) > 0 && cfg.Schedule.OrgID == uuid.Nil {
+		return fmt.Errorf("ACTS_SCHEDULE_ORG_ID is required with ACTS_SCHEDULES")
+	}
+	switch cfg.Storage.Kind {
+	case "disk":
+	case "s3":
+		if cfg.Storage.S3Endpoint == "" || cfg.Storage.S3Bucket == "" {
+			return fmt.Errorf("ACTS_S3_ENDPOINT and ACTS_S3_BUCKET are required with ACTS_STORAGE=s3")
+		}
+	default:
+		return fmt.Errorf("ACTS_STORAGE must be disk or s3")
+	}
+	if cfg.Mail.SMTPHost != "" && cfg.Mail.From == "" {
+		return fmt.Errorf("ACTS_MAIL_FROM is required with ACTS_SMTP_HOST")
+	}
+	if cfg.Mail.PollInterval <= 0 {
+		return fmt.Errorf("ACTS_MAIL_POLL_INTERVAL must be positive")
+	}
+	if cfg.Webhooks.PollInterval <= 0 || cfg.Webhooks.Timeout <= 0 {
+		return fmt.Errorf("ACTS_WEBHOOK_POLL_INTERVAL and ACTS_WEBHOOK_TIMEOUT must be positive")
+	}
 	return nil
 }
 
+// splitSchedules splits cron expressions separated by semicolons; the
+// expressions themselves contain spaces and commas.
+func splitSchedules(raw string) []string {
+	var result []string
+	for _, item := range strings.Split(raw, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func splitList(raw string) []string {
+	var result []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
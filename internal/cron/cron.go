@@ -0,0 +1,158 @@
+// Package cron parses five-field cron expressions (minute, hour, day of
+// month, month, day of week) and finds the moments they match.
+//
+// As in classic cron, when both the day of month and the day of week are
+// restricted a day matches if either of them does, so "0 9 1,15 * 1" runs on
+// the 1st, the 15th and every Monday. A field starting with "*", such as
+// "*/2", does not count as restricted.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	expr     string
+	minutes  field
+	hours    field
+	days     field
+	months   field
+	weekdays field
+	// anyDay and anyWeekday record that the day of month and day of week
+	// fields start with "*".
+	anyDay     bool
+	anyWeekday bool
+}
+
+// field is the set of values a cron field matches.
+type field uint64
+
+func (f field) has(value int) bool {
+	return f&(1<<uint(value)) != 0
+}
+
+// bounds are the allowed values of a field. Day of week 7 is Sunday, like 0.
+var bounds = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse parses an expression of five space-separated fields. A field is "*"
+// or a comma-separated list of values and ranges "a-b", each optionally
+// followed by a step "/n".
+func Parse(expr string) (Schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(bounds) {
+		return Schedule{}, fmt.Errorf("cron %q: expected %d fields, got %d", expr, len(bounds), len(parts))
+	}
+	var fields [5]field
+	for i, part := range parts {
+		f, err := parseField(part, bounds[i].min, bounds[i].max)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("cron %q: %s: %w", expr, bounds[i].name, err)
+		}
+		fields[i] = f
+	}
+	if fields[4].has(7) {
+		fields[4] |= 1
+	}
+	return Schedule{
+		expr:       strings.Join(parts, " "),
+		minutes:    fields[0],
+		hours:      fields[1],
+		days:       fields[2],
+		months:     fields[3],
+		weekdays:   fields[4],
+		anyDay:     strings.HasPrefix(parts[2], "*"),
+		anyWeekday: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func parseField(raw string, min, max int) (field, error) {
+	var f field
+	for _, item := range strings.Split(raw, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		from, to := min, max
+		if rangePart != "*" {
+			low, high, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if from, err = strconv.Atoi(low); err != nil {
+				return 0, fmt.Errorf("invalid value %q", low)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(high); err != nil {
+					return 0, fmt.Errorf("invalid value %q", high)
+				}
+			} else if hasStep {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("%q is out of range %d-%d", item, min, max)
+		}
+		for v := from; v <= to; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+// matchDay reports whether the day of t matches the day of month and day of
+// week fields: both of them if either starts with "*", otherwise either one.
+func (s Schedule) matchDay(t time.Time) bool {
+	day, weekday := s.days.has(t.Day()), s.weekdays.has(int(t.Weekday()))
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// String returns the normalized expression.
+func (s Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first moment after t that matches the schedule, in t's
+// location. It returns the zero time if nothing matches within five years,
+// e.g. for 31 February.
+func (s Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !s.months.has(int(m)):
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchDay(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case !s.hours.has(t.Hour()):
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case !s.minutes.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,93 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr bool
+	}{
+		{expr: "0 9 * * 1-5", want: "0 9 * * 1-5"},
+		{expr: "  0\t9  1,15 *  */2 ", want: "0 9 1,15 * */2"},
+		{expr: "1-10/3 9-17/4 * 1-12 0,7", want: "1-10/3 9-17/4 * 1-12 0,7"},
+		{expr: "0 9 * *", wantErr: true},
+		{expr: "0 9 * * * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "* 24 * * *", wantErr: true},
+		{expr: "* * 0 * *", wantErr: true},
+		{expr: "* * 32 * *", wantErr: true},
+		{expr: "* * * 13 *", wantErr: true},
+		{expr: "* * * * 8", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "*/x * * * *", wantErr: true},
+		{expr: "a * * * *", wantErr: true},
+		{expr: "1- * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.expr)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %q, want an error", tt.expr, schedule)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := schedule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	almaty := time.FixedZone("Asia/Almaty", 5*60*60)
+	at := func(loc *time.Location, year int, month time.Month, day, hour, minute, second int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, loc)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"step minutes", "*/15 * * * *", at(time.UTC, 2026, 1, 5, 10, 7, 0), at(time.UTC, 2026, 1, 5, 10, 15, 0)},
+		{"strictly after a match", "0 * * * *", at(time.UTC, 2026, 1, 5, 10, 0, 0), at(time.UTC, 2026, 1, 5, 11, 0, 0)},
+		{"seconds are dropped", "0 * * * *", at(time.UTC, 2026, 1, 5, 10, 59, 30), at(time.UTC, 2026, 1, 5, 11, 0, 0)},
+		{"range with step", "1-10/3 * * * *", at(time.UTC, 2026, 1, 5, 10, 4, 0), at(time.UTC, 2026, 1, 5, 10, 7, 0)},
+		{"range with step wraps to next hour", "1-10/3 * * * *", at(time.UTC, 2026, 1, 5, 10, 10, 0), at(time.UTC, 2026, 1, 5, 11, 1, 0)},
+		{"hour range with step", "0 9-17/4 * * *", at(time.UTC, 2026, 1, 5, 13, 0, 0), at(time.UTC, 2026, 1, 5, 17, 0, 0)},
+		{"day rollover", "0 9-17/4 * * *", at(time.UTC, 2026, 1, 5, 17, 0, 0), at(time.UTC, 2026, 1, 6, 9, 0, 0)},
+		{"month rollover", "0 9 1 * *", at(time.UTC, 2026, 1, 31, 12, 0, 0), at(time.UTC, 2026, 2, 1, 9, 0, 0)},
+		{"year rollover", "0 0 * * *", at(time.UTC, 2026, 12, 31, 23, 59, 0), at(time.UTC, 2027, 1, 1, 0, 0, 0)},
+		{"month field rolls over the year", "30 8 1 1 *", at(time.UTC, 2026, 3, 10, 0, 0, 0), at(time.UTC, 2027, 1, 1, 8, 30, 0)},
+		{"leap day", "0 0 29 2 *", at(time.UTC, 2026, 3, 1, 0, 0, 0), at(time.UTC, 2028, 2, 29, 0, 0, 0)},
+		{"weekday range skips the weekend", "0 9 * * 1-5", at(time.UTC, 2026, 1, 9, 10, 0, 0), at(time.UTC, 2026, 1, 12, 9, 0, 0)},
+		{"sunday as 7", "0 0 * * 7", at(time.UTC, 2026, 1, 1, 0, 0, 0), at(time.UTC, 2026, 1, 4, 0, 0, 0)},
+		{"day of month or day of week: weekday first", "0 9 13 * 1", at(time.UTC, 2026, 1, 9, 10, 0, 0), at(time.UTC, 2026, 1, 12, 9, 0, 0)},
+		{"day of month or day of week: day first", "0 9 13 * 1", at(time.UTC, 2026, 1, 12, 9, 0, 0), at(time.UTC, 2026, 1, 13, 9, 0, 0)},
+		{"day of month or day of week: next weekday", "0 9 13 * 1", at(time.UTC, 2026, 1, 13, 9, 0, 0), at(time.UTC, 2026, 1, 19, 9, 0, 0)},
+		{"starred day of week is combined with AND", "0 9 1 * */2", at(time.UTC, 2026, 1, 1, 10, 0, 0), at(time.UTC, 2026, 2, 1, 9, 0, 0)},
+		{"location of t", "0 9 1 * *", at(almaty, 2026, 1, 31, 12, 0, 0), at(almaty, 2026, 2, 1, 9, 0, 0)},
+		{"nothing matches", "0 0 31 2 *", at(time.UTC, 2026, 1, 1, 0, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := schedule.Next(tt.from)
+			if !got.Equal(tt.want) || (!tt.want.IsZero() && got.Location() != tt.want.Location()) {
+				t.Errorf("Next(%s) of %q = %s, want %s", tt.from, tt.expr, got, tt.want)
+			}
+		})
+	}
+}
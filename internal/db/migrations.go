@@ -1,7 +1,348 @@
 package db
 
-import "gorm.io/gorm"
+import (
+	"fmt"
 
-func runMigrations(_ *gorm.DB) error {
-	return nil
+	"gorm.io/gorm"
+)
+
+// migrations are applied in order and recorded by their 1-based position, so
+// new statements must only ever be appended.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS act_number_sequences (
+		year INTEGER PRIMARY KEY,
+		last_value BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS acts (
+		id UUID PRIMARY KEY,
+		number TEXT NOT NULL UNIQUE,
+		year INTEGER NOT NULL,
+		sequence BIGINT NOT NULL,
+		mode TEXT NOT NULL,
+		target_id UUID NOT NULL,
+		period_start DATE NOT NULL,
+		period_end DATE NOT NULL,
+		format TEXT NOT NULL,
+		created_by UUID,
+		created_by_org UUID,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (year, sequence)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_acts_target_period ON acts (target_id, period_start, period_end)`,
+	`CREATE TABLE IF NOT EXISTS tariffs (
+		id UUID PRIMARY KEY,
+		landfill_id UUID,
+		contractor_id UUID,
+		unit TEXT NOT NULL CHECK (unit IN ('M3', 'TRIP')),
+		price NUMERIC(14, 2) NOT NULL CHECK (price >= 0),
+		valid_from DATE NOT NULL,
+		valid_to DATE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		CHECK (valid_to IS NULL OR valid_to >= valid_from)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_tariffs_validity ON tariffs (valid_from, valid_to)`,
+	`CREATE TABLE IF NOT EXISTS landfill_cameras (
+		id UUID PRIMARY KEY,
+		camera_id TEXT NOT NULL,
+		landfill_id UUID NOT NULL,
+		valid_from TIMESTAMPTZ NOT NULL,
+		valid_to TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		CHECK (valid_to IS NULL OR valid_to > valid_from)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_landfill_cameras_camera ON landfill_cameras (camera_id, valid_from)`,
+	`INSERT INTO landfill_cameras (id, camera_id, landfill_id, valid_from)
+	SELECT gen_random_uuid(), seed.camera_id, lf.id, TIMESTAMPTZ '2000-01-01 00:00:00+00'
+	FROM (VALUES
+		('shahovskoye', 'шаховское'),
+		('yakor', 'якорь'),
+		('solnechniy', 'солнечный')
+	) AS seed (camera_id, landfill_name)
+	JOIN organizations lf
+	  ON lf.type = 'LANDFILL'
+	 AND LOWER(lf.name) = seed.landfill_name
+	WHERE NOT EXISTS (
+		SELECT 1 FROM landfill_cameras lc WHERE lc.camera_id = seed.camera_id
+	)`,
+	`ALTER TABLE acts
+		ALTER COLUMN number DROP NOT NULL,
+		ALTER COLUMN year DROP NOT NULL,
+		ALTER COLUMN sequence DROP NOT NULL,
+		ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'ISSUED',
+		ADD COLUMN IF NOT EXISTS total_trips BIGINT NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS total_volume_m3 NUMERIC(14, 3) NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS net_amount NUMERIC(16, 2) NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS vat_amount NUMERIC(16, 2) NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS gross_amount NUMERIC(16, 2) NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		ADD COLUMN IF NOT EXISTS issued_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS issued_by UUID,
+		ADD COLUMN IF NOT EXISTS contractor_signed_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS contractor_signed_by UUID,
+		ADD COLUMN IF NOT EXISTS accepted_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS accepted_by UUID,
+		ADD COLUMN IF NOT EXISTS accepted_by_org UUID,
+		ADD COLUMN IF NOT EXISTS approved_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS approved_by UUID,
+		ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS cancelled_by UUID,
+		ADD COLUMN IF NOT EXISTS cancel_reason TEXT NOT NULL DEFAULT ''`,
+	`UPDATE acts SET issued_at = created_at, issued_by = created_by WHERE issued_at IS NULL AND number IS NOT NULL`,
+	`CREATE INDEX IF NOT EXISTS idx_acts_status ON acts (status, created_at DESC)`,
+	`CREATE TABLE IF NOT EXISTS act_snapshots (
+		act_id UUID PRIMARY KEY REFERENCES acts (id) ON DELETE CASCADE,
+		report JSONB NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS act_snapshot_events (
+		act_id UUID NOT NULL REFERENCES act_snapshots (act_id) ON DELETE CASCADE,
+		event_id UUID NOT NULL,
+		group_index INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		event_time TIMESTAMPTZ NOT NULL,
+		plate TEXT,
+		polygon_id UUID,
+		polygon_name TEXT,
+		contractor_id UUID,
+		contractor_name TEXT,
+		snow_volume_m3 DOUBLE PRECISION,
+		PRIMARY KEY (act_id, event_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_act_snapshot_events_event ON act_snapshot_events (event_id)`,
+	`ALTER TABLE acts ADD COLUMN IF NOT EXISTS fingerprint TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE IF NOT EXISTS act_drift_checks (
+		act_id UUID PRIMARY KEY REFERENCES acts (id) ON DELETE CASCADE,
+		checked_at TIMESTAMPTZ NOT NULL,
+		current_fingerprint TEXT NOT NULL,
+		has_drift BOOLEAN NOT NULL,
+		added_count INTEGER NOT NULL,
+		removed_count INTEGER NOT NULL,
+		changed_count INTEGER NOT NULL,
+		diff JSONB NOT NULL
+	)`,
+	`ALTER TABLE acts
+		ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'REGULAR',
+		ADD COLUMN IF NOT EXISTS original_act_id UUID REFERENCES acts (id)`,
+	`CREATE INDEX IF NOT EXISTS idx_acts_original ON acts (original_act_id)`,
+	`CREATE TABLE IF NOT EXISTS act_group_totals (
+		act_id UUID NOT NULL REFERENCES acts (id) ON DELETE CASCADE,
+		group_index INTEGER NOT NULL,
+		group_id UUID NOT NULL,
+		group_name TEXT NOT NULL,
+		trip_count BIGINT NOT NULL,
+		volume_m3 DOUBLE PRECISION NOT NULL,
+		net_amount NUMERIC(14, 2) NOT NULL,
+		vat_amount NUMERIC(14, 2) NOT NULL,
+		gross_amount NUMERIC(14, 2) NOT NULL,
+		PRIMARY KEY (act_id, group_index)
+	)`,
+	// Backfill group totals of acts issued before the table existed from
+	// their snapshots.
+	`INSERT INTO act_group_totals (
+		act_id, group_index, group_id, group_name, trip_count, volume_m3,
+		net_amount, vat_amount, gross_amount
+	)
+	SELECT
+		s.act_id,
+		g.idx - 1,
+		(g.value ->> 'ID')::uuid,
+		COALESCE(g.value ->> 'Name', ''),
+		COALESCE((g.value ->> 'TripCount')::bigint, 0),
+		COALESCE((
+			SELECT SUM(e.snow_volume_m3)
+			FROM act_snapshot_events e
+			WHERE e.act_id = s.act_id AND e.group_index = g.idx - 1
+		), 0),
+		COALESCE((g.value ->> 'NetAmount')::numeric, 0),
+		COALESCE((g.value ->> 'VATAmount')::numeric, 0),
+		COALESCE((g.value ->> 'GrossAmount')::numeric, 0)
+	FROM act_snapshots s
+	CROSS JOIN LATERAL jsonb_array_elements(
+		CASE WHEN jsonb_typeof(s.report -> 'Groups') = 'array' THEN s.report -> 'Groups' ELSE '[]'::jsonb END
+	) WITH ORDINALITY AS g(value, idx)
+	ON CONFLICT (act_id, group_index) DO NOTHING`,
+	`CREATE TABLE IF NOT EXISTS export_jobs (
+		id UUID PRIMARY KEY,
+		status TEXT NOT NULL,
+		progress INTEGER NOT NULL DEFAULT 0,
+		format TEXT NOT NULL,
+		mode TEXT NOT NULL,
+		target_id UUID NOT NULL,
+		period_start DATE NOT NULL,
+		period_end DATE NOT NULL,
+		file_name TEXT NOT NULL DEFAULT '',
+		content BYTEA,
+		error TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		created_by UUID NOT NULL,
+		created_by_org UUID NOT NULL,
+		created_by_role TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		started_at TIMESTAMPTZ,
+		heartbeat_at TIMESTAMPTZ,
+		finished_at TIMESTAMPTZ
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_export_jobs_queue ON export_jobs (status, created_at)`,
+	`ALTER TABLE export_jobs ADD COLUMN IF NOT EXISTS lang TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE acts ADD COLUMN IF NOT EXISTS landfill_id UUID`,
+	`CREATE INDEX IF NOT EXISTS idx_acts_landfill ON acts (landfill_id) WHERE landfill_id IS NOT NULL`,
+	`ALTER TABLE export_jobs
+		ADD COLUMN IF NOT EXISTS landfill_id UUID,
+		ADD COLUMN IF NOT EXISTS hide_empty_groups BOOLEAN NOT NULL DEFAULT FALSE`,
+	`CREATE TABLE IF NOT EXISTS scheduled_acts (
+		id UUID PRIMARY KEY,
+		schedule TEXT NOT NULL,
+		act_id UUID NOT NULL REFERENCES acts(id),
+		act_number TEXT NOT NULL,
+		mode TEXT NOT NULL,
+		target_id UUID NOT NULL,
+		period_start DATE NOT NULL,
+		period_end DATE NOT NULL,
+		format TEXT NOT NULL,
+		file_name TEXT NOT NULL,
+		storage_key TEXT NOT NULL,
+		size_bytes BIGINT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (mode, target_id, period_start, period_end, format)
+	)`,
+	`CREATE TABLE IF NOT EXISTS organization_recipients (
+		id UUID PRIMARY KEY,
+		organization_id UUID NOT NULL,
+		email TEXT NOT NULL,
+		lang TEXT NOT NULL DEFAULT 'ru',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (organization_id, email)
+	)`,
+	`CREATE TABLE IF NOT EXISTS act_deliveries (
+		id UUID PRIMARY KEY,
+		act_id UUID NOT NULL REFERENCES acts(id),
+		organization_id UUID NOT NULL,
+		email TEXT NOT NULL,
+		lang TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		sent_at TIMESTAMPTZ
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_act_deliveries_queue ON act_deliveries (next_attempt_at) WHERE status = 'QUEUED'`,
+	`CREATE INDEX IF NOT EXISTS idx_act_deliveries_act ON act_deliveries (act_id, created_at)`,
+	`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id UUID PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_by UUID NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhook_outbox (
+		id UUID PRIMARY KEY,
+		subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+		event TEXT NOT NULL,
+		act_id UUID NOT NULL REFERENCES acts(id),
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_status INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		sent_at TIMESTAMPTZ
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_webhook_outbox_queue ON webhook_outbox (next_attempt_at) WHERE status = 'QUEUED'`,
+	`CREATE INDEX IF NOT EXISTS idx_webhook_outbox_subscription ON webhook_outbox (subscription_id, created_at)`,
+	`CREATE TABLE IF NOT EXISTS act_audit_log (
+		id UUID PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		action TEXT NOT NULL,
+		user_id UUID NOT NULL,
+		org_id UUID NOT NULL,
+		role TEXT NOT NULL,
+		mode TEXT NOT NULL DEFAULT '',
+		target_id UUID,
+		landfill_id UUID,
+		act_id UUID,
+		job_id UUID,
+		period_start DATE,
+		period_end DATE,
+		format TEXT NOT NULL DEFAULT '',
+		foreign_target BOOLEAN NOT NULL DEFAULT FALSE,
+		outcome TEXT NOT NULL,
+		status_code INTEGER NOT NULL,
+		duration_ms BIGINT NOT NULL,
+		size_bytes BIGINT NOT NULL DEFAULT 0,
+		checksum TEXT NOT NULL DEFAULT '',
+		client_ip TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_act_audit_log_created ON act_audit_log (created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_act_audit_log_user ON act_audit_log (user_id, created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_act_audit_log_outcome ON act_audit_log (outcome, created_at)`,
+	`ALTER TABLE acts ADD COLUMN IF NOT EXISTS base_act_id UUID REFERENCES acts (id)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_acts_base_live ON acts (base_act_id) WHERE status <> 'CANCELLED'`,
+	`ALTER TABLE export_jobs ADD COLUMN IF NOT EXISTS act_id UUID NOT NULL DEFAULT gen_random_uuid()`,
+	`ALTER TABLE scheduled_acts ADD COLUMN IF NOT EXISTS stored_at TIMESTAMPTZ`,
+	`UPDATE scheduled_acts SET stored_at = created_at WHERE stored_at IS NULL`,
+	`CREATE EXTENSION IF NOT EXISTS btree_gist`,
+	`ALTER TABLE landfill_cameras
+		ADD CONSTRAINT landfill_cameras_no_overlap
+		EXCLUDE USING gist (camera_id WITH =, tstzrange(valid_from, valid_to) WITH &&)`,
+	`ALTER TABLE export_jobs
+		ADD COLUMN IF NOT EXISTS storage_key TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS size_bytes BIGINT NOT NULL DEFAULT 0`,
+	`ALTER TABLE tariffs ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()`,
+	`ALTER TABLE tariffs
+		ADD CONSTRAINT tariffs_no_overlap
+		EXCLUDE USING gist (
+			(COALESCE(landfill_id, '00000000-0000-0000-0000-000000000000'::uuid)) WITH =,
+			(COALESCE(contractor_id, '00000000-0000-0000-0000-000000000000'::uuid)) WITH =,
+			daterange(valid_from, valid_to, '[]') WITH &&
+		)`,
+}
+
+// migrationsLockKey serializes migrations of concurrently starting replicas.
+const migrationsLockKey = 7089001
+
+// runMigrations applies pending migrations in one transaction. Applied
+// versions are recorded in schema_migrations, so data migrations (seeds) run
+// exactly once.
+func runMigrations(database *gorm.DB) error {
+	return database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`SELECT pg_advisory_xact_lock(?)`, migrationsLockKey).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version INTEGER PRIMARY KEY,
+				applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			)
+		`).Error; err != nil {
+			return err
+		}
+
+		var applied []int
+		if err := tx.Raw(`SELECT version FROM schema_migrations`).Scan(&applied).Error; err != nil {
+			return err
+		}
+		done := make(map[int]struct{}, len(applied))
+		for _, version := range applied {
+			done[version] = struct{}{}
+		}
+
+		for i, stmt := range migrations {
+			version := i + 1
+			if _, ok := done[version]; ok {
+				continue
+			}
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("migration %d: %w", version, err)
+			}
+			if err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version).Error; err != nil {
+				return fmt.Errorf("record migration %d: %w", version, err)
+			}
+		}
+		return nil
+	})
 }
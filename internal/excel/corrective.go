@@ -0,0 +1,104 @@
+package excel
+
+import (
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// GenerateCorrective renders a corrective act: for every group the figures of
+// the original act ("было"), the recalculated ones ("стало") and the
+// difference ("разница").
+func (g *Generator) GenerateCorrective(report model.CorrectiveReport) ([]byte, error) {
+	l := labels.For(report.Lang)
+	file := excelize.NewFile()
+	sheet := l.CorrectiveSheet
+	file.SetSheetName("Sheet1", sheet)
+
+	set := func(cell string, value interface{}) {
+		_ = file.SetCellValue(sheet, cell, value)
+	}
+
+	modeLabel, groupLabel := l.Mode(report.Mode)
+	set("A1", l.Corrective)
+	set("B1", report.Number)
+	set("A2", l.Date)
+	set("B2", l.FormatDate(report.IssuedAt))
+	set("A3", l.OriginalAct)
+	set("B3", report.OriginalNumber)
+	set("A4", l.OriginalActDate)
+	set("B4", l.FormatDate(report.OriginalIssuedAt))
+	set("A5", l.ReportType)
+	set("B5", modeLabel)
+	set("A6", l.Organization)
+	set("B6", report.Target.Name)
+	set("A7", l.PeriodStart)
+	set("B7", l.FormatDate(report.PeriodStart))
+	set("A8", l.PeriodEnd)
+	set("B8", l.FormatDate(report.PeriodEnd))
+	if report.PreviousNumber != "" {
+		set("A9", l.PreviousAct)
+		set("B9", l.NumberDate(l.NumberDateFormat, report.PreviousNumber, report.PreviousIssuedAt))
+	}
+
+	headerRow := 10
+	set(fmt.Sprintf("A%d", headerRow), groupLabel)
+	_ = file.MergeCell(sheet, fmt.Sprintf("A%d", headerRow), fmt.Sprintf("A%d", headerRow+1))
+	for i, metric := range correctiveMetrics {
+		first := 2 + i*3
+		start, _ := excelize.CoordinatesToCellName(first, headerRow)
+		end, _ := excelize.CoordinatesToCellName(first+2, headerRow)
+		set(start, metric.label(l))
+		_ = file.MergeCell(sheet, start, end)
+		for j, column := range []string{l.Before, l.After, l.Difference} {
+			cell, _ := excelize.CoordinatesToCellName(first+j, headerRow+1)
+			set(cell, column)
+		}
+	}
+
+	row := headerRow + 1
+	writeRow := func(name string, before, after model.GroupTotals) {
+		row++
+		set(fmt.Sprintf("A%d", row), name)
+		delta := after.Sub(before)
+		for i, metric := range correctiveMetrics {
+			for j, totals := range []model.GroupTotals{before, after, delta} {
+				cell, _ := excelize.CoordinatesToCellName(2+i*3+j, row)
+				set(cell, metric.value(totals))
+			}
+		}
+	}
+	for _, line := range report.Rows {
+		writeRow(line.GroupName, line.Before, line.After)
+	}
+	writeRow(l.Total, report.Before, report.After)
+
+	if report.Reprint {
+		_ = file.SetCellValue(sheet, "D1", l.Reprint)
+	}
+
+	_ = file.SetColWidth(sheet, "A", "A", 40)
+	_ = file.SetColWidth(sheet, "B", "P", 14)
+	file.SetActiveSheet(0)
+	buf, err := file.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type correctiveMetric struct {
+	label func(labels.Labels) string
+	value func(model.GroupTotals) interface{}
+}
+
+var correctiveMetrics = []correctiveMetric{
+	{func(l labels.Labels) string { return l.TripCount }, func(t model.GroupTotals) interface{} { return t.TripCount }},
+	{func(l labels.Labels) string { return l.SnowVolume }, func(t model.GroupTotals) interface{} { return formatFloatValue(t.VolumeM3, true) }},
+	{func(l labels.Labels) string { return l.NetAmount }, func(t model.GroupTotals) interface{} { return formatMoney(t.NetAmount) }},
+	{func(l labels.Labels) string { return l.VATAmount }, func(t model.GroupTotals) interface{} { return formatMoney(t.VATAmount) }},
+	{func(l labels.Labels) string { return l.GrossAmount }, func(t model.GroupTotals) interface{} { return formatMoney(t.GrossAmount) }},
+}
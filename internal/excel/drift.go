@@ -0,0 +1,92 @@
+package excel
+
+import (
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// GenerateDrift renders the drift workbook ("Расхождения"): differences
+// between an issued act and the current ANPR data.
+func (g *Generator) GenerateDrift(report model.DriftReport) ([]byte, error) {
+	l := labels.For(report.Lang)
+	file := excelize.NewFile()
+	sheet := l.DriftSheet
+	file.SetSheetName("Sheet1", sheet)
+
+	set := func(cell string, value interface{}) {
+		_ = file.SetCellValue(sheet, cell, value)
+	}
+
+	modeLabel, groupLabel := l.Mode(report.Mode)
+	set("A1", l.ActNumber)
+	set("B1", report.ActNumber)
+	set("A2", l.ReportType)
+	set("B2", modeLabel)
+	set("A3", l.Organization)
+	set("B3", report.TargetName)
+	set("A4", l.PeriodStart)
+	set("B4", l.FormatDate(report.PeriodStart))
+	set("A5", l.PeriodEnd)
+	set("B5", l.FormatDate(report.PeriodEnd))
+	set("A6", l.IssueDate)
+	if report.IssuedAt != nil {
+		set("B6", l.FormatDateTime(*report.IssuedAt))
+	}
+	set("A7", l.CheckDate)
+	set("B7", l.FormatDateTime(report.CheckedAt))
+	set("A8", l.EventsAdded)
+	set("B8", len(report.Added))
+	set("A9", l.EventsRemoved)
+	set("B9", len(report.Removed))
+	set("A10", l.VolumeChanged)
+	set("B10", len(report.Changed))
+
+	tableRow := 12
+	headers := []string{l.Change, l.EventID, l.Date, l.Plate, groupLabel, l.VolumeInAct, l.VolumeNow, l.VolumeDifference}
+	for i, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, tableRow)
+		set(cell, header)
+	}
+
+	row := tableRow
+	for _, changes := range [][]model.DriftChange{report.Added, report.Removed, report.Changed} {
+		for _, change := range changes {
+			row++
+			set(fmt.Sprintf("A%d", row), l.DriftKind(change.Kind))
+			set(fmt.Sprintf("B%d", row), change.EventID.String())
+			set(fmt.Sprintf("C%d", row), l.FormatDateTime(change.EventTime))
+			set(fmt.Sprintf("D%d", row), change.Plate)
+			set(fmt.Sprintf("E%d", row), change.GroupName)
+			set(fmt.Sprintf("F%d", row), formatFloat(change.VolumeBefore))
+			set(fmt.Sprintf("G%d", row), formatFloat(change.VolumeAfter))
+			set(fmt.Sprintf("H%d", row), formatFloatValue(volumeOrZero(change.VolumeAfter)-volumeOrZero(change.VolumeBefore), true))
+		}
+	}
+	if row == tableRow {
+		set(fmt.Sprintf("A%d", row+1), l.NoDrift)
+	}
+
+	_ = file.SetColWidth(sheet, "A", "A", 22)
+	_ = file.SetColWidth(sheet, "B", "B", 38)
+	_ = file.SetColWidth(sheet, "C", "C", 20)
+	_ = file.SetColWidth(sheet, "D", "D", 16)
+	_ = file.SetColWidth(sheet, "E", "E", 32)
+	_ = file.SetColWidth(sheet, "F", "H", 18)
+
+	buf, err := file.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func volumeOrZero(value *float64) float64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
@@ -1,14 +1,21 @@
 package excel
 
 import (
+	"archive/zip"
+	"bytes"
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/xuri/excelize/v2"
 
+	"github.com/nurpe/snowops-acts/internal/labels"
 	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/textutil"
 )
 
 type Generator struct{}
@@ -18,151 +25,324 @@ func NewGenerator() *Generator {
 }
 
 func (g *Generator) Generate(report model.ActReport) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := g.Write(&buf, report, report.EachTrip); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Write streams the workbook to w. Detail sheets are written with a
+// StreamWriter while trips are read from trips, so the trips never have to be
+// in memory at once; report.Groups only needs the group totals.
+func (g *Generator) Write(w io.Writer, report model.ActReport, trips model.TripSource) error {
 	file := excelize.NewFile()
+	defer file.Close()
 
-	summarySheet := "Сводка"
+	l := labels.For(report.Lang)
+	summarySheet := l.Summary
 	file.SetSheetName("Sheet1", summarySheet)
-	if err := g.writeSummary(file, summarySheet, report); err != nil {
-		return nil, err
+	if err := g.writeSummary(file, summarySheet, l, report); err != nil {
+		return err
 	}
+	// SetActiveSheet reads every worksheet into memory, so it has to run
+	// before the detail sheets are streamed.
+	file.SetActiveSheet(0)
 
 	usedNames := map[string]struct{}{summarySheet: {}}
-	for _, group := range report.Groups {
-		sheetName := buildSheetName(report.Mode, group.Name, group.ID, usedNames)
+	if report.Matrix != nil {
+		matrixSheet := l.Matrix
+		if _, err := file.NewSheet(matrixSheet); err != nil {
+			return err
+		}
+		if err := g.writeMatrix(file, matrixSheet, l, report); err != nil {
+			return err
+		}
+		usedNames[matrixSheet] = struct{}{}
+	}
+	sheets := make([]string, len(report.Groups))
+	for i, group := range report.Groups {
+		if !report.Shown(group) {
+			continue
+		}
+		sheetName := buildSheetName(l, report.Mode, group.Name, group.ID, usedNames)
 		usedNames[sheetName] = struct{}{}
+		sheets[i] = sheetName
+		if _, err := file.NewSheet(sheetName); err != nil {
+			return err
+		}
+	}
 
-		file.NewSheet(sheetName)
-		if err := g.writeDetail(file, sheetName, report, group); err != nil {
-			return nil, err
+	// Trips arrive group by group; every detail sheet is opened when its
+	// group starts and flushed before the next one, including the sheets of
+	// shown groups without trips.
+	var detail *detailWriter
+	next := 0
+	openUntil := func(groupIndex int) error {
+		for next <= groupIndex && next < len(report.Groups) {
+			if detail != nil {
+				if err := detail.flush(); err != nil {
+					return err
+				}
+				detail = nil
+			}
+			if sheets[next] != "" {
+				var err error
+				detail, err = g.openDetail(file, sheets[next], l, report, report.Groups[next])
+				if err != nil {
+					return err
+				}
+			}
+			next++
 		}
+		return nil
 	}
 
-	file.SetActiveSheet(0)
-	buf, err := file.WriteToBuffer()
+	err := trips(func(groupIndex int, trip model.TripDetail) error {
+		if groupIndex < 0 || groupIndex >= len(report.Groups) {
+			return nil
+		}
+		if err := openUntil(groupIndex); err != nil {
+			return err
+		}
+		if groupIndex != next-1 {
+			return fmt.Errorf("trips of group %d arrived out of order", groupIndex)
+		}
+		if detail == nil {
+			return nil
+		}
+		return detail.writeTrip(trip)
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return buf.Bytes(), nil
+	if err := openUntil(len(report.Groups) - 1); err != nil {
+		return err
+	}
+	if detail != nil {
+		if err := detail.flush(); err != nil {
+			return err
+		}
+	}
+
+	// The marker is written last so the rest of the workbook stays identical
+	// to the original document.
+	if report.Reprint {
+		_ = file.SetCellValue(summarySheet, "D1", l.Reprint)
+	}
+
+	return writeSorted(file, w)
 }
 
-func (g *Generator) writeSummary(file *excelize.File, sheet string, report model.ActReport) error {
-	modeLabel, groupLabel := reportLabels(report.Mode)
-	totalVolume := sumReportVolume(report)
+// writeSorted writes the workbook to w with its zip entries in a fixed order.
+// excelize emits stream-written sheets in map order, so the package is spooled
+// to a temporary file and its entries are copied, still compressed, sorted by
+// name. Reprints of an act stay byte-identical this way.
+func writeSorted(file *excelize.File, w io.Writer) error {
+	tmp, err := os.CreateTemp("", "acts-*.xlsx")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := file.WriteTo(tmp)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return err
+	}
+	entries := append([]*zip.File(nil), zr.File...)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		dst, err := zw.CreateRaw(&entry.FileHeader)
+		if err != nil {
+			return err
+		}
+		src, err := entry.OpenRaw()
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (g *Generator) writeSummary(file *excelize.File, sheet string, l labels.Labels, report model.ActReport) error {
+	modeLabel, groupLabel := l.Mode(report.Mode)
 
 	set := func(cell string, value interface{}) {
 		_ = file.SetCellValue(sheet, cell, value)
 	}
 
-	set("A1", "Тип отчета")
-	set("B1", modeLabel)
-	set("A2", "Организация")
-	set("B2", report.Target.Name)
-	set("A3", "Начало периода")
-	set("B3", formatDate(report.PeriodStart))
-	set("A4", "Конец периода")
-	set("B4", formatDate(report.PeriodEnd))
-	set("A5", "Количество рейсов")
-	set("B5", report.TotalTrips)
-	set("A6", "Объем снега, м3")
-	set("B6", formatFloatValue(totalVolume, true))
-
-	tableRow := 8
+	set("A1", l.ActNumber)
+	set("B1", report.Number)
+	set("A2", l.ReportType)
+	set("B2", modeLabel)
+	set("A3", l.Organization)
+	set("B3", report.Target.Name)
+	set("A4", l.PeriodStart)
+	set("B4", l.FormatDate(report.PeriodStart))
+	set("A5", l.PeriodEnd)
+	set("B5", l.FormatDate(report.PeriodEnd))
+	set("A6", l.TripCount)
+	set("B6", report.TotalTrips)
+	set("A7", l.SnowVolume)
+	set("B7", formatFloatValue(report.TotalVolumeM3, true))
+	set("A8", l.NetAmount)
+	set("B8", formatMoney(report.NetAmount))
+	set("A9", l.VAT(report.VATRate))
+	set("B9", formatMoney(report.VATAmount))
+	set("A10", l.GrossAmount)
+	set("B10", formatMoney(report.GrossAmount))
+	set("A11", l.Statuses)
+	set("B11", strings.Join(report.Statuses, ", "))
+
+	tableRow := 13
 	set(fmt.Sprintf("A%d", tableRow), groupLabel)
-	set(fmt.Sprintf("B%d", tableRow), "Количество рейсов")
-	set(fmt.Sprintf("C%d", tableRow), "Объем снега, м3")
+	set(fmt.Sprintf("B%d", tableRow), l.TripCount)
+	set(fmt.Sprintf("C%d", tableRow), l.SnowVolume)
+	set(fmt.Sprintf("D%d", tableRow), l.NetAmount)
+	set(fmt.Sprintf("E%d", tableRow), l.VATAmount)
+	set(fmt.Sprintf("F%d", tableRow), l.GrossAmount)
 
-	for i, group := range report.Groups {
-		row := tableRow + 1 + i
+	row := tableRow
+	for _, group := range report.Groups {
+		if !report.Shown(group) {
+			continue
+		}
+		row++
 		set(fmt.Sprintf("A%d", row), group.Name)
 		set(fmt.Sprintf("B%d", row), group.TripCount)
-		set(fmt.Sprintf("C%d", row), formatFloatValue(sumGroupVolume(report.Mode, group), true))
+		set(fmt.Sprintf("C%d", row), formatFloatValue(group.VolumeM3, true))
+		set(fmt.Sprintf("D%d", row), formatMoney(group.NetAmount))
+		set(fmt.Sprintf("E%d", row), formatMoney(group.VATAmount))
+		set(fmt.Sprintf("F%d", row), formatMoney(group.GrossAmount))
 	}
 
 	_ = file.SetColWidth(sheet, "A", "A", 45)
-	_ = file.SetColWidth(sheet, "B", "B", 16)
-	_ = file.SetColWidth(sheet, "C", "C", 16)
+	_ = file.SetColWidth(sheet, "B", "F", 18)
 	return nil
 }
 
-func (g *Generator) writeDetail(file *excelize.File, sheet string, report model.ActReport, group model.TripGroup) error {
-	modeLabel, groupLabel := reportLabels(report.Mode)
-	groupVolume := sumGroupVolume(report.Mode, group)
+// detailWriter streams the detail sheet of one group.
+type detailWriter struct {
+	sw   *excelize.StreamWriter
+	l    labels.Labels
+	loc  *time.Location
+	mode model.ReportMode
+	row  int
+}
 
-	set := func(cell string, value interface{}) {
-		_ = file.SetCellValue(sheet, cell, value)
+// openDetail writes the group header and charge lines of a detail sheet and
+// leaves it ready for the trip rows.
+func (g *Generator) openDetail(file *excelize.File, sheet string, l labels.Labels, report model.ActReport, group model.TripGroup) (*detailWriter, error) {
+	sw, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, err
 	}
-
-	set("A1", "Тип отчета")
-	set("B1", modeLabel)
-	set("A2", "Организация")
-	set("B2", report.Target.Name)
-	set("A3", groupLabel)
-	set("B3", group.Name)
-	set("A4", "Начало периода")
-	set("B4", formatDate(report.PeriodStart))
-	set("A5", "Конец периода")
-	set("B5", formatDate(report.PeriodEnd))
-	set("A6", "Количество рейсов")
-	set("B6", group.TripCount)
-	set("A7", "Объем снега, м3")
-	set("B7", formatFloatValue(groupVolume, true))
-
-	tableRow := 9
-	headers := []string{"Дата", "Номер машины"}
-	if report.Mode == model.ReportModeContractor {
-		headers = append(headers, "Полигон")
+	for _, col := range []struct {
+		from, to int
+		width    float64
+	}{{1, 1, 20}, {2, 2, 16}, {3, 3, 32}, {4, 4, 14}, {5, 6, 18}} {
+		if err := sw.SetColWidth(col.from, col.to, col.width); err != nil {
+			return nil, err
+		}
 	}
-	if report.Mode == model.ReportModeLandfill {
-		headers = append(headers, "Подрядчик")
+
+	d := &detailWriter{sw: sw, l: l, loc: report.Location(), mode: report.Mode}
+	modeLabel, groupLabel := l.Mode(report.Mode)
+	rows := [][]interface{}{
+		{l.ReportType, modeLabel},
+		{l.Organization, report.Target.Name},
+		{groupLabel, group.Name},
+		{l.PeriodStart, l.FormatDate(report.PeriodStart)},
+		{l.PeriodEnd, l.FormatDate(report.PeriodEnd)},
+		{l.TripCount, group.TripCount},
+		{l.SnowVolume, formatFloatValue(group.VolumeM3, true)},
+		{l.NetAmount, formatMoney(group.NetAmount)},
+		{l.VAT(report.VATRate), formatMoney(group.VATAmount)},
+		{l.GrossAmount, formatMoney(group.GrossAmount)},
+		{l.UnpricedTrips, group.UnpricedTrips},
 	}
-	headers = append(headers, "Объем снега, м3")
-	for i, header := range headers {
-		cell, _ := excelize.CoordinatesToCellName(i+1, tableRow)
-		set(cell, header)
+	for _, values := range rows {
+		if err := d.write(values...); err != nil {
+			return nil, err
+		}
 	}
 
-	for i, trip := range group.Trips {
-		row := tableRow + 1 + i
-		set(fmt.Sprintf("A%d", row), formatDateTime(trip.EventTime))
-		set(fmt.Sprintf("B%d", row), formatString(trip.Plate))
-		if report.Mode == model.ReportModeContractor {
-			set(fmt.Sprintf("C%d", row), formatString(trip.PolygonName))
-			set(fmt.Sprintf("D%d", row), formatFloat(trip.SnowVolumeM3))
-			continue
+	d.row++
+	if err := d.write(l.TariffFrom, l.TariffTo, l.Unit, l.Price, l.Quantity, l.NetAmount); err != nil {
+		return nil, err
+	}
+	for _, line := range group.Charges {
+		if err := d.write(
+			l.FormatDate(line.FirstDate),
+			l.FormatDate(line.LastDate),
+			l.TariffUnit(line.Unit),
+			formatMoney(line.Price),
+			formatFloatValue(line.Quantity, true),
+			formatMoney(line.NetAmount),
+		); err != nil {
+			return nil, err
 		}
-		set(fmt.Sprintf("C%d", row), formatString(trip.ContractorName))
-		set(fmt.Sprintf("D%d", row), formatFloat(trip.SnowVolumeM3))
 	}
 
-	_ = file.SetColWidth(sheet, "A", "A", 20)
-	_ = file.SetColWidth(sheet, "B", "B", 16)
-	_ = file.SetColWidth(sheet, "C", "C", 32)
-	_ = file.SetColWidth(sheet, "D", "D", 14)
-	return nil
+	d.row++
+	headers := []interface{}{l.Date, l.Plate}
+	if report.Mode == model.ReportModeLandfill {
+		headers = append(headers, l.Contractor)
+	} else {
+		headers = append(headers, l.Landfill)
+	}
+	headers = append(headers, l.SnowVolume)
+	if err := d.write(headers...); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *detailWriter) writeTrip(trip model.TripDetail) error {
+	related := trip.PolygonName
+	if d.mode == model.ReportModeLandfill {
+		related = trip.ContractorName
+	}
+	return d.write(
+		d.l.FormatDateTime(trip.EventTime.In(d.loc)),
+		formatString(trip.Plate),
+		formatString(related),
+		formatFloat(trip.SnowVolumeM3),
+	)
 }
 
-func reportLabels(mode model.ReportMode) (string, string) {
-	switch mode {
-	case model.ReportModeLandfill:
-		return "Полигон", "Подрядчик"
-	case model.ReportModeContractor:
-		return "Подрядчик", "Полигон"
-	default:
-		return "Отчет", "Группа"
+func (d *detailWriter) write(values ...interface{}) error {
+	d.row++
+	cell, err := excelize.CoordinatesToCellName(1, d.row)
+	if err != nil {
+		return err
 	}
+	return d.sw.SetRow(cell, values)
+}
+
+func (d *detailWriter) flush() error {
+	return d.sw.Flush()
 }
 
-func buildSheetName(mode model.ReportMode, name string, id uuid.UUID, used map[string]struct{}) string {
-	_, groupLabel := reportLabels(mode)
+func buildSheetName(l labels.Labels, mode model.ReportMode, name string, id uuid.UUID, used map[string]struct{}) string {
+	_, groupLabel := l.Mode(mode)
 	base := fmt.Sprintf("%s - %s", groupLabel, strings.TrimSpace(name))
 	if base == strings.TrimSpace(groupLabel)+" -" || strings.TrimSpace(name) == "" {
 		base = fmt.Sprintf("%s - %s", groupLabel, id.String())
 	}
-	base = sanitizeSheetName(base)
-
-	if len(base) > 31 {
-		base = base[:31]
-	}
+	base = textutil.Truncate(sanitizeSheetName(base, l.Sheet), maxSheetName)
 
 	nameCandidate := base
 	counter := 2
@@ -171,19 +351,18 @@ func buildSheetName(mode model.ReportMode, name string, id uuid.UUID, used map[s
 			return nameCandidate
 		}
 		suffix := fmt.Sprintf("-%d", counter)
-		trimmed := base
-		if len(trimmed)+len(suffix) > 31 {
-			trimmed = trimmed[:31-len(suffix)]
-		}
-		nameCandidate = trimmed + suffix
+		nameCandidate = textutil.Truncate(base, maxSheetName-len(suffix)) + suffix
 		counter++
 	}
 }
 
-func sanitizeSheetName(value string) string {
+// maxSheetName is the longest sheet name Excel accepts, in characters.
+const maxSheetName = 31
+
+func sanitizeSheetName(value, fallback string) string {
 	value = strings.TrimSpace(value)
 	if value == "" {
-		return "Лист"
+		return fallback
 	}
 
 	replacer := strings.NewReplacer(
@@ -198,25 +377,11 @@ func sanitizeSheetName(value string) string {
 	value = replacer.Replace(value)
 	value = strings.TrimSpace(value)
 	if value == "" {
-		return "Лист"
+		return fallback
 	}
 	return value
 }
 
-func formatDate(t time.Time) string {
-	if t.IsZero() {
-		return ""
-	}
-	return t.Format("2006-01-02")
-}
-
-func formatDateTime(t time.Time) string {
-	if t.IsZero() {
-		return ""
-	}
-	return t.Format("2006-01-02 15:04:05")
-}
-
 func formatString(value *string) string {
 	if value == nil {
 		return ""
@@ -231,34 +396,13 @@ func formatFloat(value *float64) string {
 	return fmt.Sprintf("%.3f", *value)
 }
 
+func formatMoney(value float64) string {
+	return fmt.Sprintf("%.2f", value)
+}
+
 func formatFloatValue(value float64, ok bool) string {
 	if !ok {
 		return ""
 	}
 	return fmt.Sprintf("%.3f", value)
 }
-
-func sumGroupVolume(mode model.ReportMode, group model.TripGroup) float64 {
-	total := 0.0
-	for _, trip := range group.Trips {
-		if volume, ok := computeTripVolume(mode, trip); ok {
-			total += volume
-		}
-	}
-	return total
-}
-
-func sumReportVolume(report model.ActReport) float64 {
-	total := 0.0
-	for _, group := range report.Groups {
-		total += sumGroupVolume(report.Mode, group)
-	}
-	return total
-}
-
-func computeTripVolume(_ model.ReportMode, trip model.TripDetail) (float64, bool) {
-	if trip.SnowVolumeM3 == nil {
-		return 0, false
-	}
-	return *trip.SnowVolumeM3, true
-}
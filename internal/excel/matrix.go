@@ -0,0 +1,121 @@
+package excel
+
+import (
+	"github.com/xuri/excelize/v2"
+
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// writeMatrix fills the sheet of a city-wide act: the contractor × landfill
+// matrix of trips, volume and net amount with the subtotals of every
+// contractor and landfill and the grand totals, then the amounts of every
+// landfill.
+func (g *Generator) writeMatrix(file *excelize.File, sheet string, l labels.Labels, report model.ActReport) error {
+	matrix := report.Matrix
+	set := func(col, row int, value interface{}) {
+		cell, err := excelize.CoordinatesToCellName(col, row)
+		if err == nil {
+			_ = file.SetCellValue(sheet, cell, value)
+		}
+	}
+
+	var contractors []model.TripGroup
+	for _, group := range report.Groups {
+		if group.TripCount > 0 {
+			contractors = append(contractors, group)
+		}
+	}
+	_, groupLabel := l.Mode(report.Mode)
+	totalCol := len(matrix.Landfills) + 2
+
+	blocks := []struct {
+		caption string
+		cell    func(model.MatrixCell) interface{}
+		group   func(model.TripGroup) interface{}
+		column  func(model.MatrixTotal) interface{}
+		total   interface{}
+	}{
+		{
+			caption: l.TripCount,
+			cell:    func(c model.MatrixCell) interface{} { return c.TripCount },
+			group:   func(t model.TripGroup) interface{} { return t.TripCount },
+			column:  func(t model.MatrixTotal) interface{} { return t.TripCount },
+			total:   report.TotalTrips,
+		},
+		{
+			caption: l.SnowVolume,
+			cell:    func(c model.MatrixCell) interface{} { return formatFloatValue(c.VolumeM3, true) },
+			group:   func(t model.TripGroup) interface{} { return formatFloatValue(t.VolumeM3, true) },
+			column:  func(t model.MatrixTotal) interface{} { return formatFloatValue(t.VolumeM3, true) },
+			total:   formatFloatValue(report.TotalVolumeM3, true),
+		},
+		{
+			caption: l.NetAmount,
+			cell:    func(c model.MatrixCell) interface{} { return formatMoney(c.NetAmount) },
+			group:   func(t model.TripGroup) interface{} { return formatMoney(t.NetAmount) },
+			column:  func(t model.MatrixTotal) interface{} { return formatMoney(t.NetAmount) },
+			total:   formatMoney(report.NetAmount),
+		},
+	}
+
+	row := 1
+	for _, block := range blocks {
+		set(1, row, block.caption)
+		row++
+		set(1, row, groupLabel+" / "+l.Landfill)
+		for i, landfill := range matrix.Landfills {
+			set(i+2, row, landfill.Name)
+		}
+		set(totalCol, row, l.Total)
+		row++
+
+		for _, contractor := range contractors {
+			set(1, row, contractor.Name)
+			for i, landfill := range matrix.Landfills {
+				cell := matrix.Cell(contractor.ID, landfill.ID)
+				if cell.TripCount > 0 {
+					set(i+2, row, block.cell(cell))
+				}
+			}
+			set(totalCol, row, block.group(contractor))
+			row++
+		}
+
+		set(1, row, l.Total)
+		for i, landfill := range matrix.Landfills {
+			set(i+2, row, block.column(landfill))
+		}
+		set(totalCol, row, block.total)
+		row += 2
+	}
+
+	headers := []string{l.Landfill, l.TripCount, l.SnowVolume, l.NetAmount, l.VATAmount, l.GrossAmount}
+	for i, header := range headers {
+		set(i+1, row, header)
+	}
+	row++
+	for _, landfill := range matrix.Landfills {
+		set(1, row, landfill.Name)
+		set(2, row, landfill.TripCount)
+		set(3, row, formatFloatValue(landfill.VolumeM3, true))
+		set(4, row, formatMoney(landfill.NetAmount))
+		set(5, row, formatMoney(landfill.VATAmount))
+		set(6, row, formatMoney(landfill.GrossAmount))
+		row++
+	}
+	set(1, row, l.Total)
+	set(2, row, report.TotalTrips)
+	set(3, row, formatFloatValue(report.TotalVolumeM3, true))
+	set(4, row, formatMoney(report.NetAmount))
+	set(5, row, formatMoney(report.VATAmount))
+	set(6, row, formatMoney(report.GrossAmount))
+
+	lastCol, err := excelize.ColumnNumberToName(max(totalCol, len(headers)))
+	if err != nil {
+		return err
+	}
+	_ = file.SetColWidth(sheet, "A", "A", 45)
+	_ = file.SetColWidth(sheet, "B", lastCol, 18)
+	return nil
+}
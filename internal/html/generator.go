@@ -0,0 +1,255 @@
+// Package html renders acts as standalone HTML pages for viewing and printing
+// in a browser.
+package html
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+type Generator struct{}
+
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+func (g *Generator) Generate(report model.ActReport) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := g.Write(&buf, report, report.EachTrip); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Write streams the page to w: the summary first, then one section per group
+// with its trips as they are read from trips. Every group section starts on a
+// new printed page.
+func (g *Generator) Write(w io.Writer, report model.ActReport, trips model.TripSource) error {
+	out := bufio.NewWriter(w)
+	l := labels.For(report.Lang)
+	loc := report.Location()
+	modeLabel, groupLabel := l.Mode(report.Mode)
+	relatedLabel := l.Landfill
+	if report.Mode == model.ReportModeLandfill {
+		relatedLabel = l.Contractor
+	}
+	page := pageData{
+		L:            l,
+		Report:       report,
+		ModeLabel:    modeLabel,
+		GroupLabel:   groupLabel,
+		RelatedLabel: relatedLabel,
+	}
+
+	if err := pageTemplate.ExecuteTemplate(out, "head", page); err != nil {
+		return err
+	}
+
+	next := 0
+	open := false
+	openUntil := func(groupIndex int) error {
+		for next <= groupIndex && next < len(report.Groups) {
+			if open {
+				if err := pageTemplate.ExecuteTemplate(out, "groupEnd", page); err != nil {
+					return err
+				}
+				open = false
+			}
+			page.Group = report.Groups[next]
+			if report.Shown(page.Group) {
+				if err := pageTemplate.ExecuteTemplate(out, "groupStart", page); err != nil {
+					return err
+				}
+				open = true
+			}
+			next++
+		}
+		return nil
+	}
+
+	err := trips(func(groupIndex int, trip model.TripDetail) error {
+		if groupIndex < 0 || groupIndex >= len(report.Groups) {
+			return nil
+		}
+		if err := openUntil(groupIndex); err != nil {
+			return err
+		}
+		if groupIndex != next-1 {
+			return fmt.Errorf("trips of group %d arrived out of order", groupIndex)
+		}
+		if !open {
+			return nil
+		}
+		return pageTemplate.ExecuteTemplate(out, "trip", tripData{
+			Time:    l.FormatDateTime(trip.EventTime.In(loc)),
+			Plate:   formatString(trip.Plate),
+			Related: formatString(relatedName(report.Mode, trip)),
+			Volume:  formatFloat(trip.SnowVolumeM3),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if err := openUntil(len(report.Groups) - 1); err != nil {
+		return err
+	}
+	if open {
+		if err := pageTemplate.ExecuteTemplate(out, "groupEnd", page); err != nil {
+			return err
+		}
+	}
+	if err := pageTemplate.ExecuteTemplate(out, "foot", page); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+type pageData struct {
+	L            labels.Labels
+	Report       model.ActReport
+	ModeLabel    string
+	GroupLabel   string
+	RelatedLabel string
+	Group        model.TripGroup
+}
+
+type tripData struct {
+	Time    string
+	Plate   string
+	Related string
+	Volume  string
+}
+
+var pageTemplate = template.Must(template.New("act").Funcs(template.FuncMap{
+	"money":  formatMoney,
+	"volume": formatVolume,
+	"join":   strings.Join,
+	"vat":    func(l labels.Labels, rate float64) string { return l.VAT(rate) },
+	"unit":   func(l labels.Labels, unit model.TariffUnit) string { return l.TariffUnit(unit) },
+}).Parse(pageSource))
+
+const pageSource = `
+{{define "head"}}<!DOCTYPE html>
+<html lang="{{.L.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>{{.L.ActNumber}} {{.Report.Number}}</title>
+<style>
+@page { size: A4; margin: 12mm; }
+body { font-family: "Arial", "Helvetica", sans-serif; font-size: 11pt; color: #000; margin: 0 auto; max-width: 190mm; }
+h1 { font-size: 15pt; margin: 0 0 4mm; }
+h2 { font-size: 13pt; margin: 0 0 3mm; }
+table { width: 100%; border-collapse: collapse; margin: 0 0 5mm; }
+th, td { border: 1px solid #555; padding: 1.5mm 2mm; text-align: left; vertical-align: top; }
+th { background: #eee; }
+td.num, th.num { text-align: right; white-space: nowrap; }
+table.fields th { width: 45%; }
+thead { display: table-header-group; }
+tr { break-inside: avoid; page-break-inside: avoid; }
+.reprint { float: right; border: 2px solid #900; color: #900; padding: 1mm 3mm; font-weight: bold; }
+section.group { break-before: page; page-break-before: always; }
+@media screen {
+  body { padding: 10mm; }
+  section.group { border-top: 1px dashed #999; margin-top: 10mm; padding-top: 5mm; }
+}
+</style>
+</head>
+<body>
+<section class="summary">
+{{if .Report.Reprint}}<div class="reprint">{{.L.Reprint}}</div>{{end}}
+<h1>{{.L.Summary}}</h1>
+<table class="fields">
+<tr><th>{{.L.ActNumber}}</th><td>{{.Report.Number}}</td></tr>
+<tr><th>{{.L.ReportType}}</th><td>{{.ModeLabel}}</td></tr>
+<tr><th>{{.L.Organization}}</th><td>{{.Report.Target.Name}}</td></tr>
+<tr><th>{{.L.PeriodStart}}</th><td>{{.L.FormatDate .Report.PeriodStart}}</td></tr>
+<tr><th>{{.L.PeriodEnd}}</th><td>{{.L.FormatDate .Report.PeriodEnd}}</td></tr>
+<tr><th>{{.L.TripCount}}</th><td>{{.Report.TotalTrips}}</td></tr>
+<tr><th>{{.L.SnowVolume}}</th><td>{{volume .Report.TotalVolumeM3}}</td></tr>
+<tr><th>{{.L.NetAmount}}</th><td>{{money .Report.NetAmount}}</td></tr>
+<tr><th>{{vat .L .Report.VATRate}}</th><td>{{money .Report.VATAmount}}</td></tr>
+<tr><th>{{.L.GrossAmount}}</th><td>{{money .Report.GrossAmount}}</td></tr>
+<tr><th>{{.L.Statuses}}</th><td>{{join .Report.Statuses ", "}}</td></tr>
+</table>
+<table>
+<thead><tr><th>{{.GroupLabel}}</th><th class="num">{{.L.TripCount}}</th><th class="num">{{.L.SnowVolume}}</th><th class="num">{{.L.NetAmount}}</th><th class="num">{{.L.VATAmount}}</th><th class="num">{{.L.GrossAmount}}</th></tr></thead>
+<tbody>
+{{range .Report.Groups}}{{if $.Report.Shown .}}<tr><td>{{.Name}}</td><td class="num">{{.TripCount}}</td><td class="num">{{volume .VolumeM3}}</td><td class="num">{{money .NetAmount}}</td><td class="num">{{money .VATAmount}}</td><td class="num">{{money .GrossAmount}}</td></tr>
+{{end}}{{end}}</tbody>
+</table>
+</section>
+{{end}}
+
+{{define "groupStart"}}<section class="group">
+<h2>{{.GroupLabel}}: {{.Group.Name}}</h2>
+<table class="fields">
+<tr><th>{{.L.ReportType}}</th><td>{{.ModeLabel}}</td></tr>
+<tr><th>{{.L.Organization}}</th><td>{{.Report.Target.Name}}</td></tr>
+<tr><th>{{.L.PeriodStart}}</th><td>{{.L.FormatDate .Report.PeriodStart}}</td></tr>
+<tr><th>{{.L.PeriodEnd}}</th><td>{{.L.FormatDate .Report.PeriodEnd}}</td></tr>
+<tr><th>{{.L.TripCount}}</th><td>{{.Group.TripCount}}</td></tr>
+<tr><th>{{.L.SnowVolume}}</th><td>{{volume .Group.VolumeM3}}</td></tr>
+<tr><th>{{.L.NetAmount}}</th><td>{{money .Group.NetAmount}}</td></tr>
+<tr><th>{{vat .L .Report.VATRate}}</th><td>{{money .Group.VATAmount}}</td></tr>
+<tr><th>{{.L.GrossAmount}}</th><td>{{money .Group.GrossAmount}}</td></tr>
+<tr><th>{{.L.UnpricedTrips}}</th><td>{{.Group.UnpricedTrips}}</td></tr>
+</table>
+{{if .Group.Charges}}<table>
+<thead><tr><th>{{.L.TariffFrom}}</th><th>{{.L.TariffTo}}</th><th>{{.L.Unit}}</th><th class="num">{{.L.Price}}</th><th class="num">{{.L.Quantity}}</th><th class="num">{{.L.NetAmount}}</th></tr></thead>
+<tbody>
+{{$l := .L}}{{range .Group.Charges}}<tr><td>{{$l.FormatDate .FirstDate}}</td><td>{{$l.FormatDate .LastDate}}</td><td>{{unit $l .Unit}}</td><td class="num">{{money .Price}}</td><td class="num">{{volume .Quantity}}</td><td class="num">{{money .NetAmount}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}<table>
+<thead><tr><th>{{.L.Date}}</th><th>{{.L.Plate}}</th><th>{{.RelatedLabel}}</th><th class="num">{{.L.SnowVolume}}</th></tr></thead>
+<tbody>
+{{end}}
+
+{{define "trip"}}<tr><td>{{.Time}}</td><td>{{.Plate}}</td><td>{{.Related}}</td><td class="num">{{.Volume}}</td></tr>
+{{end}}
+
+{{define "groupEnd"}}</tbody>
+</table>
+</section>
+{{end}}
+
+{{define "foot"}}</body>
+</html>
+{{end}}
+`
+
+func relatedName(mode model.ReportMode, trip model.TripDetail) *string {
+	if mode == model.ReportModeLandfill {
+		return trip.ContractorName
+	}
+	return trip.PolygonName
+}
+
+func formatString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func formatFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return formatVolume(*value)
+}
+
+func formatVolume(value float64) string {
+	return fmt.Sprintf("%.3f", value)
+}
+
+func formatMoney(value float64) string {
+	return fmt.Sprintf("%.2f", value)
+}
@@ -0,0 +1,545 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-acts/internal/http/middleware"
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/service"
+)
+
+type cancelActRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type actTransition func(ctx context.Context, id uuid.UUID, principal model.Principal) (*model.Act, error)
+
+func (h *Handler) listActs(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	filter := model.ActFilter{
+		Status: model.ActStatus(strings.ToUpper(strings.TrimSpace(c.Query("status")))),
+	}
+	if raw := c.Query("mode"); raw != "" {
+		mode, err := parseReportMode(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+			return
+		}
+		filter.Mode = mode
+	}
+	if raw := c.Query("target_id"); raw != "" {
+		targetID, err := uuid.Parse(strings.TrimSpace(raw))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_id"})
+			return
+		}
+		filter.TargetID = targetID
+	}
+
+	acts, err := h.acts.ListActs(c.Request.Context(), filter, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": acts})
+}
+
+func (h *Handler) getAct(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	auditAct(c, id)
+	act, err := h.acts.GetAct(c.Request.Context(), id, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": act})
+}
+
+func (h *Handler) createAct(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	input, ok := bindExportRequest(c, h.acts.Location())
+	if !ok {
+		return
+	}
+	input.Principal = principal
+
+	act, err := h.acts.CreateDraft(c.Request.Context(), input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": act})
+}
+
+// previewActs returns what POST /acts/export would put into the act as JSON,
+// without issuing it. Trips are included when page_size is given.
+func (h *Handler) previewActs(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	var page service.PreviewPage
+	if raw := c.Query("page_size"); raw != "" {
+		size, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || size <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page_size"})
+			return
+		}
+		page.PageSize = size
+		page.Page = 1
+	}
+	if raw := c.Query("page"); raw != "" {
+		number, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || number <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page"})
+			return
+		}
+		page.Page = number
+	}
+
+	input, ok := bindExportRequest(c, h.acts.Location())
+	if !ok {
+		return
+	}
+	input.Principal = principal
+
+	preview, err := h.acts.PreviewReport(c.Request.Context(), input, page)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": preview})
+}
+
+// exportTrips streams the trips of an act as CSV (the default) or JSON Lines,
+// negotiated like an act export. ?delimiter=semicolon switches CSV to ';'.
+func (h *Handler) exportTrips(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	var req exportActsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input, ok := parseExportRequest(c, req, h.acts.Location())
+	if !ok {
+		return
+	}
+	input.Principal = principal
+
+	format, ok := h.negotiateFormat(c, req.Format, h.acts.NegotiateTripsFormat)
+	if !ok {
+		return
+	}
+	var opts service.TripExportOptions
+	switch strings.ToLower(strings.TrimSpace(c.Query("delimiter"))) {
+	case "", "comma", ",":
+	case "semicolon", ";":
+		opts.Delimiter = ';'
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delimiter"})
+		return
+	}
+
+	result, err := h.acts.ExportTrips(c.Request.Context(), input, format, opts)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	h.sendDocument(c, result)
+}
+
+type exportBatchRequest struct {
+	Mode string `json:"mode" binding:"required"`
+	// TargetIDs lists contractors or landfills; all of them when empty.
+	TargetIDs   []string `json:"target_ids"`
+	PeriodStart string   `json:"period_start" binding:"required"`
+	PeriodEnd   string   `json:"period_end" binding:"required"`
+	// Formats names the document formats of every act, e.g. ["xlsx", "pdf"].
+	Formats         []string `json:"formats"`
+	Lang            string   `json:"lang"`
+	HideEmptyGroups bool     `json:"hide_empty_groups"`
+}
+
+// exportBatch issues the acts of several contractors or landfills for one
+// period and returns them as a ZIP archive with a manifest.
+func (h *Handler) exportBatch(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	var req exportBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mode, err := parseReportMode(req.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+		return
+	}
+	targetIDs := make([]uuid.UUID, 0, len(req.TargetIDs))
+	for _, raw := range req.TargetIDs {
+		id, err := uuid.Parse(strings.TrimSpace(raw))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_ids"})
+			return
+		}
+		targetIDs = append(targetIDs, id)
+	}
+	start, err := parseDate(req.PeriodStart, h.acts.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_start"})
+		return
+	}
+	end, err := parseDate(req.PeriodEnd, h.acts.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_end"})
+		return
+	}
+	formats := make([]string, 0, len(req.Formats))
+	for _, format := range req.Formats {
+		formats = append(formats, strings.ToLower(strings.TrimSpace(format)))
+	}
+	// A batch of one target is recorded with it; larger batches are only
+	// open to Akimat and KGU.
+	requested := service.GenerateReportInput{Mode: mode, PeriodStart: start, PeriodEnd: end}
+	if len(targetIDs) == 1 {
+		requested.TargetID = targetIDs[0]
+	}
+	auditRequest(c, requested)
+	auditFormat(c, strings.Join(formats, ","))
+
+	result, err := h.acts.ExportBatch(c.Request.Context(), service.BatchExportInput{
+		Mode:            mode,
+		TargetIDs:       targetIDs,
+		PeriodStart:     start,
+		PeriodEnd:       end,
+		Formats:         formats,
+		Lang:            requestLang(c, req.Lang),
+		HideEmptyGroups: req.HideEmptyGroups,
+		Principal:       principal,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	h.sendDocument(c, result)
+}
+
+func (h *Handler) issueAct(c *gin.Context) {
+	h.transitionAct(c, h.acts.IssueAct)
+}
+
+func (h *Handler) signAct(c *gin.Context) {
+	h.transitionAct(c, h.acts.SignAct)
+}
+
+func (h *Handler) approveAct(c *gin.Context) {
+	h.transitionAct(c, h.acts.ApproveAct)
+}
+
+func (h *Handler) cancelAct(c *gin.Context) {
+	var req cancelActRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.transitionAct(c, func(ctx context.Context, id uuid.UUID, principal model.Principal) (*model.Act, error) {
+		return h.acts.CancelAct(ctx, id, req.Reason, principal)
+	})
+}
+
+// snapshotDocument returns a handler rendering the act in the path from its
+// snapshot: marked as a reprint, or as it was issued.
+func (h *Handler) snapshotDocument(reprint bool) gin.HandlerFunc {
+	render := h.acts.ActDocument
+	if reprint {
+		render = h.acts.ReprintAct
+	}
+	return func(c *gin.Context) {
+		principal, ok := middleware.MustPrincipal(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+			return
+		}
+
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+
+		auditAct(c, id)
+		format := strings.ToLower(strings.TrimSpace(c.Query("format")))
+		auditFormat(c, format)
+		lang := strings.ToLower(strings.TrimSpace(c.Query("lang")))
+		result, err := render(c.Request.Context(), id, format, lang, principal)
+		if err != nil {
+			h.handleError(c, err)
+			return
+		}
+
+		h.sendDocument(c, result)
+	}
+}
+
+// createCorrectiveAct issues a corrective act for the act in the path and
+// returns its document.
+func (h *Handler) createCorrectiveAct(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	auditAct(c, id)
+	format := strings.ToLower(strings.TrimSpace(c.Query("format")))
+	auditFormat(c, format)
+	lang := requestLang(c, c.Query("lang"))
+	result, err := h.acts.CreateCorrectiveAct(c.Request.Context(), id, format, lang, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	h.sendDocument(c, result)
+}
+
+func (h *Handler) listDrift(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	onlyDrift := c.Query("all") != "true"
+	reports, err := h.acts.ListDrift(c.Request.Context(), onlyDrift, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": reports})
+}
+
+func (h *Handler) checkDrift(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	report, err := h.acts.CheckDrift(c.Request.Context(), id, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+func (h *Handler) exportDrift(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	auditAct(c, id)
+	lang := requestLang(c, c.Query("lang"))
+	result, err := h.acts.ExportDrift(c.Request.Context(), id, lang, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	h.sendDocument(c, result)
+}
+
+func (h *Handler) transitionAct(c *gin.Context, apply actTransition) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	act, err := apply(c.Request.Context(), id, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": act})
+}
+
+// bindExportRequest parses the common act request body (mode, target and
+// period, with dates in loc). On failure it writes the 400 response itself.
+func bindExportRequest(c *gin.Context, loc *time.Location) (service.GenerateReportInput, bool) {
+	var req exportActsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return service.GenerateReportInput{}, false
+	}
+	return parseExportRequest(c, req, loc)
+}
+
+// parseExportRequest validates an already bound act request body. On failure
+// it writes the 400 response itself.
+func parseExportRequest(c *gin.Context, req exportActsRequest, loc *time.Location) (service.GenerateReportInput, bool) {
+	mode, err := parseReportMode(req.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+		return service.GenerateReportInput{}, false
+	}
+
+	var targetID uuid.UUID
+	if rawTarget := strings.TrimSpace(req.TargetID); rawTarget != "" || mode != model.ReportModeCity {
+		targetID, err = uuid.Parse(rawTarget)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_id"})
+			return service.GenerateReportInput{}, false
+		}
+	}
+
+	var landfillID uuid.UUID
+	if rawLandfill := strings.TrimSpace(req.LandfillID); rawLandfill != "" || mode == model.ReportModePair {
+		landfillID, err = uuid.Parse(rawLandfill)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid landfill_id"})
+			return service.GenerateReportInput{}, false
+		}
+	}
+
+	start, err := parseDate(req.PeriodStart, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_start"})
+		return service.GenerateReportInput{}, false
+	}
+
+	end, err := parseDate(req.PeriodEnd, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_end"})
+		return service.GenerateReportInput{}, false
+	}
+
+	input := service.GenerateReportInput{
+		Mode:            mode,
+		TargetID:        targetID,
+		LandfillID:      landfillID,
+		PeriodStart:     start,
+		PeriodEnd:       end,
+		Lang:            requestLang(c, req.Lang),
+		HideEmptyGroups: req.HideEmptyGroups,
+	}
+	auditRequest(c, input)
+	return input, true
+}
+
+// requestLang returns the document language named in the request or, when
+// none is named, the one the Accept-Language header prefers.
+func requestLang(c *gin.Context, lang string) string {
+	if lang = strings.ToLower(strings.TrimSpace(lang)); lang != "" {
+		return lang
+	}
+	if accept := c.GetHeader("Accept-Language"); accept != "" {
+		return labels.Negotiate(accept)
+	}
+	return ""
+}
+
+// sendDocument sends a generated document as an attachment. Streamed results
+// are written straight into the response; if streaming fails before the
+// first byte is sent, the error is returned as usual.
+func (h *Handler) sendDocument(c *gin.Context, result *service.GenerateReportResult) {
+	contentType := result.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := "attachment"
+	if result.Inline {
+		disposition = "inline"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", disposition+"; filename=\""+result.FileName+"\"")
+	w, audited := auditDocument(c, c.Writer)
+	if result.Stream == nil {
+		c.Status(http.StatusOK)
+		_, err := w.Write(result.Content)
+		audited(err != nil)
+		return
+	}
+
+	c.Status(http.StatusOK)
+	err := result.Stream(w)
+	audited(err != nil)
+	if err != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			h.handleError(c, err)
+			return
+		}
+		h.log.Error().Err(err).Str("path", c.FullPath()).Msg("document stream interrupted")
+	}
+}
@@ -0,0 +1,264 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-acts/internal/http/middleware"
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/service"
+)
+
+// auditKey holds the *model.AuditEntry of an audited request; handlers fill
+// in what they learn about the request as they parse it.
+const auditKey = "audit"
+
+// audited records every request to next in the act audit log, with its
+// outcome and duration, after the response is written.
+func (h *Handler) audited(action string, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		entry := &model.AuditEntry{Action: action, CreatedAt: start}
+		c.Set(auditKey, entry)
+
+		next(c)
+
+		principal, ok := middleware.MustPrincipal(c)
+		if !ok {
+			return
+		}
+		entry.UserID = principal.UserID
+		entry.OrgID = principal.OrgID
+		entry.Role = principal.Role
+		entry.StatusCode = c.Writer.Status()
+		entry.DurationMs = time.Since(start).Milliseconds()
+		entry.ClientIP = c.ClientIP()
+		if entry.Outcome == "" {
+			entry.Outcome = auditOutcome(entry.StatusCode)
+		}
+
+		// The client may be gone; the attempt is recorded anyway.
+		ctx := context.WithoutCancel(c.Request.Context())
+		if err := h.audit.Record(ctx, entry); err != nil {
+			h.log.Error().Err(err).Str("path", c.FullPath()).Msg("record act audit failed")
+		}
+		if entry.Outcome == model.AuditPermissionDenied {
+			event := h.log.Warn().
+				Str("action", action).
+				Str("user_id", principal.UserID.String()).
+				Str("org_id", principal.OrgID.String()).
+				Str("role", string(principal.Role)).
+				Bool("foreign_target", entry.ForeignTarget)
+			if entry.TargetID != nil {
+				event = event.Str("target_id", entry.TargetID.String())
+			}
+			if entry.ActID != nil {
+				event = event.Str("act_id", entry.ActID.String())
+			}
+			event.Msg("act access denied")
+		}
+	}
+}
+
+func auditOutcome(status int) model.AuditOutcome {
+	switch {
+	case status >= 200 && status < 300:
+		return model.AuditSuccess
+	case status == http.StatusBadRequest || status == http.StatusNotAcceptable:
+		return model.AuditInvalidInput
+	case status == http.StatusForbidden:
+		return model.AuditPermissionDenied
+	case status == http.StatusNotFound:
+		return model.AuditNotFound
+	case status == http.StatusConflict:
+		return model.AuditConflict
+	default:
+		return model.AuditError
+	}
+}
+
+// auditEntry returns the audit entry of the request, nil when it is not
+// audited.
+func auditEntry(c *gin.Context) *model.AuditEntry {
+	value, ok := c.Get(auditKey)
+	if !ok {
+		return nil
+	}
+	entry, _ := value.(*model.AuditEntry)
+	return entry
+}
+
+// auditRequest records the act asked for by a request body.
+func auditRequest(c *gin.Context, input service.GenerateReportInput) {
+	entry := auditEntry(c)
+	if entry == nil {
+		return
+	}
+	entry.Mode = input.Mode
+	if input.TargetID != uuid.Nil {
+		entry.TargetID = &input.TargetID
+	}
+	if input.LandfillID != uuid.Nil {
+		entry.LandfillID = &input.LandfillID
+	}
+	entry.PeriodStart = &input.PeriodStart
+	entry.PeriodEnd = &input.PeriodEnd
+}
+
+// auditAct records the act a request is about.
+func auditAct(c *gin.Context, id uuid.UUID) {
+	if entry := auditEntry(c); entry != nil {
+		entry.ActID = &id
+	}
+}
+
+// auditJob records the export job a request is about.
+func auditJob(c *gin.Context, id uuid.UUID) {
+	if entry := auditEntry(c); entry != nil {
+		entry.JobID = &id
+	}
+}
+
+func auditFormat(c *gin.Context, format string) {
+	if entry := auditEntry(c); entry != nil {
+		entry.Format = format
+	}
+}
+
+// auditWriter counts and hashes the bytes of a document as it is sent.
+type auditWriter struct {
+	w    io.Writer
+	hash hash.Hash
+	size int64
+}
+
+func (w *auditWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.hash.Write(p[:n])
+	w.size += int64(n)
+	return n, err
+}
+
+// auditDocument wraps the writer a document is sent to so the size and
+// checksum of the document are recorded. It returns w itself when the
+// request is not audited.
+func auditDocument(c *gin.Context, w io.Writer) (io.Writer, func(failed bool)) {
+	entry := auditEntry(c)
+	if entry == nil {
+		return w, func(bool) {}
+	}
+	aw := &auditWriter{w: w, hash: sha256.New()}
+	return aw, func(failed bool) {
+		entry.SizeBytes = aw.size
+		entry.Checksum = hex.EncodeToString(aw.hash.Sum(nil))
+		if failed {
+			entry.Outcome = model.AuditError
+		}
+	}
+}
+
+// listAudit returns the act audit log as JSON or, with ?format=csv, as a CSV
+// file of every matching entry.
+func (h *Handler) listAudit(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	filter := model.AuditFilter{
+		Role:    model.UserRole(strings.ToUpper(strings.TrimSpace(c.Query("role")))),
+		Action:  strings.ToLower(strings.TrimSpace(c.Query("action"))),
+		Outcome: model.AuditOutcome(strings.ToUpper(strings.TrimSpace(c.Query("outcome")))),
+	}
+	ids := []struct {
+		name string
+		dst  *uuid.UUID
+	}{
+		{"user_id", &filter.UserID},
+		{"org_id", &filter.OrgID},
+		{"target_id", &filter.TargetID},
+		{"act_id", &filter.ActID},
+	}
+	for _, param := range ids {
+		if raw := strings.TrimSpace(c.Query(param.name)); raw != "" {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param.name})
+				return
+			}
+			*param.dst = id
+		}
+	}
+	if raw := strings.TrimSpace(c.Query("foreign_target")); raw != "" {
+		foreign, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid foreign_target"})
+			return
+		}
+		filter.ForeignTarget = &foreign
+	}
+	loc := h.acts.Location()
+	if raw := c.Query("from"); raw != "" {
+		from, err := parseDate(raw, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+			return
+		}
+		filter.From = from
+	}
+	if raw := c.Query("to"); raw != "" {
+		to, err := parseDate(raw, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+			return
+		}
+		// to is inclusive: the whole day is covered.
+		filter.To = time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, loc)
+	}
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		filter.Limit = limit
+	}
+	if raw := strings.TrimSpace(c.Query("offset")); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+			return
+		}
+		filter.Offset = offset
+	}
+
+	switch strings.ToLower(strings.TrimSpace(c.Query("format"))) {
+	case "", "json":
+		entries, err := h.audit.ListAudit(c.Request.Context(), filter, principal)
+		if err != nil {
+			h.handleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": entries})
+	case "csv":
+		result, err := h.audit.ExportAuditCSV(c.Request.Context(), filter, principal)
+		if err != nil {
+			h.handleError(c, err)
+			return
+		}
+		h.sendDocument(c, result)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format"})
+	}
+}
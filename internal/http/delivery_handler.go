@@ -0,0 +1,139 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-acts/internal/http/middleware"
+	"github.com/nurpe/snowops-acts/internal/service"
+)
+
+type recipientRequest struct {
+	Email string `json:"email" binding:"required"`
+	// Lang is the language of the emails, "ru" (the default) or "kk".
+	Lang string `json:"lang"`
+}
+
+// sendAct queues the emails of an act to the recipients of its
+// organizations.
+func (h *Handler) sendAct(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	deliveries, err := h.deliveries.SendAct(c.Request.Context(), id, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"data": deliveries})
+}
+
+func (h *Handler) listDeliveries(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	deliveries, err := h.deliveries.ListDeliveries(c.Request.Context(), id, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": deliveries})
+}
+
+func (h *Handler) listRecipients(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	recipients, err := h.deliveries.ListRecipients(c.Request.Context(), orgID, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": recipients})
+}
+
+func (h *Handler) createRecipient(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req recipientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recipient, err := h.deliveries.AddRecipient(c.Request.Context(), service.RecipientInput{
+		OrganizationID: orgID,
+		Email:          req.Email,
+		Lang:           req.Lang,
+		Principal:      principal,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": recipient})
+}
+
+func (h *Handler) deleteRecipient(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	id, err := uuid.Parse(c.Param("recipient_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipient_id"})
+		return
+	}
+
+	if err := h.deliveries.DeleteRecipient(c.Request.Context(), orgID, id, principal); err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
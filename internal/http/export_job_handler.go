@@ -0,0 +1,87 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-acts/internal/http/middleware"
+	"github.com/nurpe/snowops-acts/internal/service"
+)
+
+func (h *Handler) createExportJob(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	var req exportActsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input, ok := parseExportRequest(c, req, h.acts.Location())
+	if !ok {
+		return
+	}
+	input.Principal = principal
+
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	if format == "" {
+		format = service.FormatXLSX
+	}
+	auditFormat(c, format)
+	job, err := h.jobs.Enqueue(c.Request.Context(), input, format)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"data": job})
+}
+
+func (h *Handler) getExportJob(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	job, err := h.jobs.Get(c.Request.Context(), id, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+func (h *Handler) downloadExportJob(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	auditJob(c, id)
+	result, err := h.jobs.File(c.Request.Context(), id, principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	h.sendDocument(c, result)
+}
@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 
 	"github.com/nurpe/snowops-acts/internal/http/middleware"
@@ -16,83 +15,119 @@ import (
 )
 
 type Handler struct {
-	acts *service.ActService
-	log  zerolog.Logger
+	acts       *service.ActService
+	cameras    *service.LandfillCameraService
+	tariffs    *service.TariffService
+	jobs       *service.ExportJobService
+	deliveries *service.DeliveryService
+	webhooks   *service.WebhookService
+	audit      *service.AuditService
+	log        zerolog.Logger
 }
 
-func NewHandler(acts *service.ActService, log zerolog.Logger) *Handler {
-	return &Handler{acts: acts, log: log}
+func NewHandler(
+	acts *service.ActService,
+	cameras *service.LandfillCameraService,
+	tariffs *service.TariffService,
+	jobs *service.ExportJobService,
+	deliveries *service.DeliveryService,
+	webhooks *service.WebhookService,
+	audit *service.AuditService,
+	log zerolog.Logger,
+) *Handler {
+	return &Handler{
+		acts:       acts,
+		cameras:    cameras,
+		tariffs:    tariffs,
+		jobs:       jobs,
+		deliveries: deliveries,
+		webhooks:   webhooks,
+		audit:      audit,
+		log:        log,
+	}
 }
 
 func (h *Handler) Register(router *gin.Engine, authMiddleware gin.HandlerFunc) {
 	protected := router.Group("/")
 	protected.Use(authMiddleware)
-	protected.POST("/acts/export", h.exportActs)
-	protected.POST("/acts/export/pdf", h.exportActsPDF)
+	protected.POST("/acts/export", h.audited(model.AuditActionExport, h.exportActs))
+	protected.POST("/acts/export/pdf", h.audited(model.AuditActionExport, h.exportActsAs(service.FormatPDF)))
+	protected.POST("/acts/export/completed-works", h.audited(model.AuditActionExport, h.exportActsAs(service.FormatCompletedWorksPDF)))
+	protected.POST("/acts/export/trips", h.audited(model.AuditActionExportTrips, h.exportTrips))
+	protected.POST("/acts/export/batch", h.audited(model.AuditActionExportBatch, h.exportBatch))
+	protected.POST("/acts/preview", h.audited(model.AuditActionPreview, h.previewActs))
+
+	protected.GET("/acts", h.listActs)
+	protected.POST("/acts", h.createAct)
+	protected.GET("/acts/drift", h.listDrift)
+	protected.GET("/acts/audit", h.listAudit)
+	protected.POST("/acts/jobs", h.audited(model.AuditActionExportJob, h.createExportJob))
+	protected.GET("/acts/jobs/:id", h.getExportJob)
+	protected.GET("/acts/jobs/:id/file", h.audited(model.AuditActionJobDownload, h.downloadExportJob))
+	protected.GET("/acts/:id", h.audited(model.AuditActionView, h.getAct))
+	protected.GET("/acts/:id/reprint", h.audited(model.AuditActionReprint, h.snapshotDocument(true)))
+	protected.GET("/acts/:id/document", h.audited(model.AuditActionDocument, h.snapshotDocument(false)))
+	protected.POST("/acts/:id/drift", h.checkDrift)
+	protected.POST("/acts/:id/drift/export", h.audited(model.AuditActionDriftExport, h.exportDrift))
+	protected.POST("/acts/:id/corrective", h.audited(model.AuditActionCorrective, h.createCorrectiveAct))
+	protected.POST("/acts/:id/issue", h.issueAct)
+	protected.POST("/acts/:id/sign", h.signAct)
+	protected.POST("/acts/:id/approve", h.approveAct)
+	protected.POST("/acts/:id/cancel", h.cancelAct)
+	protected.POST("/acts/:id/send", h.sendAct)
+	protected.GET("/acts/:id/deliveries", h.listDeliveries)
+
+	protected.GET("/organizations/:id/recipients", h.listRecipients)
+	protected.POST("/organizations/:id/recipients", h.createRecipient)
+	protected.DELETE("/organizations/:id/recipients/:recipient_id", h.deleteRecipient)
+
+	protected.GET("/webhooks", h.listWebhooks)
+	protected.POST("/webhooks", h.createWebhook)
+	protected.GET("/webhooks/:id", h.getWebhook)
+	protected.PUT("/webhooks/:id", h.updateWebhook)
+	protected.DELETE("/webhooks/:id", h.deleteWebhook)
+	protected.GET("/webhooks/:id/deliveries", h.listWebhookDeliveries)
+
+	protected.GET("/landfill-cameras", h.listLandfillCameras)
+	protected.POST("/landfill-cameras", h.createLandfillCamera)
+	protected.PUT("/landfill-cameras/:id", h.updateLandfillCamera)
+	protected.DELETE("/landfill-cameras/:id", h.deleteLandfillCamera)
+
+	protected.GET("/tariffs", h.listTariffs)
+	protected.POST("/tariffs", h.createTariff)
+	protected.PUT("/tariffs/:id", h.updateTariff)
+	protected.DELETE("/tariffs/:id", h.deleteTariff)
 }
 
 type exportActsRequest struct {
 	Mode        string `json:"mode" binding:"required"`
-	TargetID    string `json:"target_id" binding:"required"`
+	TargetID    string `json:"target_id"`
 	PeriodStart string `json:"period_start" binding:"required"`
 	PeriodEnd   string `json:"period_end" binding:"required"`
+	// Format names the document format; the Accept header is used when empty.
+	Format string `json:"format"`
+	// Lang names the document language; Accept-Language is used when empty.
+	Lang string `json:"lang"`
+	// LandfillID names the landfill of a pair act.
+	LandfillID string `json:"landfill_id"`
+	// HideEmptyGroups leaves groups without trips out of the document.
+	HideEmptyGroups bool `json:"hide_empty_groups"`
 }
 
+// exportActs issues an act and returns its document in the format named in
+// the request or negotiated from the Accept header.
 func (h *Handler) exportActs(c *gin.Context) {
-	principal, ok := middleware.MustPrincipal(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
-		return
-	}
-
-	var req exportActsRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	mode, err := parseReportMode(req.Mode)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
-		return
-	}
-
-	targetID, err := uuid.Parse(strings.TrimSpace(req.TargetID))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_id"})
-		return
-	}
-
-	start, err := parseDate(req.PeriodStart)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_start"})
-		return
-	}
-
-	end, err := parseDate(req.PeriodEnd)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_end"})
-		return
-	}
+	h.exportAct(c, "")
+}
 
-	result, err := h.acts.GenerateReport(c.Request.Context(), service.GenerateReportInput{
-		Mode:        mode,
-		TargetID:    targetID,
-		PeriodStart: start,
-		PeriodEnd:   end,
-		Principal:   principal,
-	})
-	if err != nil {
-		h.handleError(c, err)
-		return
+// exportActsAs serves the legacy per-format export routes.
+func (h *Handler) exportActsAs(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.exportAct(c, format)
 	}
-
-	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	c.Header("Content-Disposition", "attachment; filename=\""+result.FileName+"\"")
-	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", result.Content)
 }
 
-func (h *Handler) exportActsPDF(c *gin.Context) {
+func (h *Handler) exportAct(c *gin.Context, format string) {
 	principal, ok := middleware.MustPrincipal(c)
 	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
@@ -104,46 +139,45 @@ func (h *Handler) exportActsPDF(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	mode, err := parseReportMode(req.Mode)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+	input, ok := parseExportRequest(c, req, h.acts.Location())
+	if !ok {
 		return
 	}
+	input.Principal = principal
 
-	targetID, err := uuid.Parse(strings.TrimSpace(req.TargetID))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_id"})
-		return
+	if format == "" {
+		if format, ok = h.negotiateFormat(c, req.Format, h.acts.NegotiateFormat); !ok {
+			return
+		}
+	} else {
+		auditFormat(c, format)
 	}
 
-	start, err := parseDate(req.PeriodStart)
+	result, err := h.acts.Export(c.Request.Context(), input, format)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_start"})
+		h.handleError(c, err)
 		return
 	}
 
-	end, err := parseDate(req.PeriodEnd)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period_end"})
-		return
-	}
+	h.sendDocument(c, result)
+}
 
-	result, err := h.acts.GenerateReportPDF(c.Request.Context(), service.GenerateReportInput{
-		Mode:        mode,
-		TargetID:    targetID,
-		PeriodStart: start,
-		PeriodEnd:   end,
-		Principal:   principal,
-	})
+// negotiateFormat resolves the format of an export the same way for acts and
+// trips: the format field of the body, else the format query parameter, else
+// the Accept header. On failure it writes the error response itself.
+func (h *Handler) negotiateFormat(c *gin.Context, name string, negotiate func(name, accept string) (string, error)) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = strings.ToLower(strings.TrimSpace(c.Query("format")))
+	}
+	format, err := negotiate(name, c.GetHeader("Accept"))
 	if err != nil {
+		auditFormat(c, name)
 		h.handleError(c, err)
-		return
+		return "", false
 	}
-
-	c.Header("Content-Type", "application/pdf")
-	c.Header("Content-Disposition", "attachment; filename=\""+result.FileName+"\"")
-	c.Data(http.StatusOK, "application/pdf", result.Content)
+	auditFormat(c, format)
+	return format, true
 }
 
 func (h *Handler) handleError(c *gin.Context, err error) {
@@ -154,8 +188,12 @@ func (h *Handler) handleError(c *gin.Context, err error) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 	case errors.Is(err, service.ErrNotFound):
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNotAcceptable):
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
 	default:
-		h.log.Error().Err(err).Msg("generate report failed")
+		h.log.Error().Err(err).Str("path", c.FullPath()).Msg("request failed")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 	}
 }
@@ -166,23 +204,32 @@ func parseReportMode(raw string) (model.ReportMode, error) {
 		return model.ReportModeContractor, nil
 	case "landfill":
 		return model.ReportModeLandfill, nil
+	case "city":
+		return model.ReportModeCity, nil
+	case "pair":
+		return model.ReportModePair, nil
 	default:
 		return "", service.ErrInvalidInput
 	}
 }
 
-func parseDate(raw string) (time.Time, error) {
+// parseDate parses a date or a moment in loc. A moment with an explicit
+// offset (RFC 3339) is converted to loc, so "2026-01-31T19:00:00Z" is
+// 1 February in Asia/Almaty; values without an offset are read as loc time.
+func parseDate(raw string, loc *time.Location) (time.Time, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
 		return time.Time{}, service.ErrInvalidInput
 	}
+	if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+		return parsed.In(loc), nil
+	}
 	layouts := []string{
-		time.RFC3339,
 		"2006-01-02",
 		"2006-01-02T15:04:05",
 	}
 	for _, layout := range layouts {
-		if parsed, err := time.Parse(layout, raw); err == nil {
+		if parsed, err := time.ParseInLocation(layout, raw, loc); err == nil {
 			return parsed, nil
 		}
 	}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-acts/internal/http/middleware"
+	"github.com/nurpe/snowops-acts/internal/service"
+)
+
+type landfillCameraRequest struct {
+	CameraID   string  `json:"camera_id" binding:"required"`
+	LandfillID string  `json:"landfill_id" binding:"required"`
+	ValidFrom  string  `json:"valid_from" binding:"required"`
+	ValidTo    *string `json:"valid_to"`
+}
+
+func (h *Handler) listLandfillCameras(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	cameras, err := h.cameras.List(c.Request.Context(), principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": cameras})
+}
+
+func (h *Handler) createLandfillCamera(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	input, ok := bindLandfillCamera(c, h.acts.Location())
+	if !ok {
+		return
+	}
+	input.Principal = principal
+
+	camera, err := h.cameras.Create(c.Request.Context(), input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": camera})
+}
+
+func (h *Handler) updateLandfillCamera(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	input, ok := bindLandfillCamera(c, h.acts.Location())
+	if !ok {
+		return
+	}
+	input.Principal = principal
+
+	camera, err := h.cameras.Update(c.Request.Context(), id, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": camera})
+}
+
+func (h *Handler) deleteLandfillCamera(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.cameras.Delete(c.Request.Context(), id, principal); err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func bindLandfillCamera(c *gin.Context, loc *time.Location) (service.LandfillCameraInput, bool) {
+	var req landfillCameraRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return service.LandfillCameraInput{}, false
+	}
+
+	landfillID, err := uuid.Parse(strings.TrimSpace(req.LandfillID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid landfill_id"})
+		return service.LandfillCameraInput{}, false
+	}
+
+	validFrom, err := parseDate(req.ValidFrom, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid valid_from"})
+		return service.LandfillCameraInput{}, false
+	}
+
+	var validTo *time.Time
+	if req.ValidTo != nil && strings.TrimSpace(*req.ValidTo) != "" {
+		parsed, err := parseDate(*req.ValidTo, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid valid_to"})
+			return service.LandfillCameraInput{}, false
+		}
+		validTo = &parsed
+	}
+
+	return service.LandfillCameraInput{
+		CameraID:   req.CameraID,
+		LandfillID: landfillID,
+		ValidFrom:  validFrom,
+		ValidTo:    validTo,
+	}, true
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-acts/internal/http/middleware"
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/service"
+)
+
+type tariffRequest struct {
+	LandfillID   *string  `json:"landfill_id"`
+	ContractorID *string  `json:"contractor_id"`
+	Unit         string   `json:"unit" binding:"required"`
+	Price        *float64 `json:"price" binding:"required"`
+	ValidFrom    string   `json:"valid_from" binding:"required"`
+	ValidTo      *string  `json:"valid_to"`
+}
+
+func (h *Handler) listTariffs(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	tariffs, err := h.tariffs.List(c.Request.Context(), principal)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tariffs})
+}
+
+func (h *Handler) createTariff(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	input, ok := bindTariff(c, h.acts.Location())
+	if !ok {
+		return
+	}
+	input.Principal = principal
+
+	tariff, err := h.tariffs.Create(c.Request.Context(), input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": tariff})
+}
+
+func (h *Handler) updateTariff(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	input, ok := bindTariff(c, h.acts.Location())
+	if !ok {
+		return
+	}
+	input.Principal = principal
+
+	tariff, err := h.tariffs.Update(c.Request.Context(), id, input)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tariff})
+}
+
+func (h *Handler) deleteTariff(c *gin.Context) {
+	principal, ok := middleware.MustPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing principal"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.tariffs.Delete(c.Request.Context(), id, principal); err != nil {
+		h.handleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func bindTariff(c *gin.Context, loc *time.Location) (service.TariffInput, bool) {
+	var req tariffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return service.TariffInput{}, false
+	}
+
+	landfillID, ok := parseOptionalUUID(req.LandfillID)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid landfill_id"})
+		return service.TariffInput{}, false
+	}
+	contractorID, ok := parseOptionalUUID(req.ContractorID)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid contractor_id"})
+		return service.TariffInput{}, false
+	}
+
+	validFrom, err := parseDate(req.ValidFrom, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid valid_from"})
+		return service.TariffInput{}, false
+	}
+
+	var validTo *time.Time
+	if req.ValidTo != nil && strings.TrimSpace(*req.ValidTo) != "" {
+		parsed, err := parseDate(*req.ValidTo, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid valid_to"})
+			return service.TariffInput{}, false
+		}
+		validTo = &parsed
+	}
+
+	return service.TariffInput{
+		LandfillID:   landfillID,
+		ContractorID: contractorID,
+		Unit:         model.TariffUnit(req.Unit),
+		Price:        *req.Price,
+		ValidFrom:    validFrom,
+		ValidTo:      validTo,
+	}, true
+}
+
+// parseOptionalUUID parses an optional id; nil and blank mean "any".
+func parseOptionalUUID(value *string) (*uuid.UUID, bool) {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(strings.TrimSpace(*value))
+	if err != nil {
+		return nil, false
+	}
+	return &id, true
+}
@@ -0,0 +1,578 @@
+// Package labels holds the captions of act documents in every supported
+// language, so every document format names the same things the same way.
+package labels
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// Supported document languages.
+const (
+	LangRussian = "ru"
+	LangKazakh  = "kk"
+	LangEnglish = "en"
+)
+
+// DefaultLang is used when no language is requested.
+const DefaultLang = LangRussian
+
+// Labels are the captions of act documents in one language.
+type Labels struct {
+	Lang          string
+	Summary       string
+	ActNumber     string
+	ReportType    string
+	Organization  string
+	PeriodStart   string
+	PeriodEnd     string
+	Period        string
+	TripCount     string
+	SnowVolume    string
+	NetAmount     string
+	VATAmount     string
+	GrossAmount   string
+	Statuses      string
+	UnpricedTrips string
+	TariffFrom    string
+	TariffTo      string
+	Unit          string
+	Price         string
+	Quantity      string
+	Date          string
+	Plate         string
+	Reprint       string
+	Landfill      string
+	Contractor    string
+	City          string
+	Report        string
+	Group         string
+	Sheet         string
+	UnitM3        string
+	UnitTrip      string
+	// Matrix names the contractor × landfill summary of a city-wide act and
+	// Total its totals.
+	Matrix string
+	Total  string
+	// ColTrips, ColVolume, ColNet, ColVAT and ColGross are short captions
+	// for narrow table columns.
+	ColTrips  string
+	ColVolume string
+	ColNet    string
+	ColVAT    string
+	ColGross  string
+	// ActTitleFormat formats the document title with the act number.
+	ActTitleFormat string
+	// VATFormat formats the VAT caption with the rate in percent.
+	VATFormat string
+	// DateLayout and DateTimeLayout are time layouts of dates in documents.
+	DateLayout     string
+	DateTimeLayout string
+	// NumberDateFormat formats an act number with its date.
+	NumberDateFormat string
+
+	// Captions of corrective acts. Before, After and Difference head the
+	// columns of the compared figures; the formats take an act number and
+	// its date.
+	Corrective            string
+	CorrectiveSheet       string
+	OriginalAct           string
+	OriginalActDate       string
+	PreviousAct           string
+	Before                string
+	After                 string
+	Difference            string
+	CorrectiveTitleFormat string
+	OriginalActFormat     string
+	PreviousActFormat     string
+	Customer              string
+	Executor              string
+	SignatureLineFormat   string
+	SignatureCaption      string
+	SealPlace             string
+
+	// Captions of the drift workbook.
+	DriftSheet       string
+	IssueDate        string
+	CheckDate        string
+	EventsAdded      string
+	EventsRemoved    string
+	VolumeChanged    string
+	Change           string
+	EventID          string
+	VolumeInAct      string
+	VolumeNow        string
+	VolumeDifference string
+	DriftAdded       string
+	DriftRemoved     string
+	DriftChanged     string
+	NoDrift          string
+
+	// Captions of the act of completed works (form P-1). PeriodFromToFormat
+	// takes the first and the last day of the period.
+	CompletedWorks      string
+	CompletedWorksTitle string
+	FormP1Note          string
+	DocumentNumber      string
+	DocumentDate        string
+	PeriodFromToFormat  string
+	RowNumber           string
+	WorkName            string
+	WorkDate            string
+	MeasureUnit         string
+	UnitPrice           string
+	Cost                string
+	TotalNet            string
+	VATRateFormat       string
+	TotalGross          string
+	BIN                 string
+	Phone               string
+	Delivered           string
+	Accepted            string
+}
+
+// Russian are the captions of the Russian-language documents.
+var Russian = Labels{
+	Lang:           LangRussian,
+	Summary:        "Сводка",
+	ActNumber:      "Номер акта",
+	ReportType:     "Тип отчета",
+	Organization:   "Организация",
+	PeriodStart:    "Начало периода",
+	PeriodEnd:      "Конец периода",
+	Period:         "Период",
+	TripCount:      "Количество рейсов",
+	SnowVolume:     "Объем снега, м3",
+	NetAmount:      "Сумма без НДС, тг",
+	VATAmount:      "НДС, тг",
+	GrossAmount:    "Сумма с НДС, тг",
+	Statuses:       "Статусы событий",
+	UnpricedTrips:  "Рейсов без тарифа",
+	TariffFrom:     "Тариф с",
+	TariffTo:       "Тариф по",
+	Unit:           "Единица",
+	Price:          "Цена, тг",
+	Quantity:       "Количество",
+	Date:           "Дата",
+	Plate:          "Номер машины",
+	Reprint:        "Повторная печать",
+	Landfill:       "Полигон",
+	Contractor:     "Подрядчик",
+	City:           "Город",
+	Report:         "Отчет",
+	Group:          "Группа",
+	Sheet:          "Лист",
+	UnitM3:         "м3",
+	UnitTrip:       "рейс",
+	Matrix:         "Подрядчики и полигоны",
+	Total:          "Итого",
+	ColTrips:       "Рейсы",
+	ColVolume:      "Объем, м3",
+	ColNet:         "Без НДС",
+	ColVAT:         "НДС",
+	ColGross:       "С НДС",
+	ActTitleFormat: "Акт № %s",
+	VATFormat:      "НДС %s%%, тг",
+	DateLayout:     "02.01.2006",
+	DateTimeLayout: "02.01.2006 15:04:05",
+
+	NumberDateFormat: "%s от %s",
+
+	Corrective:            "Корректировочный акт",
+	CorrectiveSheet:       "Корректировка",
+	OriginalAct:           "К акту",
+	OriginalActDate:       "Дата исходного акта",
+	PreviousAct:           "С учетом акта",
+	Before:                "было",
+	After:                 "стало",
+	Difference:            "разница",
+	CorrectiveTitleFormat: "Корректировочный акт № %s от %s",
+	OriginalActFormat:     "к акту № %s от %s",
+	PreviousActFormat:     "с учетом корректировочного акта № %s от %s",
+	Customer:              "Заказчик",
+	Executor:              "Исполнитель",
+	SignatureLineFormat:   "_____________ / %s /",
+	SignatureCaption:      "подпись / расшифровка подписи",
+	SealPlace:             "М.П.",
+
+	DriftSheet:       "Расхождения",
+	IssueDate:        "Дата выдачи",
+	CheckDate:        "Дата проверки",
+	EventsAdded:      "Добавлено событий",
+	EventsRemoved:    "Удалено событий",
+	VolumeChanged:    "Изменен объем",
+	Change:           "Изменение",
+	EventID:          "ID события",
+	VolumeInAct:      "Объем в акте, м3",
+	VolumeNow:        "Объем сейчас, м3",
+	VolumeDifference: "Разница, м3",
+	DriftAdded:       "Добавлено",
+	DriftRemoved:     "Удалено",
+	DriftChanged:     "Изменен объем",
+	NoDrift:          "Расхождений нет",
+
+	CompletedWorks:      "Акт выполненных работ",
+	CompletedWorksTitle: "АКТ ВЫПОЛНЕННЫХ РАБОТ (ОКАЗАННЫХ УСЛУГ)",
+	FormP1Note:          "Приложение 50\nк приказу Министра финансов\nРеспублики Казахстан\nот 20 декабря 2012 года № 562\nФорма Р-1",
+	DocumentNumber:      "Номер документа",
+	DocumentDate:        "Дата составления",
+	PeriodFromToFormat:  "за период с %s по %s",
+	RowNumber:           "№ п/п",
+	WorkName:            "Наименование работ (услуг)",
+	WorkDate:            "Дата выполнения работ (оказания услуг)",
+	MeasureUnit:         "Единица измерения",
+	UnitPrice:           "Цена за единицу, тг",
+	Cost:                "Стоимость, тг",
+	TotalNet:            "Итого без НДС",
+	VATRateFormat:       "НДС %s%%",
+	TotalGross:          "Всего с НДС",
+	BIN:                 "БИН",
+	Phone:               "тел.",
+	Delivered:           "Сдал (Исполнитель)",
+	Accepted:            "Принял (Заказчик)",
+}
+
+// Kazakh are the captions of the Kazakh-language documents.
+var Kazakh = Labels{
+	Lang:           LangKazakh,
+	Summary:        "Жиынтық",
+	ActNumber:      "Акт нөмірі",
+	ReportType:     "Есеп түрі",
+	Organization:   "Ұйым",
+	PeriodStart:    "Кезеңнің басы",
+	PeriodEnd:      "Кезеңнің соңы",
+	Period:         "Кезең",
+	TripCount:      "Рейстер саны",
+	SnowVolume:     "Қар көлемі, м3",
+	NetAmount:      "ҚҚС-сыз сома, тг",
+	VATAmount:      "ҚҚС, тг",
+	GrossAmount:    "ҚҚС-пен сома, тг",
+	Statuses:       "Оқиға мәртебелері",
+	UnpricedTrips:  "Тарифсіз рейстер",
+	TariffFrom:     "Тариф басталуы",
+	TariffTo:       "Тариф аяқталуы",
+	Unit:           "Бірлік",
+	Price:          "Бағасы, тг",
+	Quantity:       "Саны",
+	Date:           "Күні",
+	Plate:          "Көлік нөмірі",
+	Reprint:        "Қайта басып шығару",
+	Landfill:       "Полигон",
+	Contractor:     "Мердігер",
+	City:           "Қала",
+	Report:         "Есеп",
+	Group:          "Топ",
+	Sheet:          "Парақ",
+	UnitM3:         "м3",
+	UnitTrip:       "рейс",
+	Matrix:         "Мердігерлер мен полигондар",
+	Total:          "Барлығы",
+	ColTrips:       "Рейстер",
+	ColVolume:      "Көлемі, м3",
+	ColNet:         "ҚҚС-сыз",
+	ColVAT:         "ҚҚС",
+	ColGross:       "ҚҚС-пен",
+	ActTitleFormat: "№ %s акт",
+	VATFormat:      "ҚҚС %s%%, тг",
+	DateLayout:     "02.01.2006",
+	DateTimeLayout: "02.01.2006 15:04:05",
+
+	NumberDateFormat: "%s, %s",
+
+	Corrective:            "Түзету акті",
+	CorrectiveSheet:       "Түзету",
+	OriginalAct:           "Бастапқы акт",
+	OriginalActDate:       "Бастапқы акт күні",
+	PreviousAct:           "Ескерілген акт",
+	Before:                "бұрын",
+	After:                 "қазір",
+	Difference:            "айырма",
+	CorrectiveTitleFormat: "№ %s түзету акті, %s",
+	OriginalActFormat:     "№ %s актіге, %s",
+	PreviousActFormat:     "№ %s түзету актісін ескере отырып, %s",
+	Customer:              "Тапсырыс беруші",
+	Executor:              "Орындаушы",
+	SignatureLineFormat:   "_____________ / %s /",
+	SignatureCaption:      "қолы / қолтаңбаның толық жазылуы",
+	SealPlace:             "М.О.",
+
+	DriftSheet:       "Айырмашылықтар",
+	IssueDate:        "Берілген күні",
+	CheckDate:        "Тексерілген күні",
+	EventsAdded:      "Қосылған оқиғалар",
+	EventsRemoved:    "Жойылған оқиғалар",
+	VolumeChanged:    "Көлемі өзгерген",
+	Change:           "Өзгеріс",
+	EventID:          "Оқиға ID",
+	VolumeInAct:      "Актідегі көлем, м3",
+	VolumeNow:        "Қазіргі көлем, м3",
+	VolumeDifference: "Айырма, м3",
+	DriftAdded:       "Қосылды",
+	DriftRemoved:     "Жойылды",
+	DriftChanged:     "Көлемі өзгерді",
+	NoDrift:          "Айырмашылық жоқ",
+
+	CompletedWorks:      "Орындалған жұмыстар актісі",
+	CompletedWorksTitle: "ОРЫНДАЛҒАН ЖҰМЫСТАР (КӨРСЕТІЛГЕН ҚЫЗМЕТТЕР) АКТІСІ",
+	FormP1Note:          "Қазақстан Республикасы\nҚаржы министрінің\n2012 жылғы 20 желтоқсандағы № 562\nбұйрығына 50-қосымша\nР-1 нысаны",
+	DocumentNumber:      "Құжат нөмірі",
+	DocumentDate:        "Жасалған күні",
+	PeriodFromToFormat:  "%s бастап %s дейінгі кезең үшін",
+	RowNumber:           "Р/с №",
+	WorkName:            "Жұмыстардың (қызметтердің) атауы",
+	WorkDate:            "Жұмыстардың орындалған (қызметтердің көрсетілген) күні",
+	MeasureUnit:         "Өлшем бірлігі",
+	UnitPrice:           "Бірлік бағасы, тг",
+	Cost:                "Құны, тг",
+	TotalNet:            "ҚҚС-сыз барлығы",
+	VATRateFormat:       "ҚҚС %s%%",
+	TotalGross:          "ҚҚС-пен барлығы",
+	BIN:                 "БСН",
+	Phone:               "тел.",
+	Delivered:           "Тапсырды (Орындаушы)",
+	Accepted:            "Қабылдады (Тапсырыс беруші)",
+}
+
+// English are the captions of the English-language documents.
+var English = Labels{
+	Lang:           LangEnglish,
+	Summary:        "Summary",
+	ActNumber:      "Act number",
+	ReportType:     "Report type",
+	Organization:   "Organization",
+	PeriodStart:    "Period start",
+	PeriodEnd:      "Period end",
+	Period:         "Period",
+	TripCount:      "Trips",
+	SnowVolume:     "Snow volume, m3",
+	NetAmount:      "Net amount, KZT",
+	VATAmount:      "VAT, KZT",
+	GrossAmount:    "Gross amount, KZT",
+	Statuses:       "Event statuses",
+	UnpricedTrips:  "Trips without tariff",
+	TariffFrom:     "Tariff from",
+	TariffTo:       "Tariff to",
+	Unit:           "Unit",
+	Price:          "Price, KZT",
+	Quantity:       "Quantity",
+	Date:           "Date",
+	Plate:          "Plate",
+	Reprint:        "Reprint",
+	Landfill:       "Landfill",
+	Contractor:     "Contractor",
+	City:           "City",
+	Report:         "Report",
+	Group:          "Group",
+	Sheet:          "Sheet",
+	UnitM3:         "m3",
+	UnitTrip:       "trip",
+	Matrix:         "Contractors by landfill",
+	Total:          "Total",
+	ColTrips:       "Trips",
+	ColVolume:      "Volume, m3",
+	ColNet:         "Net",
+	ColVAT:         "VAT",
+	ColGross:       "Gross",
+	ActTitleFormat: "Act No. %s",
+	VATFormat:      "VAT %s%%, KZT",
+	DateLayout:     "2006-01-02",
+	DateTimeLayout: "2006-01-02 15:04:05",
+
+	NumberDateFormat: "%s of %s",
+
+	Corrective:            "Corrective act",
+	CorrectiveSheet:       "Correction",
+	OriginalAct:           "To act",
+	OriginalActDate:       "Original act date",
+	PreviousAct:           "Following act",
+	Before:                "before",
+	After:                 "after",
+	Difference:            "difference",
+	CorrectiveTitleFormat: "Corrective act No. %s of %s",
+	OriginalActFormat:     "to act No. %s of %s",
+	PreviousActFormat:     "following corrective act No. %s of %s",
+	Customer:              "Customer",
+	Executor:              "Executor",
+	SignatureLineFormat:   "_____________ / %s /",
+	SignatureCaption:      "signature / full name",
+	SealPlace:             "Seal",
+
+	DriftSheet:       "Drift",
+	IssueDate:        "Issued at",
+	CheckDate:        "Checked at",
+	EventsAdded:      "Events added",
+	EventsRemoved:    "Events removed",
+	VolumeChanged:    "Volume changed",
+	Change:           "Change",
+	EventID:          "Event ID",
+	VolumeInAct:      "Volume in act, m3",
+	VolumeNow:        "Current volume, m3",
+	VolumeDifference: "Difference, m3",
+	DriftAdded:       "Added",
+	DriftRemoved:     "Removed",
+	DriftChanged:     "Volume changed",
+	NoDrift:          "No drift",
+
+	CompletedWorks:      "Act of completed works",
+	CompletedWorksTitle: "ACT OF COMPLETED WORKS (SERVICES RENDERED)",
+	FormP1Note:          "Annex 50\nto Order of the Minister of Finance\nof the Republic of Kazakhstan\nNo. 562 of 20 December 2012\nForm R-1",
+	DocumentNumber:      "Document number",
+	DocumentDate:        "Date of preparation",
+	PeriodFromToFormat:  "for the period from %s to %s",
+	RowNumber:           "No.",
+	WorkName:            "Work (services)",
+	WorkDate:            "Date of work (services)",
+	MeasureUnit:         "Unit of measure",
+	UnitPrice:           "Unit price, KZT",
+	Cost:                "Amount, KZT",
+	TotalNet:            "Total net of VAT",
+	VATRateFormat:       "VAT %s%%",
+	TotalGross:          "Total including VAT",
+	BIN:                 "BIN",
+	Phone:               "tel.",
+	Delivered:           "Delivered by (Executor)",
+	Accepted:            "Accepted by (Customer)",
+}
+
+var catalogs = map[string]Labels{
+	LangRussian: Russian,
+	LangKazakh:  Kazakh,
+	LangEnglish: English,
+}
+
+// For returns the captions of lang; an empty or unknown lang gives the
+// default language.
+func For(lang string) Labels {
+	if l, ok := catalogs[lang]; ok {
+		return l
+	}
+	return catalogs[DefaultLang]
+}
+
+// Supported reports whether documents can be rendered in lang.
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Negotiate picks the supported language an Accept-Language header prefers
+// most. Regional variants match their language ("en-US" gives "en"). It
+// returns DefaultLang when nothing matches.
+func Negotiate(acceptLanguage string) string {
+	type languageRange struct {
+		lang    string
+		quality float64
+	}
+	var ranges []languageRange
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		lang, _, _ := strings.Cut(tag, "-")
+		if lang == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(key, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, languageRange{lang: lang, quality: quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+
+	for _, r := range ranges {
+		if Supported(r.lang) {
+			return r.lang
+		}
+	}
+	return DefaultLang
+}
+
+// Mode returns the caption of the report target and of its groups: a
+// contractor act is grouped by landfill, a landfill act and a city-wide act by
+// contractor.
+func (l Labels) Mode(mode model.ReportMode) (target, group string) {
+	switch mode {
+	case model.ReportModeLandfill:
+		return l.Landfill, l.Contractor
+	case model.ReportModeCity:
+		return l.City, l.Contractor
+	case model.ReportModeContractor, model.ReportModePair:
+		return l.Contractor, l.Landfill
+	default:
+		return l.Report, l.Group
+	}
+}
+
+// ActTitle returns the document title of the act with number.
+func (l Labels) ActTitle(number string) string {
+	return fmt.Sprintf(l.ActTitleFormat, number)
+}
+
+// NumberDate returns an act number with its date.
+func (l Labels) NumberDate(format, number string, date time.Time) string {
+	return fmt.Sprintf(format, number, l.FormatDate(date))
+}
+
+// VAT returns the VAT caption with the rate.
+func (l Labels) VAT(rate float64) string {
+	return fmt.Sprintf(l.VATFormat, strconv.FormatFloat(rate, 'f', -1, 64))
+}
+
+// VATRate returns the VAT caption with the rate and without the currency.
+func (l Labels) VATRate(rate float64) string {
+	return fmt.Sprintf(l.VATRateFormat, strconv.FormatFloat(rate, 'f', -1, 64))
+}
+
+// DriftKind returns the caption of a kind of drift.
+func (l Labels) DriftKind(kind model.DriftKind) string {
+	switch kind {
+	case model.DriftAdded:
+		return l.DriftAdded
+	case model.DriftRemoved:
+		return l.DriftRemoved
+	case model.DriftChanged:
+		return l.DriftChanged
+	default:
+		return string(kind)
+	}
+}
+
+func (l Labels) TariffUnit(unit model.TariffUnit) string {
+	switch unit {
+	case model.TariffUnitM3:
+		return l.UnitM3
+	case model.TariffUnitTrip:
+		return l.UnitTrip
+	default:
+		return string(unit)
+	}
+}
+
+// FormatDate formats a date the way the language writes it; zero is empty.
+func (l Labels) FormatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(l.DateLayout)
+}
+
+// FormatDateTime formats a moment the way the language writes it; zero is
+// empty.
+func (l Labels) FormatDateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(l.DateTimeLayout)
+}
@@ -0,0 +1,215 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is a local SMTP server without TLS or auth that accepts one
+// session and records its envelope and message.
+type fakeSMTP struct {
+	listener net.Listener
+	// rcptReply answers RCPT TO; a 5xx reply rejects the recipient.
+	rcptReply string
+
+	from string
+	to   []string
+	data []byte
+	done chan error
+}
+
+func startFakeSMTP(t *testing.T, rcptReply string) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{listener: listener, rcptReply: rcptReply, done: make(chan error, 1)}
+	t.Cleanup(func() { listener.Close() })
+	go func() { s.done <- s.serve() }()
+	return s
+}
+
+func (s *fakeSMTP) sender(t *testing.T) *SMTP {
+	host, port, err := net.SplitHostPort(s.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := NewSMTP(host, portNumber, "", "")
+	sender.timeout = 5 * time.Second
+	return sender
+}
+
+func (s *fakeSMTP) serve() error {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	text := textproto.NewConn(conn)
+	if err := text.PrintfLine("220 localhost fake ESMTP"); err != nil {
+		return err
+	}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			err = text.PrintfLine("250 localhost")
+		case "MAIL":
+			s.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			err = text.PrintfLine("250 OK")
+		case "RCPT":
+			s.to = append(s.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			err = text.PrintfLine("%s", s.rcptReply)
+		case "DATA":
+			if err := text.PrintfLine("354 End data with <CR><LF>.<CR><LF>"); err != nil {
+				return err
+			}
+			if s.data, err = text.ReadDotBytes(); err != nil {
+				return err
+			}
+			err = text.PrintfLine("250 OK")
+		case "RSET", "NOOP":
+			err = text.PrintfLine("250 OK")
+		case "QUIT":
+			return text.PrintfLine("221 Bye")
+		default:
+			err = text.PrintfLine("502 Command not implemented")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	server := startFakeSMTP(t, "250 OK")
+	workbook := []byte("PK\x03\x04 act workbook")
+	msg := Message{
+		From:    "Акты <acts@example.kz>",
+		To:      []string{"buh@contractor.kz", "Оператор <operator@landfill.kz>"},
+		Subject: "Акт № АКТ-2026-000017 за январь 2026",
+		Body:    "Здравствуйте! Рейсов: 1 204, объем: 15 050,00 м³.",
+		Attachments: []Attachment{{
+			FileName:    "act-АКТ-2026-000017.xlsx",
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			Content:     workbook,
+		}},
+	}
+
+	if err := server.sender(t).Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := <-server.done; err != nil {
+		t.Fatalf("fake server: %v", err)
+	}
+
+	if server.from != "acts@example.kz" {
+		t.Errorf("MAIL FROM = %q, want the bare sender address", server.from)
+	}
+	if want := []string{"buh@contractor.kz", "operator@landfill.kz"}; strings.Join(server.to, ",") != strings.Join(want, ",") {
+		t.Errorf("RCPT TO = %v, want %v", server.to, want)
+	}
+
+	parsed, err := netmail.ReadMessage(bytes.NewReader(server.data))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	header, _, _ := bytes.Cut(server.data, []byte("\r\n\r\n"))
+	for _, b := range header {
+		if b >= 0x80 {
+			t.Fatalf("message header has 8-bit bytes:\n%s", header)
+		}
+	}
+	from, err := parsed.Header.AddressList("From")
+	if err != nil || len(from) != 1 || from[0].Name != "Акты" || from[0].Address != "acts@example.kz" {
+		t.Errorf("From = %v (%v), want Акты <acts@example.kz>", from, err)
+	}
+	to, err := parsed.Header.AddressList("To")
+	if err != nil || len(to) != 2 ||
+		to[0].Name != "" || to[0].Address != "buh@contractor.kz" ||
+		to[1].Name != "Оператор" || to[1].Address != "operator@landfill.kz" {
+		t.Errorf("To = %v (%v), want buh@contractor.kz and Оператор <operator@landfill.kz>", to, err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("subject = %q (%v), want %q", subject, err, msg.Subject)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("content type = %q (%v), want multipart/mixed", mediaType, err)
+	}
+
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	body := readPart(t, parts)
+	if body.content != msg.Body {
+		t.Errorf("body = %q, want %q", body.content, msg.Body)
+	}
+	attachment := readPart(t, parts)
+	if attachment.fileName != msg.Attachments[0].FileName {
+		t.Errorf("attachment name = %q, want %q", attachment.fileName, msg.Attachments[0].FileName)
+	}
+	if attachment.content != string(workbook) {
+		t.Errorf("attachment content = %q, want %q", attachment.content, workbook)
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("unexpected part after the attachment: %v", err)
+	}
+}
+
+func TestSMTPSendRejectedRecipient(t *testing.T) {
+	server := startFakeSMTP(t, "550 Почтовый ящик не найден")
+	msg := Message{From: "acts@example.kz", To: []string{"nobody@contractor.kz"}, Subject: "Акт", Body: "Акт"}
+
+	err := server.sender(t).Send(context.Background(), msg)
+	if err == nil || !strings.Contains(err.Error(), "Почтовый ящик не найден") {
+		t.Fatalf("send error = %v, want the server's rejection", err)
+	}
+	<-server.done
+	if server.data != nil {
+		t.Error("message data sent after the recipient was rejected")
+	}
+}
+
+type decodedPart struct {
+	fileName string
+	content  string
+}
+
+func readPart(t *testing.T, parts *multipart.Reader) decodedPart {
+	t.Helper()
+	part, err := parts.NextPart()
+	if err != nil {
+		t.Fatalf("next part: %v", err)
+	}
+	if encoding := part.Header.Get("Content-Transfer-Encoding"); encoding != "base64" {
+		t.Fatalf("transfer encoding = %q, want base64", encoding)
+	}
+	content, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, bufio.NewReader(part)))
+	if err != nil {
+		t.Fatalf("decode part: %v", err)
+	}
+	return decodedPart{fileName: part.FileName(), content: string(content)}
+}
@@ -0,0 +1,108 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// Languages act emails are written in.
+const (
+	LangRussian = "ru"
+	LangKazakh  = "kk"
+)
+
+// actData is what the act email templates show.
+type actData struct {
+	Number       string
+	Organization string
+	Landfill     string
+	PeriodStart  string
+	PeriodEnd    string
+	TripCount    int64
+	Volume       string
+}
+
+type actTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+var actTemplates = map[string]actTemplate{
+	LangRussian: {
+		subject: template.Must(template.New("subject").Parse(
+			`Акт {{.Number}} за период {{.PeriodStart}} – {{.PeriodEnd}}`)),
+		body: template.Must(template.New("body").Parse(`Здравствуйте!
+
+Направляем акт {{.Number}} по вывозу снега за период с {{.PeriodStart}} по {{.PeriodEnd}}.
+
+Организация: {{.Organization}}
+{{if .Landfill}}Полигон: {{.Landfill}}
+{{end}}Количество рейсов: {{.TripCount}}
+Объем снега: {{.Volume}} м³
+
+Документы акта приложены к письму.
+
+Письмо отправлено автоматически, отвечать на него не нужно.
+`)),
+	},
+	LangKazakh: {
+		subject: template.Must(template.New("subject").Parse(
+			`{{.PeriodStart}} – {{.PeriodEnd}} кезеңіндегі № {{.Number}} акт`)),
+		body: template.Must(template.New("body").Parse(`Сәлеметсіз бе!
+
+{{.PeriodStart}} – {{.PeriodEnd}} кезеңіндегі қар шығару бойынша № {{.Number}} актіні жолдаймыз.
+
+Ұйым: {{.Organization}}
+{{if .Landfill}}Полигон: {{.Landfill}}
+{{end}}Рейстер саны: {{.TripCount}}
+Қар көлемі: {{.Volume}} м³
+
+Акт құжаттары хатқа тіркелген.
+
+Хат автоматты түрде жіберілді, оған жауап берудің қажеті жоқ.
+`)),
+	},
+}
+
+// SupportedLang reports whether act emails can be written in lang.
+func SupportedLang(lang string) bool {
+	_, ok := actTemplates[lang]
+	return ok
+}
+
+// ActEmail returns the subject and body of the email that delivers the act
+// of report, in lang; other languages get the Russian text.
+func ActEmail(lang string, report model.ActReport) (subject, body string, err error) {
+	tmpl, ok := actTemplates[lang]
+	if !ok {
+		lang = LangRussian
+		tmpl = actTemplates[lang]
+	}
+	l := labels.For(lang)
+	data := actData{
+		Number:       report.Number,
+		Organization: report.Target.Name,
+		PeriodStart:  l.FormatDate(report.PeriodStart),
+		PeriodEnd:    l.FormatDate(report.PeriodEnd),
+		TripCount:    report.TotalTrips,
+		Volume:       fmt.Sprintf("%.2f", report.TotalVolumeM3),
+	}
+	if report.Landfill != nil {
+		data.Landfill = report.Landfill.Name
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.subject.Execute(&buf, data); err != nil {
+		return "", "", err
+	}
+	subject = buf.String()
+	buf.Reset()
+	if err := tmpl.body.Execute(&buf, data); err != nil {
+		return "", "", err
+	}
+	return subject, buf.String(), nil
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ActStatus string
+
+const (
+	ActStatusDraft     ActStatus = "DRAFT"
+	ActStatusIssued    ActStatus = "ISSUED"
+	ActStatusSigned    ActStatus = "SIGNED"
+	ActStatusApproved  ActStatus = "APPROVED"
+	ActStatusCancelled ActStatus = "CANCELLED"
+)
+
+// Act is an entry of the act registry. Drafts have no number; the number,
+// year and totals are frozen when the act is issued.
+type Act struct {
+	ID       uuid.UUID `json:"id"`
+	Number   string    `json:"number"`
+	Year     int       `json:"year"`
+	Sequence int64     `json:"sequence"`
+	Status   ActStatus `json:"status"`
+	Kind     ActKind   `json:"kind"`
+	// OriginalActID is the act corrected by a corrective act.
+	OriginalActID *uuid.UUID `json:"original_act_id"`
+	// BaseActID is the act whose figures a corrective act is diffed
+	// against: the original act or the last corrective act issued for it.
+	BaseActID  *uuid.UUID `json:"base_act_id"`
+	Mode       ReportMode `json:"mode"`
+	TargetID   uuid.UUID  `json:"target_id"`
+	TargetName string     `json:"target_name"`
+	// LandfillID is the landfill of a pair act.
+	LandfillID    *uuid.UUID `json:"landfill_id"`
+	LandfillName  string     `json:"landfill_name"`
+	PeriodStart   time.Time  `json:"period_start"`
+	PeriodEnd     time.Time  `json:"period_end"`
+	Format        string     `json:"format"`
+	TotalTrips    int64      `json:"total_trips"`
+	TotalVolumeM3 float64    `json:"total_volume_m3"`
+	NetAmount     float64    `json:"net_amount"`
+	VATAmount     float64    `json:"vat_amount"`
+	GrossAmount   float64    `json:"gross_amount"`
+	// Fingerprint identifies the exact events and volumes the act was issued with.
+	Fingerprint  string    `json:"fingerprint"`
+	CreatedBy    uuid.UUID `json:"created_by"`
+	CreatedByOrg uuid.UUID `json:"created_by_org"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	IssuedAt           *time.Time `json:"issued_at"`
+	IssuedBy           *uuid.UUID `json:"issued_by"`
+	ContractorSignedAt *time.Time `json:"contractor_signed_at"`
+	ContractorSignedBy *uuid.UUID `json:"contractor_signed_by"`
+	AcceptedAt         *time.Time `json:"accepted_at"`
+	AcceptedBy         *uuid.UUID `json:"accepted_by"`
+	AcceptedByOrg      *uuid.UUID `json:"accepted_by_org"`
+	ApprovedAt         *time.Time `json:"approved_at"`
+	ApprovedBy         *uuid.UUID `json:"approved_by"`
+	CancelledAt        *time.Time `json:"cancelled_at"`
+	CancelledBy        *uuid.UUID `json:"cancelled_by"`
+	CancelReason       string     `json:"cancel_reason"`
+}
+
+// NeedsContractorSignature reports whether the act must be signed by the
+// contractor before it counts as signed. Landfill acts cover many contractors
+// and are only accepted by the landfill or KGU.
+func (a Act) NeedsContractorSignature() bool {
+	return a.Mode == ReportModeContractor || a.Mode == ReportModePair
+}
+
+// CoversOrg reports whether the act is about the organization: its target or
+// the landfill of a pair act.
+func (a Act) CoversOrg(orgID uuid.UUID) bool {
+	return a.TargetID == orgID || (a.LandfillID != nil && *a.LandfillID == orgID)
+}
+
+// FullySigned reports whether every required signature has been recorded.
+func (a Act) FullySigned() bool {
+	if a.AcceptedAt == nil {
+		return false
+	}
+	return !a.NeedsContractorSignature() || a.ContractorSignedAt != nil
+}
+
+type ActFilter struct {
+	Status   ActStatus
+	Mode     ReportMode
+	TargetID uuid.UUID
+	// OrgID limits the list to acts of one organization: acts about it and
+	// acts created by it. Nil means no restriction.
+	OrgID uuid.UUID
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the act audit log.
+const (
+	AuditActionExport      = "export"
+	AuditActionExportTrips = "export_trips"
+	AuditActionExportBatch = "export_batch"
+	AuditActionExportJob   = "export_job"
+	AuditActionJobDownload = "job_download"
+	AuditActionPreview     = "preview"
+	AuditActionView        = "view"
+	AuditActionReprint     = "reprint"
+	AuditActionDocument    = "document"
+	AuditActionCorrective  = "corrective"
+	AuditActionDriftExport = "drift_export"
+)
+
+type AuditOutcome string
+
+const (
+	AuditSuccess          AuditOutcome = "SUCCESS"
+	AuditInvalidInput     AuditOutcome = "INVALID_INPUT"
+	AuditPermissionDenied AuditOutcome = "PERMISSION_DENIED"
+	AuditNotFound         AuditOutcome = "NOT_FOUND"
+	AuditConflict         AuditOutcome = "CONFLICT"
+	AuditError            AuditOutcome = "ERROR"
+)
+
+// AuditEntry records one request to export or read an act, whether it
+// succeeded or not.
+type AuditEntry struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Action    string    `json:"action"`
+	UserID    uuid.UUID `json:"user_id"`
+	OrgID     uuid.UUID `json:"org_id"`
+	Role      UserRole  `json:"role"`
+	// Mode, target and period are what was asked for, or for requests about
+	// an act, the ones of the act.
+	Mode        ReportMode `json:"mode"`
+	TargetID    *uuid.UUID `json:"target_id"`
+	LandfillID  *uuid.UUID `json:"landfill_id"`
+	ActID       *uuid.UUID `json:"act_id"`
+	JobID       *uuid.UUID `json:"job_id"`
+	PeriodStart *time.Time `json:"period_start"`
+	PeriodEnd   *time.Time `json:"period_end"`
+	Format      string     `json:"format"`
+	// ForeignTarget marks requests of organizations other than Akimat and
+	// KGU about another organization's acts.
+	ForeignTarget bool         `json:"foreign_target"`
+	Outcome       AuditOutcome `json:"outcome"`
+	StatusCode    int          `json:"status_code"`
+	DurationMs    int64        `json:"duration_ms"`
+	SizeBytes     int64        `json:"size_bytes"`
+	// Checksum is the hex SHA-256 of the document sent.
+	Checksum string `json:"checksum"`
+	ClientIP string `json:"client_ip"`
+}
+
+// AuditFilter selects audit entries. Zero fields do not restrict.
+type AuditFilter struct {
+	UserID        uuid.UUID
+	OrgID         uuid.UUID
+	Role          UserRole
+	Action        string
+	Outcome       AuditOutcome
+	TargetID      uuid.UUID
+	ActID         uuid.UUID
+	ForeignTarget *bool
+	// From and To limit the time of the request, To exclusive.
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
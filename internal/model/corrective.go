@@ -0,0 +1,96 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ActKind string
+
+const (
+	ActKindRegular    ActKind = "REGULAR"
+	ActKindCorrective ActKind = "CORRECTIVE"
+)
+
+// GroupTotals are the per-group figures of an issued act.
+type GroupTotals struct {
+	GroupIndex  int
+	GroupID     uuid.UUID
+	GroupName   string
+	TripCount   int64
+	VolumeM3    float64
+	NetAmount   float64
+	VATAmount   float64
+	GrossAmount float64
+}
+
+// Sub returns t - other for every figure.
+func (t GroupTotals) Sub(other GroupTotals) GroupTotals {
+	return GroupTotals{
+		GroupIndex:  t.GroupIndex,
+		GroupID:     t.GroupID,
+		GroupName:   t.GroupName,
+		TripCount:   t.TripCount - other.TripCount,
+		VolumeM3:    t.VolumeM3 - other.VolumeM3,
+		NetAmount:   t.NetAmount - other.NetAmount,
+		VATAmount:   t.VATAmount - other.VATAmount,
+		GrossAmount: t.GrossAmount - other.GrossAmount,
+	}
+}
+
+// Add returns t + other for every figure.
+func (t GroupTotals) Add(other GroupTotals) GroupTotals {
+	t.TripCount += other.TripCount
+	t.VolumeM3 += other.VolumeM3
+	t.NetAmount += other.NetAmount
+	t.VATAmount += other.VATAmount
+	t.GrossAmount += other.GrossAmount
+	return t
+}
+
+// CorrectionRow compares one group of the original act ("было") with the
+// recalculated figures ("стало").
+type CorrectionRow struct {
+	GroupName string
+	Before    GroupTotals
+	After     GroupTotals
+}
+
+func (r CorrectionRow) Delta() GroupTotals {
+	return r.After.Sub(r.Before)
+}
+
+// CorrectiveReport is a corrective act: it references the original act and
+// only bills the difference between the last issued and the current data.
+type CorrectiveReport struct {
+	Number   string
+	IssuedAt time.Time
+	Reprint  bool
+	// Lang is the language the document is rendered in; empty means the
+	// default language.
+	Lang string
+
+	OriginalID       uuid.UUID
+	OriginalNumber   string
+	OriginalIssuedAt time.Time
+	// PreviousNumber and PreviousIssuedAt identify the corrective act whose
+	// figures are the "было" column when the original act was corrected
+	// before; PreviousNumber is empty otherwise.
+	PreviousNumber   string
+	PreviousIssuedAt time.Time
+
+	Mode        ReportMode
+	Target      Organization
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	VATRate     float64
+
+	Rows   []CorrectionRow
+	Before GroupTotals
+	After  GroupTotals
+}
+
+func (r CorrectiveReport) Delta() GroupTotals {
+	return r.After.Sub(r.Before)
+}
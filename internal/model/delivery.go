@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Recipient is an email address the acts of an organization are sent to.
+type Recipient struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Email          string    `json:"email"`
+	// Lang is the language of the email, "ru" or "kk".
+	Lang      string    `json:"lang"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryQueued DeliveryStatus = "QUEUED"
+	DeliverySent   DeliveryStatus = "SENT"
+	DeliveryFailed DeliveryStatus = "FAILED"
+)
+
+// ActDelivery is the email of an act to one recipient. A queued delivery is
+// retried until it is sent or runs out of attempts.
+type ActDelivery struct {
+	ID             uuid.UUID      `json:"id"`
+	ActID          uuid.UUID      `json:"act_id"`
+	OrganizationID uuid.UUID      `json:"organization_id"`
+	Email          string         `json:"email"`
+	Lang           string         `json:"lang"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	Error          string         `json:"error"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	SentAt         *time.Time     `json:"sent_at"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DriftKind string
+
+const (
+	DriftAdded   DriftKind = "ADDED"
+	DriftRemoved DriftKind = "REMOVED"
+	DriftChanged DriftKind = "CHANGED"
+)
+
+// DriftChange is one ANPR event that differs between the act snapshot and
+// the current data. VolumeBefore is nil for added events and VolumeAfter is
+// nil for removed ones.
+type DriftChange struct {
+	Kind         DriftKind `json:"kind"`
+	EventID      uuid.UUID `json:"event_id"`
+	EventTime    time.Time `json:"event_time"`
+	Plate        string    `json:"plate"`
+	GroupName    string    `json:"group_name"`
+	VolumeBefore *float64  `json:"volume_before"`
+	VolumeAfter  *float64  `json:"volume_after"`
+}
+
+// DriftReport compares an issued act with the data currently in anpr_events.
+type DriftReport struct {
+	ActID              uuid.UUID     `json:"act_id"`
+	ActNumber          string        `json:"act_number"`
+	Mode               ReportMode    `json:"mode"`
+	TargetID           uuid.UUID     `json:"target_id"`
+	TargetName         string        `json:"target_name"`
+	PeriodStart        time.Time     `json:"period_start"`
+	PeriodEnd          time.Time     `json:"period_end"`
+	IssuedAt           *time.Time    `json:"issued_at"`
+	CheckedAt          time.Time     `json:"checked_at"`
+	Fingerprint        string        `json:"fingerprint"`
+	CurrentFingerprint string        `json:"current_fingerprint"`
+	HasDrift           bool          `json:"has_drift"`
+	Added              []DriftChange `json:"added"`
+	Removed            []DriftChange `json:"removed"`
+	Changed            []DriftChange `json:"changed"`
+	// Lang is the language the report is exported in; empty means the
+	// default language.
+	Lang string `json:"-"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ExportJobStatus string
+
+const (
+	ExportJobQueued  ExportJobStatus = "QUEUED"
+	ExportJobRunning ExportJobStatus = "RUNNING"
+	ExportJobDone    ExportJobStatus = "DONE"
+	ExportJobFailed  ExportJobStatus = "FAILED"
+)
+
+// ExportJob is an act export run in the background. The job keeps the
+// requesting principal, so the export is checked with the same permissions as
+// a direct request.
+type ExportJob struct {
+	ID            uuid.UUID       `json:"id"`
+	Status        ExportJobStatus `json:"status"`
+	Progress      int             `json:"progress"`
+	Format        string          `json:"format"`
+	Lang          string          `json:"lang"`
+	Mode          ReportMode      `json:"mode"`
+	TargetID      uuid.UUID       `json:"target_id"`
+	LandfillID    *uuid.UUID      `json:"landfill_id"`
+	PeriodStart   time.Time       `json:"period_start"`
+	PeriodEnd     time.Time       `json:"period_end"`
+	FileName      string          `json:"file_name"`
+	SizeBytes     int64           `json:"size_bytes"`
+	Error         string          `json:"error"`
+	Attempts      int             `json:"attempts"`
+	CreatedBy     uuid.UUID       `json:"created_by"`
+	CreatedByOrg  uuid.UUID       `json:"created_by_org"`
+	CreatedByRole UserRole        `json:"-"`
+	CreatedAt     time.Time       `json:"created_at"`
+	StartedAt     *time.Time      `json:"started_at"`
+	FinishedAt    *time.Time      `json:"finished_at"`
+
+	// ActID is the ID the act of the job is registered under. It is chosen
+	// when the job is queued, so a restarted job finds the act of an earlier
+	// attempt instead of registering another one.
+	ActID uuid.UUID `json:"act_id"`
+
+	// HideEmptyGroups leaves groups without trips out of the document.
+	HideEmptyGroups bool `json:"hide_empty_groups"`
+
+	// StorageKey is where the document of a finished job is kept in the file
+	// store. Jobs finished before documents were stored there keep it in
+	// export_jobs.content and have no key.
+	StorageKey string `json:"-"`
+}
+
+// Principal returns the principal the job was requested by.
+func (j ExportJob) Principal() Principal {
+	return Principal{UserID: j.CreatedBy, OrgID: j.CreatedByOrg, Role: j.CreatedByRole}
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LandfillCamera maps an ANPR camera to the landfill it was installed at
+// during [ValidFrom, ValidTo). ValidTo is nil while the camera is in place.
+type LandfillCamera struct {
+	ID           uuid.UUID  `json:"id"`
+	CameraID     string     `json:"camera_id"`
+	LandfillID   uuid.UUID  `json:"landfill_id"`
+	LandfillName string     `json:"landfill_name"`
+	ValidFrom    time.Time  `json:"valid_from"`
+	ValidTo      *time.Time `json:"valid_to"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ActPreview is what an act would contain if exported now. It is returned as
+// JSON so the act can be shown on screen before it is issued.
+type ActPreview struct {
+	Mode          ReportMode          `json:"mode"`
+	Target        PreviewOrganization `json:"target"`
+	PeriodStart   time.Time           `json:"period_start"`
+	PeriodEnd     time.Time           `json:"period_end"`
+	Statuses      []string            `json:"statuses"`
+	TotalTrips    int64               `json:"total_trips"`
+	TotalVolumeM3 float64             `json:"total_volume_m3"`
+	VATRate       float64             `json:"vat_rate"`
+	NetAmount     float64             `json:"net_amount"`
+	VATAmount     float64             `json:"vat_amount"`
+	GrossAmount   float64             `json:"gross_amount"`
+	Groups        []PreviewGroup      `json:"groups"`
+	// Trips is set only when trips were requested.
+	Trips *PreviewTripPage `json:"trips,omitempty"`
+}
+
+type PreviewOrganization struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	BIN  string    `json:"bin"`
+}
+
+type PreviewGroup struct {
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	TripCount     int64     `json:"trip_count"`
+	VolumeM3      float64   `json:"volume_m3"`
+	UnpricedTrips int64     `json:"unpriced_trips"`
+	NetAmount     float64   `json:"net_amount"`
+	VATAmount     float64   `json:"vat_amount"`
+	GrossAmount   float64   `json:"gross_amount"`
+}
+
+// PreviewTripPage is one page of the act trips ordered by event time.
+type PreviewTripPage struct {
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+	Total    int64         `json:"total"`
+	Items    []PreviewTrip `json:"items"`
+}
+
+type PreviewTrip struct {
+	EventID      uuid.UUID `json:"event_id"`
+	EventTime    time.Time `json:"event_time"`
+	Plate        *string   `json:"plate"`
+	GroupID      uuid.UUID `json:"group_id"`
+	GroupName    string    `json:"group_name"`
+	SnowVolumeM3 *float64  `json:"snow_volume_m3"`
+}
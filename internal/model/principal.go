@@ -27,6 +27,10 @@ func (p Principal) IsAkimat() bool {
 	return p.Role == UserRoleAkimatAdmin || p.Role == UserRoleAkimatUser
 }
 
+func (p Principal) IsAkimatAdmin() bool {
+	return p.Role == UserRoleAkimatAdmin
+}
+
 func (p Principal) IsKgu() bool {
 	return p.Role == UserRoleKguZkhAdmin || p.Role == UserRoleKguZkhUser
 }
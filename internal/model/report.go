@@ -11,16 +11,30 @@ type ReportMode string
 const (
 	ReportModeContractor ReportMode = "CONTRACTOR"
 	ReportModeLandfill   ReportMode = "LANDFILL"
+	// ReportModeCity is the city-wide act of the customer: every contractor
+	// across every landfill, grouped by contractor.
+	ReportModeCity ReportMode = "CITY"
+	// ReportModePair is the act of one contractor at one landfill: the target
+	// is the contractor and the only group is the landfill.
+	ReportModePair ReportMode = "PAIR"
 )
 
 type TripGroup struct {
-	ID        uuid.UUID
-	Name      string
-	TripCount int64
-	Trips     []TripDetail `gorm:"-"`
+	ID            uuid.UUID
+	Name          string
+	TripCount     int64
+	VolumeM3      float64       `gorm:"-"`
+	UnpricedTrips int64         `gorm:"-"`
+	NetAmount     float64       `gorm:"-"`
+	VATAmount     float64       `gorm:"-"`
+	GrossAmount   float64       `gorm:"-"`
+	Charges       []ChargeLine  `gorm:"-"`
+	Organization  *Organization `gorm:"-"`
+	Trips         []TripDetail  `gorm:"-"`
 }
 
 type TripDetail struct {
+	EventID        uuid.UUID
 	EventTime      time.Time
 	Plate          *string
 	PolygonID      *uuid.UUID
@@ -31,10 +45,114 @@ type TripDetail struct {
 }
 
 type ActReport struct {
-	Mode        ReportMode
-	Target      Organization
-	PeriodStart time.Time
-	PeriodEnd   time.Time
-	TotalTrips  int64
-	Groups      []TripGroup
+	Number   string
+	IssuedAt time.Time
+	// Reprint marks a document rendered again from the act snapshot.
+	Reprint       bool
+	Mode          ReportMode
+	Target        Organization
+	PeriodStart   time.Time
+	PeriodEnd     time.Time
+	TotalTrips    int64
+	TotalVolumeM3 float64
+	// Statuses lists the ANPR event statuses counted in the act.
+	Statuses    []string
+	VATRate     float64
+	NetAmount   float64
+	VATAmount   float64
+	GrossAmount float64
+	// WorkDescription names the billed work in the act of completed works.
+	WorkDescription string
+	// Lang is the language the act documents are rendered in; empty means
+	// the default language.
+	Lang string
+	// TimeZone is the IANA name of the zone the period days were counted in
+	// and event times are shown in; empty for acts issued before it was
+	// recorded, whose period days are UTC days.
+	TimeZone string
+	// HideEmptyGroups leaves the groups without trips out of the documents.
+	HideEmptyGroups bool
+	Groups          []TripGroup
+	// Matrix is the contractor × landfill summary of a city-wide act; nil in
+	// the other modes.
+	Matrix *CityMatrix
+	// Landfill is the landfill of a pair act; nil in the other modes.
+	Landfill *Organization
+}
+
+// CityMatrix breaks a city-wide act down by contractor and landfill. The
+// contractors are the report groups, which carry their own subtotals.
+type CityMatrix struct {
+	// Landfills are the matrix columns with their subtotals, by name.
+	Landfills []MatrixTotal
+	// Cells hold every contractor and landfill pair with trips, in the order
+	// of the groups and then of the landfills.
+	Cells []MatrixCell
+}
+
+// MatrixTotal is the subtotal of one landfill of a city-wide act: the shares
+// of the group amounts that fall on the landfill, so the subtotals add up to
+// the act totals.
+type MatrixTotal struct {
+	ID          uuid.UUID
+	Name        string
+	TripCount   int64
+	VolumeM3    float64
+	NetAmount   float64
+	VATAmount   float64
+	GrossAmount float64
+}
+
+// MatrixCell is what one contractor delivered to one landfill.
+type MatrixCell struct {
+	ContractorID uuid.UUID
+	LandfillID   uuid.UUID
+	TripCount    int64
+	VolumeM3     float64
+	NetAmount    float64
+}
+
+// Cell returns the cell of the contractor and landfill pair; the zero cell
+// when the pair has no trips.
+func (m *CityMatrix) Cell(contractorID, landfillID uuid.UUID) MatrixCell {
+	for _, cell := range m.Cells {
+		if cell.ContractorID == contractorID && cell.LandfillID == landfillID {
+			return cell
+		}
+	}
+	return MatrixCell{ContractorID: contractorID, LandfillID: landfillID}
+}
+
+// Location returns the zone of TimeZone; UTC when it is not recorded or
+// unknown.
+func (r ActReport) Location() *time.Location {
+	if r.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Shown reports whether group is printed in the documents of the report.
+func (r ActReport) Shown(group TripGroup) bool {
+	return group.TripCount > 0 || !r.HideEmptyGroups
+}
+
+// TripSource passes the trips of a report to fn group by group, in the order
+// of the groups, without requiring them all in memory.
+type TripSource func(fn func(groupIndex int, trip TripDetail) error) error
+
+// EachTrip is the TripSource of a report that holds its trips.
+func (r ActReport) EachTrip(fn func(groupIndex int, trip TripDetail) error) error {
+	for i, group := range r.Groups {
+		for _, trip := range group.Trips {
+			if err := fn(i, trip); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScheduledAct is a document of an act issued by a schedule and put into the
+// file storage. Every act has one record per format, stored with the act;
+// StoredAt is set once the document is in the storage.
+type ScheduledAct struct {
+	ID          uuid.UUID  `json:"id"`
+	Schedule    string     `json:"schedule"`
+	ActID       uuid.UUID  `json:"act_id"`
+	ActNumber   string     `json:"act_number"`
+	Mode        ReportMode `json:"mode"`
+	TargetID    uuid.UUID  `json:"target_id"`
+	PeriodStart time.Time  `json:"period_start"`
+	PeriodEnd   time.Time  `json:"period_end"`
+	Format      string     `json:"format"`
+	FileName    string     `json:"file_name"`
+	StorageKey  string     `json:"storage_key"`
+	SizeBytes   int64      `json:"size_bytes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StoredAt    *time.Time `json:"stored_at"`
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TariffUnit string
+
+const (
+	TariffUnitM3   TariffUnit = "M3"
+	TariffUnitTrip TariffUnit = "TRIP"
+)
+
+// Tariff is a price valid for [ValidFrom, ValidTo] (both dates inclusive, open
+// ended when ValidTo is nil). Empty LandfillID/ContractorID match any
+// organization, so a tariff may be general, per landfill, per contractor or
+// per pair. Periods of tariffs with the same landfill and contractor never
+// overlap.
+type Tariff struct {
+	ID             uuid.UUID  `json:"id"`
+	LandfillID     *uuid.UUID `json:"landfill_id"`
+	LandfillName   string     `json:"landfill_name"`
+	ContractorID   *uuid.UUID `json:"contractor_id"`
+	ContractorName string     `json:"contractor_name"`
+	Unit           TariffUnit `json:"unit"`
+	Price          float64    `json:"price"`
+	ValidFrom      time.Time  `json:"valid_from"`
+	ValidTo        *time.Time `json:"valid_to"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// ChargeLine is the part of a group billed under one tariff.
+type ChargeLine struct {
+	TariffID  uuid.UUID
+	Unit      TariffUnit
+	Price     float64
+	Quantity  float64
+	TripCount int64
+	NetAmount float64
+	FirstDate time.Time
+	LastDate  time.Time
+}
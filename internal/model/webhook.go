@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Events webhooks can subscribe to.
+const (
+	// WebhookEventActGenerated fires when an act is issued with a number.
+	WebhookEventActGenerated = "act.generated"
+)
+
+// WebhookEvents lists every event a subscription may name.
+var WebhookEvents = []string{WebhookEventActGenerated}
+
+// WebhookSubscription is an external endpoint events are posted to. Bodies
+// are signed with the secret, which is only shown when it is set.
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedBy uuid.UUID `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Subscribes reports whether the subscription wants event.
+func (s WebhookSubscription) Subscribes(event string) bool {
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookStatus string
+
+const (
+	WebhookQueued WebhookStatus = "QUEUED"
+	WebhookSent   WebhookStatus = "SENT"
+	WebhookFailed WebhookStatus = "FAILED"
+)
+
+// WebhookDelivery is an event in the outbox of one subscription. It is
+// written in the transaction that issues the act, so no event is lost, and
+// retried until the endpoint accepts it or it runs out of attempts.
+type WebhookDelivery struct {
+	ID             uuid.UUID     `json:"id"`
+	SubscriptionID uuid.UUID     `json:"subscription_id"`
+	Event          string        `json:"event"`
+	ActID          uuid.UUID     `json:"act_id"`
+	Status         WebhookStatus `json:"status"`
+	Attempts       int           `json:"attempts"`
+	// ResponseStatus is the HTTP status of the last attempt, zero when the
+	// endpoint was not reached.
+	ResponseStatus int        `json:"response_status"`
+	Error          string     `json:"error"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	SentAt         *time.Time `json:"sent_at"`
+}
@@ -0,0 +1,208 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// GenerateCompletedWorks renders the act of completed works (form P-1). One
+// act is printed per landfill-contractor pair that has trips in the period:
+// the landfill is the executor and the contractor is the customer.
+func (g *Generator) GenerateCompletedWorks(report model.ActReport) ([]byte, error) {
+	l := labels.For(report.Lang)
+	p := gofpdf.New("P", "mm", "A4", "")
+	if err := configureUnicodeFont(p); err != nil {
+		return nil, err
+	}
+	p.SetTitle(l.CompletedWorks, true)
+	p.SetAuthor("snowops-acts-service", false)
+	p.SetMargins(10, 10, 10)
+	p.SetAutoPageBreak(true, 10)
+	setDeterministic(p, report)
+	markReprint(p, report, strings.ToUpper(l.Reprint))
+
+	printed := false
+	for _, group := range report.Groups {
+		if group.TripCount == 0 || group.Organization == nil {
+			continue
+		}
+		customer, executor := completedWorksParties(report, *group.Organization)
+		writeCompletedWorksPage(p, l, report, group, customer, executor)
+		printed = true
+	}
+	if !printed {
+		customer, executor := completedWorksParties(report, model.Organization{})
+		writeCompletedWorksPage(p, l, report, model.TripGroup{}, customer, executor)
+	}
+
+	var out bytes.Buffer
+	if err := p.Output(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func completedWorksParties(report model.ActReport, counterparty model.Organization) (customer, executor model.Organization) {
+	if report.Mode == model.ReportModeLandfill {
+		return counterparty, report.Target
+	}
+	return report.Target, counterparty
+}
+
+func writeCompletedWorksPage(
+	p *gofpdf.Fpdf,
+	l labels.Labels,
+	report model.ActReport,
+	group model.TripGroup,
+	customer, executor model.Organization,
+) {
+	p.AddPage()
+
+	p.SetFont("Unicode", "", 7)
+	p.MultiCell(0, 3.5, l.FormP1Note, "", "R", false)
+	p.Ln(2)
+
+	p.SetFont("Unicode", "", 9)
+	writePartyBlock(p, l, l.Customer, customer)
+	writePartyBlock(p, l, l.Executor, executor)
+	p.Ln(2)
+
+	p.CellFormat(120, 6, "", "", 0, "L", false, 0, "")
+	p.CellFormat(40, 6, l.DocumentNumber, "1", 0, "C", false, 0, "")
+	p.CellFormat(30, 6, l.DocumentDate, "1", 1, "C", false, 0, "")
+	p.CellFormat(120, 6, "", "", 0, "L", false, 0, "")
+	p.CellFormat(40, 6, report.Number, "1", 0, "C", false, 0, "")
+	p.CellFormat(30, 6, l.FormatDate(report.IssuedAt), "1", 1, "C", false, 0, "")
+	p.Ln(3)
+
+	p.SetFont("Unicode", "", 12)
+	p.CellFormat(0, 7, l.CompletedWorksTitle, "", 1, "C", false, 0, "")
+	p.SetFont("Unicode", "", 9)
+	p.CellFormat(0, 5, fmt.Sprintf(l.PeriodFromToFormat, l.FormatDate(report.PeriodStart), l.FormatDate(report.PeriodEnd)), "", 1, "C", false, 0, "")
+	p.Ln(3)
+
+	widths := []float64{10, 62, 28, 16, 22, 24, 28}
+	headers := []string{
+		l.RowNumber,
+		l.WorkName,
+		l.WorkDate,
+		l.MeasureUnit,
+		l.Quantity,
+		l.UnitPrice,
+		l.Cost,
+	}
+	p.SetFont("Unicode", "", 8)
+	writeTableRow(p, widths, headers, []string{"C", "C", "C", "C", "C", "C", "C"})
+
+	rows := completedWorksRows(l, report, group)
+	aligns := []string{"C", "L", "C", "C", "R", "R", "R"}
+	for i, row := range rows {
+		writeTableRow(p, widths, append([]string{strconv.Itoa(i + 1)}, row...), aligns)
+	}
+
+	labelWidth := widths[0] + widths[1] + widths[2] + widths[3] + widths[4] + widths[5]
+	p.SetFont("Unicode", "", 9)
+	p.CellFormat(labelWidth, 6, l.TotalNet, "1", 0, "R", false, 0, "")
+	p.CellFormat(widths[6], 6, fmt.Sprintf("%.2f", group.NetAmount), "1", 1, "R", false, 0, "")
+	p.CellFormat(labelWidth, 6, l.VATRate(report.VATRate), "1", 0, "R", false, 0, "")
+	p.CellFormat(widths[6], 6, fmt.Sprintf("%.2f", group.VATAmount), "1", 1, "R", false, 0, "")
+	p.CellFormat(labelWidth, 6, l.TotalGross, "1", 0, "R", false, 0, "")
+	p.CellFormat(widths[6], 6, fmt.Sprintf("%.2f", group.GrossAmount), "1", 1, "R", false, 0, "")
+	p.Ln(8)
+
+	writeSignatureBlocks(p, l, executor, customer)
+}
+
+func writePartyBlock(p *gofpdf.Fpdf, l labels.Labels, label string, org model.Organization) {
+	text := fmt.Sprintf("%s: %s", label, org.Name)
+	if org.BIN != "" {
+		text += fmt.Sprintf(", %s %s", l.BIN, org.BIN)
+	}
+	if org.Address != "" {
+		text += fmt.Sprintf(", %s", org.Address)
+	}
+	if org.Phone != "" {
+		text += fmt.Sprintf(", %s %s", l.Phone, org.Phone)
+	}
+	p.MultiCell(0, 5, text, "", "L", false)
+}
+
+// completedWorksRows returns the work lines of a group: one line per tariff
+// applied in the period, plus a line for trips without a tariff.
+func completedWorksRows(l labels.Labels, report model.ActReport, group model.TripGroup) [][]string {
+	rows := make([][]string, 0, len(group.Charges)+1)
+	for _, line := range group.Charges {
+		rows = append(rows, []string{
+			report.WorkDescription,
+			fmt.Sprintf("%s - %s", l.FormatDate(line.FirstDate), l.FormatDate(line.LastDate)),
+			l.TariffUnit(line.Unit),
+			fmt.Sprintf("%.3f", line.Quantity),
+			fmt.Sprintf("%.2f", line.Price),
+			fmt.Sprintf("%.2f", line.NetAmount),
+		})
+	}
+	if group.UnpricedTrips > 0 || len(rows) == 0 {
+		rows = append(rows, []string{
+			report.WorkDescription,
+			fmt.Sprintf("%s - %s", l.FormatDate(report.PeriodStart), l.FormatDate(report.PeriodEnd)),
+			l.TariffUnit(model.TariffUnitTrip),
+			strconv.FormatInt(group.UnpricedTrips, 10),
+			"",
+			"",
+		})
+	}
+	return rows
+}
+
+func writeTableRow(p *gofpdf.Fpdf, widths []float64, values []string, aligns []string) {
+	const lineHeight = 4.5
+	lines := 1
+	for i, value := range values {
+		if n := len(p.SplitText(value, widths[i]-2)); n > lines {
+			lines = n
+		}
+	}
+	height := float64(lines) * lineHeight
+
+	_, pageHeight := p.GetPageSize()
+	_, _, _, bottom := p.GetMargins()
+	if p.GetY()+height > pageHeight-bottom {
+		p.AddPage()
+	}
+
+	x, y := p.GetXY()
+	for i, value := range values {
+		p.Rect(x, y, widths[i], height, "D")
+		p.SetXY(x, y)
+		p.MultiCell(widths[i], lineHeight, value, "", aligns[i], false)
+		x += widths[i]
+	}
+	left, _, _, _ := p.GetMargins()
+	p.SetXY(left, y+height)
+}
+
+func writeSignatureBlocks(p *gofpdf.Fpdf, l labels.Labels, executor, customer model.Organization) {
+	const half = 95
+	p.SetFont("Unicode", "", 9)
+	p.CellFormat(half, 6, l.Delivered, "", 0, "L", false, 0, "")
+	p.CellFormat(half, 6, l.Accepted, "", 1, "L", false, 0, "")
+	p.CellFormat(half, 6, trim(executor.Name, 50), "", 0, "L", false, 0, "")
+	p.CellFormat(half, 6, trim(customer.Name, 50), "", 1, "L", false, 0, "")
+	p.Ln(4)
+	p.CellFormat(half, 6, fmt.Sprintf(l.SignatureLineFormat, executor.HeadFullName), "", 0, "L", false, 0, "")
+	p.CellFormat(half, 6, fmt.Sprintf(l.SignatureLineFormat, customer.HeadFullName), "", 1, "L", false, 0, "")
+	p.SetFont("Unicode", "", 7)
+	p.CellFormat(half, 4, l.SignatureCaption, "", 0, "L", false, 0, "")
+	p.CellFormat(half, 4, l.SignatureCaption, "", 1, "L", false, 0, "")
+	p.Ln(6)
+	p.SetFont("Unicode", "", 9)
+	p.CellFormat(half, 6, l.SealPlace, "", 0, "L", false, 0, "")
+	p.CellFormat(half, 6, l.SealPlace, "", 1, "L", false, 0, "")
+}
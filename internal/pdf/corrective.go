@@ -0,0 +1,106 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// GenerateCorrective renders a corrective act on a landscape page: for every
+// group the figures of the original act, the recalculated ones and the
+// difference.
+func (g *Generator) GenerateCorrective(report model.CorrectiveReport) ([]byte, error) {
+	l := labels.For(report.Lang)
+	modeLabel, groupLabel := l.Mode(report.Mode)
+
+	p := gofpdf.New("L", "mm", "A4", "")
+	if err := configureUnicodeFont(p); err != nil {
+		return nil, err
+	}
+	p.SetTitle(l.Corrective, true)
+	p.SetAuthor("snowops-acts-service", false)
+	p.SetMargins(10, 10, 10)
+	p.SetAutoPageBreak(true, 10)
+	setDeterministic(p, model.ActReport{IssuedAt: report.IssuedAt})
+	markReprint(p, model.ActReport{Reprint: report.Reprint}, strings.ToUpper(l.Reprint))
+	p.AddPage()
+
+	p.SetFont("Unicode", "", 14)
+	p.CellFormat(0, 8, l.NumberDate(l.CorrectiveTitleFormat, report.Number, report.IssuedAt), "", 1, "C", false, 0, "")
+	p.SetFont("Unicode", "", 11)
+	p.CellFormat(0, 6, l.NumberDate(l.OriginalActFormat, report.OriginalNumber, report.OriginalIssuedAt), "", 1, "C", false, 0, "")
+	if report.PreviousNumber != "" {
+		p.CellFormat(0, 6, l.NumberDate(l.PreviousActFormat, report.PreviousNumber, report.PreviousIssuedAt), "", 1, "C", false, 0, "")
+	}
+	p.Ln(4)
+
+	p.SetFont("Unicode", "", 10)
+	p.Cell(0, 6, fmt.Sprintf("%s: %s", l.ReportType, modeLabel))
+	p.Ln(6)
+	p.Cell(0, 6, fmt.Sprintf("%s: %s", l.Organization, report.Target.Name))
+	p.Ln(6)
+	p.Cell(0, 6, fmt.Sprintf("%s: %s - %s", l.Period, l.FormatDate(report.PeriodStart), l.FormatDate(report.PeriodEnd)))
+	p.Ln(8)
+
+	const nameWidth, valueWidth = 52.0, 15.0
+	p.SetFont("Unicode", "", 8)
+	x, y := p.GetXY()
+	p.CellFormat(nameWidth, 12, groupLabel, "1", 0, "C", false, 0, "")
+	for _, metric := range correctiveMetrics {
+		p.CellFormat(valueWidth*3, 6, metric.label(l), "1", 0, "C", false, 0, "")
+	}
+	p.SetXY(x+nameWidth, y+6)
+	for range correctiveMetrics {
+		p.CellFormat(valueWidth, 6, l.Before, "1", 0, "C", false, 0, "")
+		p.CellFormat(valueWidth, 6, l.After, "1", 0, "C", false, 0, "")
+		p.CellFormat(valueWidth, 6, l.Difference, "1", 0, "C", false, 0, "")
+	}
+	p.Ln(6)
+
+	p.SetFont("Unicode", "", 7)
+	writeRow := func(name string, before, after model.GroupTotals) {
+		p.CellFormat(nameWidth, 6, trim(name, 34), "1", 0, "L", false, 0, "")
+		delta := after.Sub(before)
+		for _, metric := range correctiveMetrics {
+			for _, totals := range []model.GroupTotals{before, after, delta} {
+				p.CellFormat(valueWidth, 6, metric.format(totals), "1", 0, "R", false, 0, "")
+			}
+		}
+		p.Ln(6)
+	}
+	for _, row := range report.Rows {
+		writeRow(row.GroupName, row.Before, row.After)
+	}
+	writeRow(l.Total, report.Before, report.After)
+
+	p.Ln(14)
+	p.SetFont("Unicode", "", 10)
+	pageWidth, _ := p.GetPageSize()
+	half := (pageWidth - 20) / 2
+	p.CellFormat(half, 6, l.Customer+": ____________________", "", 0, "L", false, 0, "")
+	p.CellFormat(half, 6, l.Executor+": ____________________", "", 1, "L", false, 0, "")
+
+	var out bytes.Buffer
+	if err := p.Output(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+type correctiveMetric struct {
+	label  func(labels.Labels) string
+	format func(model.GroupTotals) string
+}
+
+var correctiveMetrics = []correctiveMetric{
+	{func(l labels.Labels) string { return l.ColTrips }, func(t model.GroupTotals) string { return fmt.Sprintf("%d", t.TripCount) }},
+	{func(l labels.Labels) string { return l.ColVolume }, func(t model.GroupTotals) string { return fmt.Sprintf("%.3f", t.VolumeM3) }},
+	{func(l labels.Labels) string { return l.NetAmount }, func(t model.GroupTotals) string { return fmt.Sprintf("%.2f", t.NetAmount) }},
+	{func(l labels.Labels) string { return l.VATAmount }, func(t model.GroupTotals) string { return fmt.Sprintf("%.2f", t.VATAmount) }},
+	{func(l labels.Labels) string { return l.GrossAmount }, func(t model.GroupTotals) string { return fmt.Sprintf("%.2f", t.GrossAmount) }},
+}
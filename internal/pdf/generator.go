@@ -6,10 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
 
 	"github.com/jung-kurt/gofpdf"
 
+	"github.com/nurpe/snowops-acts/internal/labels"
 	"github.com/nurpe/snowops-acts/internal/model"
 )
 
@@ -20,42 +21,73 @@ func NewGenerator() *Generator {
 }
 
 func (g *Generator) Generate(report model.ActReport) ([]byte, error) {
+	l := labels.For(report.Lang)
+	loc := report.Location()
+	modeLabel, groupLabel := l.Mode(report.Mode)
+	relatedLabel := l.Landfill
+	if report.Mode == model.ReportModeLandfill {
+		relatedLabel = l.Contractor
+	}
+
 	p := gofpdf.New("P", "mm", "A4", "")
 	if err := configureUnicodeFont(p); err != nil {
 		return nil, err
 	}
-	p.SetTitle("SnowOps Acts Report", false)
+	p.SetTitle(l.ActTitle(report.Number), true)
 	p.SetAuthor("snowops-acts-service", false)
 	p.SetMargins(10, 10, 10)
 	p.SetAutoPageBreak(true, 10)
+	setDeterministic(p, report)
+	markReprint(p, report, strings.ToUpper(l.Reprint))
 	p.AddPage()
 
 	p.SetFont("Unicode", "", 14)
-	p.Cell(0, 8, "Act report")
+	p.Cell(0, 8, l.ActTitle(report.Number))
 	p.Ln(10)
 
 	p.SetFont("Unicode", "", 11)
-	p.Cell(0, 6, fmt.Sprintf("Mode: %s", string(report.Mode)))
+	p.Cell(0, 6, fmt.Sprintf("%s: %s", l.ReportType, modeLabel))
+	p.Ln(6)
+	p.Cell(0, 6, fmt.Sprintf("%s: %s", l.Organization, report.Target.Name))
+	p.Ln(6)
+	p.Cell(0, 6, fmt.Sprintf("%s: %s - %s", l.Period, l.FormatDate(report.PeriodStart), l.FormatDate(report.PeriodEnd)))
+	p.Ln(6)
+	p.Cell(0, 6, fmt.Sprintf("%s: %s", l.Statuses, strings.Join(report.Statuses, ", ")))
+	p.Ln(6)
+	p.Cell(0, 6, fmt.Sprintf("%s: %d", l.TripCount, report.TotalTrips))
 	p.Ln(6)
-	p.Cell(0, 6, fmt.Sprintf("Organization: %s", report.Target.Name))
+	p.Cell(0, 6, fmt.Sprintf("%s: %.2f", l.SnowVolume, report.TotalVolumeM3))
 	p.Ln(6)
-	p.Cell(0, 6, fmt.Sprintf("Period: %s - %s", formatDate(report.PeriodStart), formatDate(report.PeriodEnd)))
+	p.Cell(0, 6, fmt.Sprintf("%s: %.2f", l.NetAmount, report.NetAmount))
 	p.Ln(6)
-	p.Cell(0, 6, fmt.Sprintf("Total trips: %d", report.TotalTrips))
+	p.Cell(0, 6, fmt.Sprintf("%s: %.2f", l.VAT(report.VATRate), report.VATAmount))
 	p.Ln(6)
-	p.Cell(0, 6, fmt.Sprintf("Total volume (m3): %.2f", sumReportVolume(report)))
+	p.Cell(0, 6, fmt.Sprintf("%s: %.2f", l.GrossAmount, report.GrossAmount))
 	p.Ln(10)
 
 	p.SetFont("Unicode", "", 10)
-	p.CellFormat(70, 7, groupLabel(report.Mode), "1", 0, "L", false, 0, "")
-	p.CellFormat(32, 7, "Trips", "1", 0, "C", false, 0, "")
-	p.CellFormat(32, 7, "Volume (m3)", "1", 1, "R", false, 0, "")
+	p.CellFormat(60, 7, groupLabel, "1", 0, "L", false, 0, "")
+	p.CellFormat(18, 7, l.ColTrips, "1", 0, "C", false, 0, "")
+	p.CellFormat(26, 7, l.ColVolume, "1", 0, "R", false, 0, "")
+	p.CellFormat(28, 7, l.ColNet, "1", 0, "R", false, 0, "")
+	p.CellFormat(26, 7, l.ColVAT, "1", 0, "R", false, 0, "")
+	p.CellFormat(32, 7, l.ColGross, "1", 1, "R", false, 0, "")
 
 	p.SetFont("Unicode", "", 9)
 	for _, group := range report.Groups {
-		p.CellFormat(70, 6, trim(group.Name, 36), "1", 0, "L", false, 0, "")
-		p.CellFormat(32, 6, fmt.Sprintf("%d", group.TripCount), "1", 0, "C", false, 0, "")
-		p.CellFormat(32, 6, fmt.Sprintf("%.2f", sumGroupVolume(report.Mode, group)), "1", 1, "R", false, 0, "")
+		if !report.Shown(group) {
+			continue
+		}
+		p.CellFormat(60, 6, trim(group.Name, 32), "1", 0, "L", false, 0, "")
+		p.CellFormat(18, 6, fmt.Sprintf("%d", group.TripCount), "1", 0, "C", false, 0, "")
+		p.CellFormat(26, 6, fmt.Sprintf("%.2f", group.VolumeM3), "1", 0, "R", false, 0, "")
+		p.CellFormat(28, 6, fmt.Sprintf("%.2f", group.NetAmount), "1", 0, "R", false, 0, "")
+		p.CellFormat(26, 6, fmt.Sprintf("%.2f", group.VATAmount), "1", 0, "R", false, 0, "")
+		p.CellFormat(32, 6, fmt.Sprintf("%.2f", group.GrossAmount), "1", 1, "R", false, 0, "")
+	}
+
+	if report.Matrix != nil {
+		writeMatrix(p, l, report)
 	}
 
 	for _, group := range report.Groups {
@@ -64,18 +96,41 @@ func (g *Generator) Generate(report model.ActReport) ([]byte, error) {
 		}
 		p.AddPage()
 		p.SetFont("Unicode", "", 12)
-		p.Cell(0, 8, fmt.Sprintf("%s: %s", groupLabel(report.Mode), group.Name))
+		p.Cell(0, 8, fmt.Sprintf("%s: %s", groupLabel, group.Name))
 		p.Ln(10)
 
+		if len(group.Charges) > 0 {
+			p.SetFont("Unicode", "", 9)
+			p.CellFormat(26, 7, l.TariffFrom, "1", 0, "L", false, 0, "")
+			p.CellFormat(26, 7, l.TariffTo, "1", 0, "L", false, 0, "")
+			p.CellFormat(16, 7, l.Unit, "1", 0, "C", false, 0, "")
+			p.CellFormat(26, 7, l.Price, "1", 0, "R", false, 0, "")
+			p.CellFormat(28, 7, l.Quantity, "1", 0, "R", false, 0, "")
+			p.CellFormat(32, 7, l.ColNet, "1", 1, "R", false, 0, "")
+			p.SetFont("Unicode", "", 8)
+			for _, line := range group.Charges {
+				p.CellFormat(26, 6, l.FormatDate(line.FirstDate), "1", 0, "L", false, 0, "")
+				p.CellFormat(26, 6, l.FormatDate(line.LastDate), "1", 0, "L", false, 0, "")
+				p.CellFormat(16, 6, l.TariffUnit(line.Unit), "1", 0, "C", false, 0, "")
+				p.CellFormat(26, 6, fmt.Sprintf("%.2f", line.Price), "1", 0, "R", false, 0, "")
+				p.CellFormat(28, 6, fmt.Sprintf("%.3f", line.Quantity), "1", 0, "R", false, 0, "")
+				p.CellFormat(32, 6, fmt.Sprintf("%.2f", line.NetAmount), "1", 1, "R", false, 0, "")
+			}
+			p.SetFont("Unicode", "", 9)
+			p.Cell(0, 6, fmt.Sprintf("%s: %.2f   %s: %.2f   %s: %.2f",
+				l.ColNet, group.NetAmount, l.ColVAT, group.VATAmount, l.ColGross, group.GrossAmount))
+			p.Ln(8)
+		}
+
 		p.SetFont("Unicode", "", 9)
-		p.CellFormat(36, 7, "Date time", "1", 0, "L", false, 0, "")
-		p.CellFormat(30, 7, "Plate", "1", 0, "L", false, 0, "")
-		p.CellFormat(50, 7, relatedLabel(report.Mode), "1", 0, "L", false, 0, "")
-		p.CellFormat(24, 7, "Volume", "1", 1, "R", false, 0, "")
+		p.CellFormat(36, 7, l.Date, "1", 0, "L", false, 0, "")
+		p.CellFormat(30, 7, l.Plate, "1", 0, "L", false, 0, "")
+		p.CellFormat(50, 7, relatedLabel, "1", 0, "L", false, 0, "")
+		p.CellFormat(24, 7, l.ColVolume, "1", 1, "R", false, 0, "")
 
 		p.SetFont("Unicode", "", 8)
 		for _, trip := range group.Trips {
-			p.CellFormat(36, 6, formatDateTime(trip.EventTime), "1", 0, "L", false, 0, "")
+			p.CellFormat(36, 6, l.FormatDateTime(trip.EventTime.In(loc)), "1", 0, "L", false, 0, "")
 			p.CellFormat(30, 6, trim(strPtr(trip.Plate), 15), "1", 0, "L", false, 0, "")
 			p.CellFormat(50, 6, trim(relatedName(report.Mode, trip), 28), "1", 0, "L", false, 0, "")
 			p.CellFormat(24, 6, fmt.Sprintf("%.2f", floatPtr(trip.SnowVolumeM3)), "1", 1, "R", false, 0, "")
@@ -89,6 +144,29 @@ func (g *Generator) Generate(report model.ActReport) ([]byte, error) {
 	return out.Bytes(), nil
 }
 
+// setDeterministic makes the output depend only on the report, so a reprint
+// from the act snapshot reproduces the original file.
+func setDeterministic(p *gofpdf.Fpdf, report model.ActReport) {
+	p.SetCatalogSort(true)
+	if !report.IssuedAt.IsZero() {
+		p.SetCreationDate(report.IssuedAt)
+		p.SetModificationDate(report.IssuedAt)
+	}
+}
+
+// markReprint prints label in the top margin of every page of a reprint
+// without moving the page content.
+func markReprint(p *gofpdf.Fpdf, report model.ActReport, label string) {
+	if !report.Reprint {
+		return
+	}
+	p.SetHeaderFunc(func() {
+		p.SetFont("Unicode", "", 8)
+		pageWidth, _ := p.GetPageSize()
+		p.Text(pageWidth-10-p.GetStringWidth(label), 7, label)
+	})
+}
+
 func configureUnicodeFont(p *gofpdf.Fpdf) error {
 	fontPath, err := findUnicodeFontPath()
 	if err != nil {
@@ -125,20 +203,6 @@ func findUnicodeFontPath() (string, error) {
 	return "", errors.New("unicode font not found: set PDF_FONT_PATH to a .ttf font with Cyrillic support")
 }
 
-func groupLabel(mode model.ReportMode) string {
-	if mode == model.ReportModeLandfill {
-		return "Contractor"
-	}
-	return "Landfill"
-}
-
-func relatedLabel(mode model.ReportMode) string {
-	if mode == model.ReportModeLandfill {
-		return "Contractor"
-	}
-	return "Landfill"
-}
-
 func relatedName(mode model.ReportMode, trip model.TripDetail) string {
 	if mode == model.ReportModeLandfill {
 		return strPtr(trip.ContractorName)
@@ -146,20 +210,6 @@ func relatedName(mode model.ReportMode, trip model.TripDetail) string {
 	return strPtr(trip.PolygonName)
 }
 
-func formatDate(t time.Time) string {
-	if t.IsZero() {
-		return ""
-	}
-	return t.Format("2006-01-02")
-}
-
-func formatDateTime(t time.Time) string {
-	if t.IsZero() {
-		return ""
-	}
-	return t.Format("2006-01-02 15:04:05")
-}
-
 func strPtr(v *string) string {
 	if v == nil {
 		return ""
@@ -184,24 +234,3 @@ func trim(v string, max int) string {
 	}
 	return string(runes[:max-3]) + "..."
 }
-
-func sumGroupVolume(mode model.ReportMode, group model.TripGroup) float64 {
-	total := 0.0
-	for _, trip := range group.Trips {
-		if trip.SnowVolumeM3 != nil {
-			total += *trip.SnowVolumeM3
-		}
-	}
-	if mode == model.ReportModeLandfill || mode == model.ReportModeContractor {
-		return total
-	}
-	return total
-}
-
-func sumReportVolume(report model.ActReport) float64 {
-	total := 0.0
-	for _, group := range report.Groups {
-		total += sumGroupVolume(report.Mode, group)
-	}
-	return total
-}
@@ -0,0 +1,138 @@
+package pdf
+
+import (
+	"fmt"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+const (
+	matrixNameWidth     = 55.0
+	matrixCellWidth     = 24.0
+	matrixTotalWidth    = 28.0
+	matrixColumnsOnPage = 8
+)
+
+// writeMatrix adds landscape pages with the contractor × landfill matrix of a
+// city-wide act: trips, volume and net amount with the subtotals of every
+// contractor and landfill and the grand totals, then the amounts of every
+// landfill. Landfills that do not fit the page width continue in another
+// table below.
+func writeMatrix(p *gofpdf.Fpdf, l labels.Labels, report model.ActReport) {
+	matrix := report.Matrix
+	var contractors []model.TripGroup
+	for _, group := range report.Groups {
+		if group.TripCount > 0 {
+			contractors = append(contractors, group)
+		}
+	}
+	_, groupLabel := l.Mode(report.Mode)
+
+	blocks := []struct {
+		caption string
+		cell    func(model.MatrixCell) string
+		group   func(model.TripGroup) string
+		column  func(model.MatrixTotal) string
+		total   string
+	}{
+		{
+			caption: l.TripCount,
+			cell:    func(c model.MatrixCell) string { return fmt.Sprintf("%d", c.TripCount) },
+			group:   func(t model.TripGroup) string { return fmt.Sprintf("%d", t.TripCount) },
+			column:  func(t model.MatrixTotal) string { return fmt.Sprintf("%d", t.TripCount) },
+			total:   fmt.Sprintf("%d", report.TotalTrips),
+		},
+		{
+			caption: l.SnowVolume,
+			cell:    func(c model.MatrixCell) string { return fmt.Sprintf("%.2f", c.VolumeM3) },
+			group:   func(t model.TripGroup) string { return fmt.Sprintf("%.2f", t.VolumeM3) },
+			column:  func(t model.MatrixTotal) string { return fmt.Sprintf("%.2f", t.VolumeM3) },
+			total:   fmt.Sprintf("%.2f", report.TotalVolumeM3),
+		},
+		{
+			caption: l.NetAmount,
+			cell:    func(c model.MatrixCell) string { return fmt.Sprintf("%.2f", c.NetAmount) },
+			group:   func(t model.TripGroup) string { return fmt.Sprintf("%.2f", t.NetAmount) },
+			column:  func(t model.MatrixTotal) string { return fmt.Sprintf("%.2f", t.NetAmount) },
+			total:   fmt.Sprintf("%.2f", report.NetAmount),
+		},
+	}
+
+	p.AddPageFormat("L", p.GetPageSizeStr("A4"))
+	p.SetFont("Unicode", "", 12)
+	p.Cell(0, 8, l.Matrix)
+	p.Ln(10)
+
+	for _, block := range blocks {
+		for from := 0; from == 0 || from < len(matrix.Landfills); from += matrixColumnsOnPage {
+			to := min(from+matrixColumnsOnPage, len(matrix.Landfills))
+			landfills := matrix.Landfills[from:to]
+			last := to == len(matrix.Landfills)
+
+			p.SetFont("Unicode", "", 10)
+			p.Cell(0, 6, block.caption)
+			p.Ln(7)
+
+			p.SetFont("Unicode", "", 8)
+			p.CellFormat(matrixNameWidth, 7, trim(groupLabel+" / "+l.Landfill, 34), "1", 0, "L", false, 0, "")
+			for _, landfill := range landfills {
+				p.CellFormat(matrixCellWidth, 7, trim(landfill.Name, 14), "1", 0, "C", false, 0, "")
+			}
+			if last {
+				p.CellFormat(matrixTotalWidth, 7, l.Total, "1", 0, "R", false, 0, "")
+			}
+			p.Ln(-1)
+
+			for _, contractor := range contractors {
+				p.CellFormat(matrixNameWidth, 6, trim(contractor.Name, 34), "1", 0, "L", false, 0, "")
+				for _, landfill := range landfills {
+					value := ""
+					if cell := matrix.Cell(contractor.ID, landfill.ID); cell.TripCount > 0 {
+						value = block.cell(cell)
+					}
+					p.CellFormat(matrixCellWidth, 6, value, "1", 0, "R", false, 0, "")
+				}
+				if last {
+					p.CellFormat(matrixTotalWidth, 6, block.group(contractor), "1", 0, "R", false, 0, "")
+				}
+				p.Ln(-1)
+			}
+
+			p.CellFormat(matrixNameWidth, 6, l.Total, "1", 0, "L", false, 0, "")
+			for _, landfill := range landfills {
+				p.CellFormat(matrixCellWidth, 6, block.column(landfill), "1", 0, "R", false, 0, "")
+			}
+			if last {
+				p.CellFormat(matrixTotalWidth, 6, block.total, "1", 0, "R", false, 0, "")
+			}
+			p.Ln(8)
+		}
+	}
+
+	p.SetFont("Unicode", "", 10)
+	p.CellFormat(60, 7, l.Landfill, "1", 0, "L", false, 0, "")
+	p.CellFormat(18, 7, l.ColTrips, "1", 0, "C", false, 0, "")
+	p.CellFormat(26, 7, l.ColVolume, "1", 0, "R", false, 0, "")
+	p.CellFormat(28, 7, l.ColNet, "1", 0, "R", false, 0, "")
+	p.CellFormat(26, 7, l.ColVAT, "1", 0, "R", false, 0, "")
+	p.CellFormat(32, 7, l.ColGross, "1", 1, "R", false, 0, "")
+
+	p.SetFont("Unicode", "", 9)
+	for _, landfill := range matrix.Landfills {
+		p.CellFormat(60, 6, trim(landfill.Name, 32), "1", 0, "L", false, 0, "")
+		p.CellFormat(18, 6, fmt.Sprintf("%d", landfill.TripCount), "1", 0, "C", false, 0, "")
+		p.CellFormat(26, 6, fmt.Sprintf("%.2f", landfill.VolumeM3), "1", 0, "R", false, 0, "")
+		p.CellFormat(28, 6, fmt.Sprintf("%.2f", landfill.NetAmount), "1", 0, "R", false, 0, "")
+		p.CellFormat(26, 6, fmt.Sprintf("%.2f", landfill.VATAmount), "1", 0, "R", false, 0, "")
+		p.CellFormat(32, 6, fmt.Sprintf("%.2f", landfill.GrossAmount), "1", 1, "R", false, 0, "")
+	}
+	p.CellFormat(60, 6, l.Total, "1", 0, "L", false, 0, "")
+	p.CellFormat(18, 6, fmt.Sprintf("%d", report.TotalTrips), "1", 0, "C", false, 0, "")
+	p.CellFormat(26, 6, fmt.Sprintf("%.2f", report.TotalVolumeM3), "1", 0, "R", false, 0, "")
+	p.CellFormat(28, 6, fmt.Sprintf("%.2f", report.NetAmount), "1", 0, "R", false, 0, "")
+	p.CellFormat(26, 6, fmt.Sprintf("%.2f", report.VATAmount), "1", 0, "R", false, 0, "")
+	p.CellFormat(32, 6, fmt.Sprintf("%.2f", report.GrossAmount), "1", 1, "R", false, 0, "")
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// SaveDriftCheck stores the latest drift check result of an act, replacing
+// the previous one.
+func (r *ActRepository) SaveDriftCheck(ctx context.Context, report *model.DriftReport) error {
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO act_drift_checks (
+			act_id, checked_at, current_fingerprint, has_drift,
+			added_count, removed_count, changed_count, diff
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (act_id) DO UPDATE SET
+			checked_at = EXCLUDED.checked_at,
+			current_fingerprint = EXCLUDED.current_fingerprint,
+			has_drift = EXCLUDED.has_drift,
+			added_count = EXCLUDED.added_count,
+			removed_count = EXCLUDED.removed_count,
+			changed_count = EXCLUDED.changed_count,
+			diff = EXCLUDED.diff
+	`,
+		report.ActID, report.CheckedAt, report.CurrentFingerprint, report.HasDrift,
+		len(report.Added), len(report.Removed), len(report.Changed), string(raw),
+	).Error
+}
+
+// ListDriftChecks returns the latest stored check of every act, newest first.
+func (r *ActRepository) ListDriftChecks(ctx context.Context, onlyDrift bool) ([]model.DriftReport, error) {
+	query := `SELECT diff FROM act_drift_checks`
+	if onlyDrift {
+		query += ` WHERE has_drift = true`
+	}
+	query += ` ORDER BY checked_at DESC`
+
+	rows, err := r.db.WithContext(ctx).Raw(query).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []model.DriftReport
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var report model.DriftReport
+		if err := json.Unmarshal(raw, &report); err != nil {
+			return nil, err
+		}
+		result = append(result, report)
+	}
+	return result, rows.Err()
+}
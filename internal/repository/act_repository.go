@@ -0,0 +1,419 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+type ActRepository struct {
+	db *gorm.DB
+}
+
+func NewActRepository(db *gorm.DB) *ActRepository {
+	return &ActRepository{db: db}
+}
+
+const actSelect = `
+	SELECT
+		a.id,
+		COALESCE(a.number, '') AS number,
+		COALESCE(a.year, 0) AS year,
+		COALESCE(a.sequence, 0) AS sequence,
+		a.status,
+		a.kind,
+		a.original_act_id,
+		a.base_act_id,
+		a.mode,
+		a.target_id,
+		COALESCE(t.name, '') AS target_name,
+		a.landfill_id,
+		COALESCE(lf.name, '') AS landfill_name,
+		a.period_start,
+		a.period_end,
+		a.format,
+		a.total_trips,
+		a.total_volume_m3,
+		a.net_amount,
+		a.vat_amount,
+		a.gross_amount,
+		a.fingerprint,
+		a.created_by,
+		a.created_by_org,
+		a.created_at,
+		a.updated_at,
+		a.issued_at,
+		a.issued_by,
+		a.contractor_signed_at,
+		a.contractor_signed_by,
+		a.accepted_at,
+		a.accepted_by,
+		a.accepted_by_org,
+		a.approved_at,
+		a.approved_by,
+		a.cancelled_at,
+		a.cancelled_by,
+		a.cancel_reason
+	FROM acts a
+	LEFT JOIN organizations t ON t.id = a.target_id
+	LEFT JOIN organizations lf ON lf.id = a.landfill_id
+`
+
+// Register assigns the next number of act.Year and stores the act as issued
+// together with the snapshot returned by prepare. prepare runs inside the same
+// transaction with the number already assigned, so a failed render rolls the
+// number back and the registry stays gapless. The act.generated webhooks of
+// the act are queued in the same transaction.
+func (r *ActRepository) Register(
+	ctx context.Context,
+	act *model.Act,
+	prefix string,
+	prepare func(*model.Act) (*model.ActReport, error),
+) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := assignNumber(tx, act, prefix); err != nil {
+			return err
+		}
+		act.Status = model.ActStatusIssued
+		if err := insertAct(tx, act); err != nil {
+			return err
+		}
+		report, err := prepare(act)
+		if err != nil {
+			return err
+		}
+		if err := saveSnapshot(tx, act.ID, report); err != nil {
+			return err
+		}
+		return enqueueWebhooks(tx, model.WebhookEventActGenerated, act.ID, act.CreatedAt)
+	})
+}
+
+// RegisterStreamed is Register for reports too large to hold in memory.
+// collect reads the report through reports, which is bound to the
+// transaction, so the whole registration uses one connection; it receives
+// add to write each trip into the snapshot as it is read and returns the
+// report header. The number is assigned only after collect returns, so the
+// lock on the year's sequence is not held while the trips are read; it is
+// set on the report before the header is stored. The act totals and
+// fingerprint set by collect are stored in the same transaction.
+func (r *ActRepository) RegisterStreamed(
+	ctx context.Context,
+	act *model.Act,
+	prefix string,
+	reports *ReportRepository,
+	collect func(reports *ReportRepository, add func(groupIndex, position int, trip model.TripDetail) error) (*model.ActReport, error),
+) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		act.Status = model.ActStatusIssued
+		if err := insertAct(tx, act); err != nil {
+			return err
+		}
+		// The header row goes first: the snapshot trips reference it.
+		if err := reserveSnapshot(tx, act.ID); err != nil {
+			return err
+		}
+
+		w := &snapshotWriter{tx: tx, actID: act.ID}
+		report, err := collect(reports.withTx(tx), w.add)
+		if err != nil {
+			return err
+		}
+		if err := w.flush(); err != nil {
+			return err
+		}
+
+		if err := assignNumber(tx, act, prefix); err != nil {
+			return err
+		}
+		report.Number = act.Number
+		if err := tx.Exec(`
+			UPDATE acts
+			SET number = ?, year = ?, sequence = ?,
+				total_trips = ?, total_volume_m3 = ?, net_amount = ?, vat_amount = ?, gross_amount = ?,
+				fingerprint = ?
+			WHERE id = ?
+		`,
+			act.Number, act.Year, act.Sequence,
+			act.TotalTrips, act.TotalVolumeM3, act.NetAmount, act.VATAmount, act.GrossAmount,
+			act.Fingerprint, act.ID,
+		).Error; err != nil {
+			return err
+		}
+		if err := updateSnapshotHeader(tx, act.ID, report); err != nil {
+			return err
+		}
+		return enqueueWebhooks(tx, model.WebhookEventActGenerated, act.ID, act.CreatedAt)
+	})
+}
+
+// CreateDraft stores an act without a number.
+func (r *ActRepository) CreateDraft(ctx context.Context, act *model.Act) error {
+	act.Status = model.ActStatusDraft
+	return insertAct(r.db.WithContext(ctx), act)
+}
+
+// Issue numbers a draft, freezes its totals and stores the snapshot returned
+// by prepare. It fails with gorm.ErrRecordNotFound if the act is no longer a
+// draft. Like Register, it queues the act.generated webhooks of the act.
+func (r *ActRepository) Issue(
+	ctx context.Context,
+	act *model.Act,
+	prefix string,
+	prepare func(*model.Act) (*model.ActReport, error),
+) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := assignNumber(tx, act, prefix); err != nil {
+			return err
+		}
+		result := tx.Exec(`
+			UPDATE acts
+			SET number = ?, year = ?, sequence = ?, status = ?,
+				total_trips = ?, total_volume_m3 = ?, net_amount = ?, vat_amount = ?, gross_amount = ?,
+				fingerprint = ?, issued_at = ?, issued_by = ?, updated_at = ?
+			WHERE id = ? AND status = ?
+		`,
+			act.Number, act.Year, act.Sequence, string(model.ActStatusIssued),
+			act.TotalTrips, act.TotalVolumeM3, act.NetAmount, act.VATAmount, act.GrossAmount,
+			act.Fingerprint, act.IssuedAt, act.IssuedBy, act.UpdatedAt,
+			act.ID, string(model.ActStatusDraft),
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		act.Status = model.ActStatusIssued
+
+		report, err := prepare(act)
+		if err != nil {
+			return err
+		}
+		if err := saveSnapshot(tx, act.ID, report); err != nil {
+			return err
+		}
+		return enqueueWebhooks(tx, model.WebhookEventActGenerated, act.ID, *act.IssuedAt)
+	})
+}
+
+// RegisterBatch stores acts as issued together with the snapshots of
+// reports, in one transaction, so a batch is registered entirely or not at
+// all. The numbers are assigned in the order of acts at the end of the
+// transaction, so the lock on the year's sequence is held only briefly; they
+// are set on the reports too. Nothing is rendered here: the documents need
+// the numbers and are rendered after the batch is registered.
+func (r *ActRepository) RegisterBatch(ctx context.Context, acts []*model.Act, reports []*model.ActReport, prefix string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return registerBatch(tx, acts, reports, prefix)
+	})
+}
+
+// RegisterScheduled registers the act of a schedule like RegisterBatch and
+// stores the document records returned by records, which receives the
+// numbered act, in the same transaction. The records are unique per mode,
+// target, period and format, so an act already issued for them fails to
+// register instead of being issued twice.
+func (r *ActRepository) RegisterScheduled(
+	ctx context.Context,
+	act *model.Act,
+	report *model.ActReport,
+	prefix string,
+	records func(act *model.Act) []model.ScheduledAct,
+) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := registerBatch(tx, []*model.Act{act}, []*model.ActReport{report}, prefix); err != nil {
+			return err
+		}
+		for _, record := range records(act) {
+			if err := insertScheduledAct(tx, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func registerBatch(tx *gorm.DB, acts []*model.Act, reports []*model.ActReport, prefix string) error {
+	for i, act := range acts {
+		act.Status = model.ActStatusIssued
+		if err := insertAct(tx, act); err != nil {
+			return err
+		}
+		if err := reserveSnapshot(tx, act.ID); err != nil {
+			return err
+		}
+		if err := saveSnapshotTrips(tx, act.ID, reports[i]); err != nil {
+			return err
+		}
+	}
+
+	for i, act := range acts {
+		if err := assignNumber(tx, act, prefix); err != nil {
+			return err
+		}
+		reports[i].Number = act.Number
+		if err := tx.Exec(`
+			UPDATE acts SET number = ?, year = ?, sequence = ? WHERE id = ?
+		`, act.Number, act.Year, act.Sequence, act.ID).Error; err != nil {
+			return err
+		}
+		if err := updateSnapshotHeader(tx, act.ID, reports[i]); err != nil {
+			return err
+		}
+		if err := enqueueWebhooks(tx, model.WebhookEventActGenerated, act.ID, act.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateLifecycle stores signature, approval and cancellation fields of act.
+// The update only applies while the stored status still equals from, so
+// concurrent transitions cannot overwrite each other.
+func (r *ActRepository) UpdateLifecycle(ctx context.Context, act *model.Act, from model.ActStatus) error {
+	result := r.db.WithContext(ctx).Exec(`
+		UPDATE acts
+		SET status = ?,
+			contractor_signed_at = ?, contractor_signed_by = ?,
+			accepted_at = ?, accepted_by = ?, accepted_by_org = ?,
+			approved_at = ?, approved_by = ?,
+			cancelled_at = ?, cancelled_by = ?, cancel_reason = ?,
+			updated_at = ?
+		WHERE id = ? AND status = ?
+	`,
+		string(act.Status),
+		act.ContractorSignedAt, act.ContractorSignedBy,
+		act.AcceptedAt, act.AcceptedBy, act.AcceptedByOrg,
+		act.ApprovedAt, act.ApprovedBy,
+		act.CancelledAt, act.CancelledBy, act.CancelReason,
+		act.UpdatedAt,
+		act.ID, string(from),
+	)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *ActRepository) Get(ctx context.Context, id uuid.UUID) (*model.Act, error) {
+	var act model.Act
+	if err := r.db.WithContext(ctx).Raw(actSelect+`
+		WHERE a.id = ?
+		LIMIT 1
+	`, id).Scan(&act).Error; err != nil {
+		return nil, err
+	}
+	if act.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &act, nil
+}
+
+// LatestCorrective returns the last corrective act of the original act that
+// is not cancelled.
+func (r *ActRepository) LatestCorrective(ctx context.Context, originalID uuid.UUID) (*model.Act, error) {
+	var act model.Act
+	if err := r.db.WithContext(ctx).Raw(actSelect+`
+		WHERE a.original_act_id = ? AND a.kind = ? AND a.status <> ?
+		ORDER BY a.created_at DESC, a.sequence DESC
+		LIMIT 1
+	`, originalID, string(model.ActKindCorrective), string(model.ActStatusCancelled)).Scan(&act).Error; err != nil {
+		return nil, err
+	}
+	if act.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &act, nil
+}
+
+func (r *ActRepository) List(ctx context.Context, filter model.ActFilter) ([]model.Act, error) {
+	query := actSelect + ` WHERE 1 = 1`
+	args := make([]interface{}, 0, 5)
+	if filter.Status != "" {
+		query += ` AND a.status = ?`
+		args = append(args, string(filter.Status))
+	}
+	if filter.Mode != "" {
+		query += ` AND a.mode = ?`
+		args = append(args, string(filter.Mode))
+	}
+	if filter.TargetID != uuid.Nil {
+		query += ` AND a.target_id = ?`
+		args = append(args, filter.TargetID)
+	}
+	if filter.OrgID != uuid.Nil {
+		query += ` AND (a.target_id = ? OR a.landfill_id = ? OR a.created_by_org = ?)`
+		args = append(args, filter.OrgID, filter.OrgID, filter.OrgID)
+	}
+	query += ` ORDER BY a.created_at DESC`
+
+	var rows []model.Act
+	if err := r.db.WithContext(ctx).Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ListDriftCandidates returns the issued and signed acts whose period ends on
+// or after since: the acts that can still be corrected before approval.
+func (r *ActRepository) ListDriftCandidates(ctx context.Context, since time.Time) ([]model.Act, error) {
+	var rows []model.Act
+	err := r.db.WithContext(ctx).Raw(actSelect+`
+		WHERE a.status IN (?, ?) AND a.period_end >= ?
+		ORDER BY a.period_end DESC, a.created_at DESC
+	`, string(model.ActStatusIssued), string(model.ActStatusSigned), since).Scan(&rows).Error
+	return rows, err
+}
+
+func assignNumber(tx *gorm.DB, act *model.Act, prefix string) error {
+	var seq int64
+	if err := tx.Raw(`
+		INSERT INTO act_number_sequences (year, last_value)
+		VALUES (?, 1)
+		ON CONFLICT (year) DO UPDATE
+		SET last_value = act_number_sequences.last_value + 1
+		RETURNING last_value
+	`, act.Year).Scan(&seq).Error; err != nil {
+		return err
+	}
+	act.Sequence = seq
+	act.Number = fmt.Sprintf("%s-%d-%06d", prefix, act.Year, seq)
+	return nil
+}
+
+func insertAct(tx *gorm.DB, act *model.Act) error {
+	var number interface{}
+	var year interface{}
+	var sequence interface{}
+	if act.Number != "" {
+		number, year, sequence = act.Number, act.Year, act.Sequence
+	}
+	if act.UpdatedAt.IsZero() {
+		act.UpdatedAt = time.Now()
+	}
+	if act.Kind == "" {
+		act.Kind = model.ActKindRegular
+	}
+	return tx.Exec(`
+		INSERT INTO acts (
+			id, number, year, sequence, status, kind, original_act_id, base_act_id, mode, target_id, landfill_id,
+			period_start, period_end, format,
+			total_trips, total_volume_m3, net_amount, vat_amount, gross_amount, fingerprint,
+			created_by, created_by_org, created_at, updated_at, issued_at, issued_by
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		act.ID, number, year, sequence, string(act.Status), string(act.Kind), act.OriginalActID, act.BaseActID, string(act.Mode), act.TargetID, act.LandfillID,
+		act.PeriodStart, act.PeriodEnd, act.Format,
+		act.TotalTrips, act.TotalVolumeM3, act.NetAmount, act.VATAmount, act.GrossAmount, act.Fingerprint,
+		act.CreatedBy, act.CreatedByOrg, act.CreatedAt, act.UpdatedAt, act.IssuedAt, act.IssuedBy,
+	).Error
+}
@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+const snapshotBatchSize = 500
+
+type snapshotEventRow struct {
+	ActID          uuid.UUID  `gorm:"column:act_id"`
+	EventID        uuid.UUID  `gorm:"column:event_id"`
+	GroupIndex     int        `gorm:"column:group_index"`
+	Position       int        `gorm:"column:position"`
+	EventTime      time.Time  `gorm:"column:event_time"`
+	Plate          *string    `gorm:"column:plate"`
+	PolygonID      *uuid.UUID `gorm:"column:polygon_id"`
+	PolygonName    *string    `gorm:"column:polygon_name"`
+	ContractorID   *uuid.UUID `gorm:"column:contractor_id"`
+	ContractorName *string    `gorm:"column:contractor_name"`
+	SnowVolumeM3   *float64   `gorm:"column:snow_volume_m3"`
+}
+
+func (snapshotEventRow) TableName() string {
+	return "act_snapshot_events"
+}
+
+// GetSnapshot restores the report exactly as it was when the act was issued,
+// including every trip. It returns gorm.ErrRecordNotFound for acts without a
+// snapshot (drafts and acts issued before snapshots existed).
+func (r *ActRepository) GetSnapshot(ctx context.Context, actID uuid.UUID) (*model.ActReport, error) {
+	report, err := r.GetSnapshotHeader(ctx, actID)
+	if err != nil {
+		return nil, err
+	}
+	err = r.StreamSnapshotTrips(ctx, actID, func(groupIndex int, trip model.TripDetail) error {
+		if groupIndex < 0 || groupIndex >= len(report.Groups) {
+			return nil
+		}
+		group := &report.Groups[groupIndex]
+		group.Trips = append(group.Trips, trip)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetSnapshotHeader restores the report of an issued act without its trips.
+// Group volumes come from act_group_totals, so they are also set for acts
+// issued before the header carried them.
+func (r *ActRepository) GetSnapshotHeader(ctx context.Context, actID uuid.UUID) (*model.ActReport, error) {
+	var raw []byte
+	if err := r.db.WithContext(ctx).Raw(`SELECT report FROM act_snapshots WHERE act_id = ?`, actID).Row().Scan(&raw); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	var report model.ActReport
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return nil, err
+	}
+
+	totals, err := r.GetGroupTotals(ctx, actID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range totals {
+		if t.GroupIndex >= 0 && t.GroupIndex < len(report.Groups) {
+			report.Groups[t.GroupIndex].VolumeM3 = t.VolumeM3
+		}
+	}
+	return &report, nil
+}
+
+// StreamSnapshotTrips reads the trips of an act snapshot through a database
+// cursor, group by group in the order of the act.
+func (r *ActRepository) StreamSnapshotTrips(
+	ctx context.Context,
+	actID uuid.UUID,
+	fn func(groupIndex int, trip model.TripDetail) error,
+) error {
+	db := r.db.WithContext(ctx)
+	rows, err := db.Raw(`
+		SELECT act_id, event_id, group_index, position, event_time, plate,
+			polygon_id, polygon_name, contractor_id, contractor_name, snow_volume_m3
+		FROM act_snapshot_events
+		WHERE act_id = ?
+		ORDER BY group_index ASC, position ASC
+	`, actID).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row snapshotEventRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		err := fn(row.GroupIndex, model.TripDetail{
+			EventID:        row.EventID,
+			EventTime:      row.EventTime,
+			Plate:          row.Plate,
+			PolygonID:      row.PolygonID,
+			PolygonName:    row.PolygonName,
+			ContractorID:   row.ContractorID,
+			ContractorName: row.ContractorName,
+			SnowVolumeM3:   row.SnowVolumeM3,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// saveSnapshot stores the report header as JSON, the per-group totals and
+// every trip as a row, so the billed event IDs and volumes stay queryable.
+func saveSnapshot(tx *gorm.DB, actID uuid.UUID, report *model.ActReport) error {
+	if err := saveSnapshotHeader(tx, actID, report); err != nil {
+		return err
+	}
+	return saveSnapshotTrips(tx, actID, report)
+}
+
+// reserveSnapshot inserts an empty snapshot header, so the trips can be
+// stored before the header is known; updateSnapshotHeader completes it.
+func reserveSnapshot(tx *gorm.DB, actID uuid.UUID) error {
+	return tx.Exec(`INSERT INTO act_snapshots (act_id, report) VALUES (?, '{}')`, actID).Error
+}
+
+// saveSnapshotTrips stores every trip of report as a snapshot row.
+func saveSnapshotTrips(tx *gorm.DB, actID uuid.UUID, report *model.ActReport) error {
+	w := &snapshotWriter{tx: tx, actID: actID}
+	for i, group := range report.Groups {
+		for j, trip := range group.Trips {
+			if err := w.add(i, j, trip); err != nil {
+				return err
+			}
+		}
+	}
+	return w.flush()
+}
+
+// saveSnapshotHeader stores the report without trips and its group totals.
+func saveSnapshotHeader(tx *gorm.DB, actID uuid.UUID, report *model.ActReport) error {
+	raw, err := snapshotHeader(report)
+	if err != nil {
+		return err
+	}
+	if err := tx.Exec(`
+		INSERT INTO act_snapshots (act_id, report) VALUES (?, ?)
+	`, actID, raw).Error; err != nil {
+		return err
+	}
+	return saveGroupTotals(tx, actID, report)
+}
+
+// updateSnapshotHeader is saveSnapshotHeader for a snapshot whose header row
+// was inserted before its trips.
+func updateSnapshotHeader(tx *gorm.DB, actID uuid.UUID, report *model.ActReport) error {
+	raw, err := snapshotHeader(report)
+	if err != nil {
+		return err
+	}
+	if err := tx.Exec(`
+		UPDATE act_snapshots SET report = ? WHERE act_id = ?
+	`, raw, actID).Error; err != nil {
+		return err
+	}
+	return saveGroupTotals(tx, actID, report)
+}
+
+// snapshotHeader returns the report without trips as JSON.
+func snapshotHeader(report *model.ActReport) (string, error) {
+	header := *report
+	header.Groups = make([]model.TripGroup, len(report.Groups))
+	for i, group := range report.Groups {
+		header.Groups[i] = group
+		header.Groups[i].Trips = nil
+	}
+	raw, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// snapshotWriter inserts snapshot trips in batches as they arrive.
+type snapshotWriter struct {
+	tx    *gorm.DB
+	actID uuid.UUID
+	rows  []snapshotEventRow
+}
+
+func (w *snapshotWriter) add(groupIndex, position int, trip model.TripDetail) error {
+	w.rows = append(w.rows, snapshotEventRow{
+		ActID:          w.actID,
+		EventID:        trip.EventID,
+		GroupIndex:     groupIndex,
+		Position:       position,
+		EventTime:      trip.EventTime,
+		Plate:          trip.Plate,
+		PolygonID:      trip.PolygonID,
+		PolygonName:    trip.PolygonName,
+		ContractorID:   trip.ContractorID,
+		ContractorName: trip.ContractorName,
+		SnowVolumeM3:   trip.SnowVolumeM3,
+	})
+	if len(w.rows) < snapshotBatchSize {
+		return nil
+	}
+	return w.flush()
+}
+
+func (w *snapshotWriter) flush() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+	if err := w.tx.Create(&w.rows).Error; err != nil {
+		return err
+	}
+	w.rows = w.rows[:0]
+	return nil
+}
+
+type groupTotalsRow struct {
+	ActID       uuid.UUID `gorm:"column:act_id"`
+	GroupIndex  int       `gorm:"column:group_index"`
+	GroupID     uuid.UUID `gorm:"column:group_id"`
+	GroupName   string    `gorm:"column:group_name"`
+	TripCount   int64     `gorm:"column:trip_count"`
+	VolumeM3    float64   `gorm:"column:volume_m3"`
+	NetAmount   float64   `gorm:"column:net_amount"`
+	VATAmount   float64   `gorm:"column:vat_amount"`
+	GrossAmount float64   `gorm:"column:gross_amount"`
+}
+
+func (groupTotalsRow) TableName() string {
+	return "act_group_totals"
+}
+
+// GetGroupTotals returns the per-group figures an act was issued with, in the
+// order of the act.
+func (r *ActRepository) GetGroupTotals(ctx context.Context, actID uuid.UUID) ([]model.GroupTotals, error) {
+	var rows []groupTotalsRow
+	if err := r.db.WithContext(ctx).Raw(`
+		SELECT act_id, group_index, group_id, group_name, trip_count, volume_m3,
+			net_amount, vat_amount, gross_amount
+		FROM act_group_totals
+		WHERE act_id = ?
+		ORDER BY group_index ASC
+	`, actID).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	totals := make([]model.GroupTotals, 0, len(rows))
+	for _, row := range rows {
+		totals = append(totals, model.GroupTotals{
+			GroupIndex:  row.GroupIndex,
+			GroupID:     row.GroupID,
+			GroupName:   row.GroupName,
+			TripCount:   row.TripCount,
+			VolumeM3:    row.VolumeM3,
+			NetAmount:   row.NetAmount,
+			VATAmount:   row.VATAmount,
+			GrossAmount: row.GrossAmount,
+		})
+	}
+	return totals, nil
+}
+
+func saveGroupTotals(tx *gorm.DB, actID uuid.UUID, report *model.ActReport) error {
+	if len(report.Groups) == 0 {
+		return nil
+	}
+	rows := make([]groupTotalsRow, 0, len(report.Groups))
+	for i, group := range report.Groups {
+		rows = append(rows, groupTotalsRow{
+			ActID:       actID,
+			GroupIndex:  i,
+			GroupID:     group.ID,
+			GroupName:   group.Name,
+			TripCount:   group.TripCount,
+			VolumeM3:    group.VolumeM3,
+			NetAmount:   group.NetAmount,
+			VATAmount:   group.VATAmount,
+			GrossAmount: group.GrossAmount,
+		})
+	}
+	return tx.CreateInBatches(rows, snapshotBatchSize).Error
+}
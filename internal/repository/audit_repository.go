@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+const auditColumns = `
+	id, created_at, action, user_id, org_id, role, mode, target_id, landfill_id, act_id, job_id,
+	period_start, period_end, format, foreign_target, outcome, status_code, duration_ms, size_bytes,
+	checksum, client_ip
+`
+
+func (r *AuditRepository) Create(ctx context.Context, e *model.AuditEntry) error {
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO act_audit_log (`+auditColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		e.ID, e.CreatedAt, e.Action, e.UserID, e.OrgID, string(e.Role), string(e.Mode),
+		e.TargetID, e.LandfillID, e.ActID, e.JobID, e.PeriodStart, e.PeriodEnd, e.Format,
+		e.ForeignTarget, string(e.Outcome), e.StatusCode, e.DurationMs, e.SizeBytes,
+		e.Checksum, e.ClientIP,
+	).Error
+}
+
+// List returns the entries matching filter, newest first.
+func (r *AuditRepository) List(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	query, args := auditQuery(filter)
+	var rows []model.AuditEntry
+	if err := r.db.WithContext(ctx).Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Stream reads the entries matching filter, newest first, through a database
+// cursor and passes them to fn one at a time.
+func (r *AuditRepository) Stream(ctx context.Context, filter model.AuditFilter, fn func(model.AuditEntry) error) error {
+	db := r.db.WithContext(ctx)
+	query, args := auditQuery(filter)
+	rows, err := db.Raw(query, args...).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry model.AuditEntry
+		if err := db.ScanRows(rows, &entry); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func auditQuery(filter model.AuditFilter) (string, []interface{}) {
+	query := `SELECT ` + auditColumns + ` FROM act_audit_log WHERE 1 = 1`
+	args := make([]interface{}, 0, 10)
+	if filter.UserID != uuid.Nil {
+		query += ` AND user_id = ?`
+		args = append(args, filter.UserID)
+	}
+	if filter.OrgID != uuid.Nil {
+		query += ` AND org_id = ?`
+		args = append(args, filter.OrgID)
+	}
+	if filter.Role != "" {
+		query += ` AND role = ?`
+		args = append(args, string(filter.Role))
+	}
+	if filter.Action != "" {
+		query += ` AND action = ?`
+		args = append(args, filter.Action)
+	}
+	if filter.Outcome != "" {
+		query += ` AND outcome = ?`
+		args = append(args, string(filter.Outcome))
+	}
+	if filter.TargetID != uuid.Nil {
+		query += ` AND (target_id = ? OR landfill_id = ?)`
+		args = append(args, filter.TargetID, filter.TargetID)
+	}
+	if filter.ActID != uuid.Nil {
+		query += ` AND act_id = ?`
+		args = append(args, filter.ActID)
+	}
+	if filter.ForeignTarget != nil {
+		query += ` AND foreign_target = ?`
+		args = append(args, *filter.ForeignTarget)
+	}
+	if !filter.From.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		query += ` AND created_at < ?`
+		args = append(args, filter.To)
+	}
+	query += ` ORDER BY created_at DESC, id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query += ` OFFSET ?`
+		args = append(args, filter.Offset)
+	}
+	return query, args
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+type DeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewDeliveryRepository(db *gorm.DB) *DeliveryRepository {
+	return &DeliveryRepository{db: db}
+}
+
+// ListRecipients returns the recipients of the organizations.
+func (r *DeliveryRepository) ListRecipients(ctx context.Context, orgIDs ...uuid.UUID) ([]model.Recipient, error) {
+	var rows []model.Recipient
+	if err := r.db.WithContext(ctx).Raw(`
+		SELECT id, organization_id, email, lang, created_at
+		FROM organization_recipients
+		WHERE organization_id IN ?
+		ORDER BY organization_id, email
+	`, orgIDs).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// CreateRecipient adds a recipient or, if the organization already has the
+// address, updates its language.
+func (r *DeliveryRepository) CreateRecipient(ctx context.Context, recipient *model.Recipient) error {
+	return r.db.WithContext(ctx).Raw(`
+		INSERT INTO organization_recipients (id, organization_id, email, lang, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (organization_id, email) DO UPDATE SET lang = EXCLUDED.lang
+		RETURNING id, organization_id, email, lang, created_at
+	`, recipient.ID, recipient.OrganizationID, recipient.Email, recipient.Lang, recipient.CreatedAt).Scan(recipient).Error
+}
+
+// DeleteRecipient removes a recipient of the organization.
+func (r *DeliveryRepository) DeleteRecipient(ctx context.Context, orgID, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Exec(`
+		DELETE FROM organization_recipients
+		WHERE id = ? AND organization_id = ?
+	`, id, orgID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+const deliveryColumns = `
+	id, act_id, organization_id, email, lang, status, attempts, error, next_attempt_at, created_at, sent_at
+`
+
+// CreateDeliveries queues deliveries in one transaction.
+func (r *DeliveryRepository) CreateDeliveries(ctx context.Context, deliveries []model.ActDelivery) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, d := range deliveries {
+			if err := tx.Exec(`
+				INSERT INTO act_deliveries (
+					id, act_id, organization_id, email, lang, status, attempts, error, next_attempt_at, created_at
+				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`,
+				d.ID, d.ActID, d.OrganizationID, d.Email, d.Lang, string(d.Status), d.Attempts, d.Error,
+				d.NextAttemptAt, d.CreatedAt,
+			).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListDeliveries returns the deliveries of an act, newest first.
+func (r *DeliveryRepository) ListDeliveries(ctx context.Context, actID uuid.UUID) ([]model.ActDelivery, error) {
+	var rows []model.ActDelivery
+	if err := r.db.WithContext(ctx).Raw(`
+		SELECT `+deliveryColumns+`
+		FROM act_deliveries
+		WHERE act_id = ?
+		ORDER BY created_at DESC, email
+	`, actID).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Claim takes the queued delivery that is due first and postpones its next
+// attempt to leaseUntil, so no other worker takes it meanwhile; a worker that
+// stops mid-send leaves it to be tried again then. It returns nil when
+// nothing is due.
+func (r *DeliveryRepository) Claim(ctx context.Context, now, leaseUntil time.Time) (*model.ActDelivery, error) {
+	var d model.ActDelivery
+	if err := r.db.WithContext(ctx).Raw(`
+		UPDATE act_deliveries
+		SET attempts = attempts + 1, next_attempt_at = ?
+		WHERE id = (
+			SELECT id
+			FROM act_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns,
+		leaseUntil, string(model.DeliveryQueued), now,
+	).Scan(&d).Error; err != nil {
+		return nil, err
+	}
+	if d.ID == uuid.Nil {
+		return nil, nil
+	}
+	return &d, nil
+}
+
+func (r *DeliveryRepository) MarkSent(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE act_deliveries
+		SET status = ?, error = '', sent_at = ?
+		WHERE id = ?
+	`, string(model.DeliverySent), time.Now(), id).Error
+}
+
+// Retry records a failed attempt and schedules the next one.
+func (r *DeliveryRepository) Retry(ctx context.Context, id uuid.UUID, message string, next time.Time) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE act_deliveries
+		SET error = ?, next_attempt_at = ?
+		WHERE id = ?
+	`, message, next, id).Error
+}
+
+func (r *DeliveryRepository) Fail(ctx context.Context, id uuid.UUID, message string) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE act_deliveries
+		SET status = ?, error = ?
+		WHERE id = ?
+	`, string(model.DeliveryFailed), message, id).Error
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// IsConflict reports whether err is a violation of a unique or exclusion
+// constraint, i.e. the row clashes with one stored concurrently.
+func IsConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "23505", "23P01":
+		return true
+	}
+	return false
+}
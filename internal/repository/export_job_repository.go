@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+type ExportJobRepository struct {
+	db *gorm.DB
+}
+
+func NewExportJobRepository(db *gorm.DB) *ExportJobRepository {
+	return &ExportJobRepository{db: db}
+}
+
+const exportJobColumns = `
+	id, status, progress, format, lang, mode, target_id, landfill_id, period_start, period_end,
+	hide_empty_groups, file_name, size_bytes, storage_key, error, attempts, created_by, created_by_org,
+	created_by_role, created_at, started_at, finished_at, act_id
+`
+
+func (r *ExportJobRepository) Create(ctx context.Context, job *model.ExportJob) error {
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO export_jobs (
+			id, status, progress, format, lang, mode, target_id, landfill_id, period_start, period_end,
+			hide_empty_groups, created_by, created_by_org, created_by_role, created_at, act_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		job.ID, string(job.Status), job.Progress, job.Format, job.Lang, string(job.Mode), job.TargetID, job.LandfillID,
+		job.PeriodStart, job.PeriodEnd, job.HideEmptyGroups,
+		job.CreatedBy, job.CreatedByOrg, string(job.CreatedByRole), job.CreatedAt, job.ActID,
+	).Error
+}
+
+func (r *ExportJobRepository) Get(ctx context.Context, id uuid.UUID) (*model.ExportJob, error) {
+	var job model.ExportJob
+	if err := r.db.WithContext(ctx).Raw(`
+		SELECT `+exportJobColumns+`
+		FROM export_jobs
+		WHERE id = ?
+		LIMIT 1
+	`, id).Scan(&job).Error; err != nil {
+		return nil, err
+	}
+	if job.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &job, nil
+}
+
+// Claim takes the oldest queued job and marks it running. Running jobs whose
+// heartbeat is older than staleBefore belonged to a stopped instance and are
+// taken again, unless they already had maxAttempts attempts; those fail.
+// It returns nil when there is nothing to run.
+func (r *ExportJobRepository) Claim(ctx context.Context, staleBefore time.Time, maxAttempts int) (*model.ExportJob, error) {
+	db := r.db.WithContext(ctx)
+	now := time.Now()
+	if err := db.Exec(`
+		UPDATE export_jobs
+		SET status = ?, error = 'export was interrupted too many times', finished_at = ?
+		WHERE status = ? AND heartbeat_at < ? AND attempts >= ?
+	`, string(model.ExportJobFailed), now, string(model.ExportJobRunning), staleBefore, maxAttempts).Error; err != nil {
+		return nil, err
+	}
+
+	var job model.ExportJob
+	if err := db.Raw(`
+		UPDATE export_jobs
+		SET status = ?, progress = 0, attempts = attempts + 1,
+			started_at = ?, heartbeat_at = ?
+		WHERE id = (
+			SELECT id
+			FROM export_jobs
+			WHERE status = ?
+			   OR (status = ? AND heartbeat_at < ?)
+			ORDER BY created_at ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+exportJobColumns,
+		string(model.ExportJobRunning), now, now,
+		string(model.ExportJobQueued), string(model.ExportJobRunning), staleBefore,
+	).Scan(&job).Error; err != nil {
+		return nil, err
+	}
+	if job.ID == uuid.Nil {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// SetProgress records the progress of attempt of a running job and refreshes
+// its heartbeat. Updates of an attempt the job was taken away from are
+// ignored.
+func (r *ExportJobRepository) SetProgress(ctx context.Context, id uuid.UUID, attempt, progress int) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE export_jobs
+		SET progress = GREATEST(progress, ?), heartbeat_at = ?
+		WHERE id = ? AND status = ? AND attempts = ?
+	`, progress, time.Now(), id, string(model.ExportJobRunning), attempt).Error
+}
+
+// Complete records the document attempt of a running job put into the file
+// store under storageKey. It returns gorm.ErrRecordNotFound if the job is no
+// longer run by that attempt.
+func (r *ExportJobRepository) Complete(ctx context.Context, id uuid.UUID, attempt int, fileName, storageKey string, size int64) error {
+	return finishJob(r.db.WithContext(ctx).Exec(`
+		UPDATE export_jobs
+		SET status = ?, progress = 100, file_name = ?, storage_key = ?, size_bytes = ?, content = NULL,
+			error = '', finished_at = ?
+		WHERE id = ? AND status = ? AND attempts = ?
+	`, string(model.ExportJobDone), fileName, storageKey, size, time.Now(), id, string(model.ExportJobRunning), attempt))
+}
+
+// Fail marks attempt of a running job failed. It returns
+// gorm.ErrRecordNotFound if the job is no longer run by that attempt.
+func (r *ExportJobRepository) Fail(ctx context.Context, id uuid.UUID, attempt int, message string) error {
+	return finishJob(r.db.WithContext(ctx).Exec(`
+		UPDATE export_jobs
+		SET status = ?, error = ?, finished_at = ?
+		WHERE id = ? AND status = ? AND attempts = ?
+	`, string(model.ExportJobFailed), message, time.Now(), id, string(model.ExportJobRunning), attempt))
+}
+
+func finishJob(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetFile returns the document of a job finished before documents were kept
+// in the file store.
+func (r *ExportJobRepository) GetFile(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	var content []byte
+	if err := r.db.WithContext(ctx).Raw(`
+		SELECT content FROM export_jobs WHERE id = ? AND content IS NOT NULL
+	`, id).Row().Scan(&content); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return content, nil
+}
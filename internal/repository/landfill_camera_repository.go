@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+type LandfillCameraRepository struct {
+	db *gorm.DB
+}
+
+func NewLandfillCameraRepository(db *gorm.DB) *LandfillCameraRepository {
+	return &LandfillCameraRepository{db: db}
+}
+
+const landfillCameraSelect = `
+	SELECT
+		lc.id,
+		lc.camera_id,
+		lc.landfill_id,
+		COALESCE(lf.name, '') AS landfill_name,
+		lc.valid_from,
+		lc.valid_to,
+		lc.created_at,
+		lc.updated_at
+	FROM landfill_cameras lc
+	LEFT JOIN organizations lf ON lf.id = lc.landfill_id
+`
+
+func (r *LandfillCameraRepository) List(ctx context.Context) ([]model.LandfillCamera, error) {
+	var rows []model.LandfillCamera
+	if err := r.db.WithContext(ctx).Raw(landfillCameraSelect + `
+		ORDER BY lc.camera_id ASC, lc.valid_from ASC
+	`).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *LandfillCameraRepository) Get(ctx context.Context, id uuid.UUID) (*model.LandfillCamera, error) {
+	var row model.LandfillCamera
+	if err := r.db.WithContext(ctx).Raw(landfillCameraSelect+`
+		WHERE lc.id = ?
+		LIMIT 1
+	`, id).Scan(&row).Error; err != nil {
+		return nil, err
+	}
+	if row.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &row, nil
+}
+
+func (r *LandfillCameraRepository) Create(ctx context.Context, camera *model.LandfillCamera) error {
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO landfill_cameras (id, camera_id, landfill_id, valid_from, valid_to, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, camera.ID, camera.CameraID, camera.LandfillID, camera.ValidFrom, camera.ValidTo, camera.CreatedAt, camera.UpdatedAt).Error
+}
+
+func (r *LandfillCameraRepository) Update(ctx context.Context, camera *model.LandfillCamera) error {
+	result := r.db.WithContext(ctx).Exec(`
+		UPDATE landfill_cameras
+		SET camera_id = ?, landfill_id = ?, valid_from = ?, valid_to = ?, updated_at = ?
+		WHERE id = ?
+	`, camera.CameraID, camera.LandfillID, camera.ValidFrom, camera.ValidTo, camera.UpdatedAt, camera.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *LandfillCameraRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Exec(`DELETE FROM landfill_cameras WHERE id = ?`, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// HasOverlap reports whether another mapping of cameraID is valid at any
+// moment of [from, to). A nil to means the period is open ended.
+func (r *LandfillCameraRepository) HasOverlap(
+	ctx context.Context,
+	cameraID string,
+	from time.Time,
+	to *time.Time,
+	excludeID uuid.UUID,
+) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Raw(`
+		SELECT COUNT(*)
+		FROM landfill_cameras
+		WHERE camera_id = ?
+		  AND id <> ?
+		  AND (valid_to IS NULL OR valid_to > ?)
+		  AND (CAST(? AS TIMESTAMPTZ) IS NULL OR valid_from < ?)
+	`, cameraID, excludeID, from, to, to).Scan(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,20 +12,44 @@ import (
 )
 
 type ReportRepository struct {
-	db *gorm.DB
+	db            *gorm.DB
+	validStatuses []string
+	// paged makes StreamTrips read trips in pages instead of through a
+	// cursor; see withTx.
+	paged bool
 }
 
-const cameraLandfillNameExpr = `
-	CASE LOWER(ae.camera_id)
-		WHEN 'shahovskoye' THEN 'шаховское'
-		WHEN 'yakor' THEN 'якорь'
-		WHEN 'solnechniy' THEN 'солнечный'
-		ELSE NULL
-	END
+// tripPageSize is the number of trips read at once by a paged StreamTrips.
+const tripPageSize = 1000
+
+// landfillCameraJoin resolves the landfill of an ANPR event through the
+// camera mapping that was valid at the event time.
+const landfillCameraJoin = `
+		JOIN landfill_cameras lc
+		  ON lc.camera_id = LOWER(ae.camera_id)
+		 AND ae.event_time >= lc.valid_from
+		 AND (lc.valid_to IS NULL OR ae.event_time < lc.valid_to)
+		JOIN organizations lf
+		  ON lf.id = lc.landfill_id
+		 AND lf.type = 'LANDFILL'
 `
 
-func NewReportRepository(db *gorm.DB) *ReportRepository {
-	return &ReportRepository{db: db}
+// NewReportRepository creates a repository that only counts ANPR events whose
+// status is one of validStatuses.
+func NewReportRepository(db *gorm.DB, validStatuses []string) *ReportRepository {
+	return &ReportRepository{db: db, validStatuses: validStatuses}
+}
+
+// withTx returns the repository bound to a transaction. Trips are read in
+// pages there: an open cursor would keep the connection of the transaction
+// busy, and the trips are written back through it as they are read.
+func (r *ReportRepository) withTx(tx *gorm.DB) *ReportRepository {
+	return &ReportRepository{db: tx, validStatuses: r.validStatuses, paged: true}
+}
+
+// ValidStatuses returns the ANPR event statuses included in reports.
+func (r *ReportRepository) ValidStatuses() []string {
+	return append([]string(nil), r.validStatuses...)
 }
 
 func (r *ReportRepository) GetOrganization(ctx context.Context, id uuid.UUID) (*model.Organization, error) {
@@ -43,6 +68,23 @@ func (r *ReportRepository) GetOrganization(ctx context.Context, id uuid.UUID) (*
 	return &org, nil
 }
 
+// ListOrganizations returns the organizations with the given IDs in one
+// query; IDs without an organization are left out.
+func (r *ReportRepository) ListOrganizations(ctx context.Context, ids []uuid.UUID) ([]model.Organization, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var rows []model.Organization
+	if err := r.db.WithContext(ctx).Raw(`
+        SELECT id, name, type, bin, head_full_name, address, phone
+        FROM organizations
+        WHERE id IN ?
+    `, ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
 func (r *ReportRepository) ListLandfills(ctx context.Context) ([]model.TripGroup, error) {
 	var rows []model.TripGroup
 	if err := r.db.WithContext(ctx).Raw(`
@@ -71,75 +113,85 @@ func (r *ReportRepository) ListContractors(ctx context.Context) ([]model.TripGro
 	return rows, nil
 }
 
-func (r *ReportRepository) EventCountsByLandfill(
+// ListTariffs returns tariffs whose validity overlaps [from, to).
+func (r *ReportRepository) ListTariffs(ctx context.Context, from, to time.Time) ([]model.Tariff, error) {
+	var rows []model.Tariff
+	if err := r.db.WithContext(ctx).Raw(`
+        SELECT id, landfill_id, contractor_id, unit, price, valid_from, valid_to
+        FROM tariffs
+        WHERE valid_from < ?
+          AND (valid_to IS NULL OR valid_to >= ?)
+        ORDER BY valid_from ASC
+    `, to, from).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// StreamTrips reads every trip of the report target in [from, to) through a
+// database cursor, or in pages for a repository bound to a transaction, and
+// passes it to fn in event time order: trips of the
+// contractor across all landfills in contractor mode, trips unloaded at the
+// landfill across all contractors in landfill mode, trips of every
+// contractor at every landfill in city mode, where targetID is not used, and
+// trips of the contractor at landfillID in pair mode. landfillID is only used
+// in pair mode. Trips are never held in memory all at once.
+func (r *ReportRepository) StreamTrips(
 	ctx context.Context,
-	contractorID uuid.UUID,
+	mode model.ReportMode,
+	targetID, landfillID uuid.UUID,
 	from, to time.Time,
-) ([]model.TripGroup, error) {
-	query := `
+	fn func(model.TripDetail) error,
+) error {
+	var query string
+	args := []interface{}{targetID, r.validStatuses, from, to}
+	switch mode {
+	case model.ReportModeContractor:
+		query = `
 		SELECT
-			lf.id AS id,
-			lf.name AS name,
-			COUNT(*) AS trip_count
+			ae.id AS event_id,
+			ae.event_time AS event_time,
+			COALESCE(ae.normalized_plate, ae.raw_plate) AS plate,
+			lf.id AS polygon_id,
+			lf.name AS polygon_name,
+			ae.contractor_id,
+			org.name AS contractor_name,
+			ae.snow_volume_m3
 		FROM anpr_events ae
-		JOIN organizations lf
-		  ON lf.type = 'LANDFILL'
-		 AND LOWER(lf.name) = ` + cameraLandfillNameExpr + `
+		` + landfillCameraJoin + `
+		LEFT JOIN organizations org ON org.id = ae.contractor_id
 		WHERE ae.contractor_id = ?
 			AND ae.matched_snow = true
+			AND ae.status IN ?
 			AND ae.event_time >= ?
 			AND ae.event_time < ?
-		GROUP BY lf.id, lf.name
-		ORDER BY lf.name ASC
 	`
-
-	var rows []model.TripGroup
-	if err := r.db.WithContext(ctx).Raw(query, contractorID, from, to).Scan(&rows).Error; err != nil {
-		return nil, err
-	}
-	return rows, nil
-}
-
-func (r *ReportRepository) EventCountsByContractor(
-	ctx context.Context,
-	landfillID uuid.UUID,
-	from, to time.Time,
-) ([]model.TripGroup, error) {
-	query := `
+	case model.ReportModeLandfill:
+		query = `
 		SELECT
-			ae.contractor_id AS id,
-			org.name AS name,
-			COUNT(*) AS trip_count
+			ae.id AS event_id,
+			ae.event_time AS event_time,
+			COALESCE(ae.normalized_plate, ae.raw_plate) AS plate,
+			lf.id AS polygon_id,
+			lf.name AS polygon_name,
+			ae.contractor_id,
+			org.name AS contractor_name,
+			ae.snow_volume_m3
 		FROM anpr_events ae
-		JOIN organizations lf
-		  ON lf.type = 'LANDFILL'
-		 AND LOWER(lf.name) = ` + cameraLandfillNameExpr + `
+		` + landfillCameraJoin + `
 		JOIN organizations org ON org.id = ae.contractor_id
 		WHERE lf.id = ?
 			AND org.type = 'CONTRACTOR'
 			AND org.name NOT ILIKE 'TEST%'
 			AND ae.matched_snow = true
+			AND ae.status IN ?
 			AND ae.event_time >= ?
 			AND ae.event_time < ?
-		GROUP BY ae.contractor_id, org.name
-		ORDER BY name ASC
 	`
-
-	var rows []model.TripGroup
-	if err := r.db.WithContext(ctx).Raw(query, landfillID, from, to).Scan(&rows).Error; err != nil {
-		return nil, err
-	}
-	return rows, nil
-}
-
-func (r *ReportRepository) ListEventsByLandfill(
-	ctx context.Context,
-	contractorID uuid.UUID,
-	landfillID uuid.UUID,
-	from, to time.Time,
-) ([]model.TripDetail, error) {
-	query := `
+	case model.ReportModePair:
+		query = `
 		SELECT
+			ae.id AS event_id,
 			ae.event_time AS event_time,
 			COALESCE(ae.normalized_plate, ae.raw_plate) AS plate,
 			lf.id AS polygon_id,
@@ -148,33 +200,20 @@ func (r *ReportRepository) ListEventsByLandfill(
 			org.name AS contractor_name,
 			ae.snow_volume_m3
 		FROM anpr_events ae
-		JOIN organizations lf
-		  ON lf.type = 'LANDFILL'
-		 AND LOWER(lf.name) = ` + cameraLandfillNameExpr + `
+		` + landfillCameraJoin + `
 		LEFT JOIN organizations org ON org.id = ae.contractor_id
 		WHERE ae.contractor_id = ?
 			AND lf.id = ?
 			AND ae.matched_snow = true
+			AND ae.status IN ?
 			AND ae.event_time >= ?
 			AND ae.event_time < ?
-		ORDER BY event_time ASC
 	`
-
-	var rows []model.TripDetail
-	if err := r.db.WithContext(ctx).Raw(query, contractorID, landfillID, from, to).Scan(&rows).Error; err != nil {
-		return nil, err
-	}
-	return rows, nil
-}
-
-func (r *ReportRepository) ListEventsByContractor(
-	ctx context.Context,
-	contractorID uuid.UUID,
-	landfillID uuid.UUID,
-	from, to time.Time,
-) ([]model.TripDetail, error) {
-	query := `
+		args = []interface{}{targetID, landfillID, r.validStatuses, from, to}
+	case model.ReportModeCity:
+		query = `
 		SELECT
+			ae.id AS event_id,
 			ae.event_time AS event_time,
 			COALESCE(ae.normalized_plate, ae.raw_plate) AS plate,
 			lf.id AS polygon_id,
@@ -183,23 +222,73 @@ func (r *ReportRepository) ListEventsByContractor(
 			org.name AS contractor_name,
 			ae.snow_volume_m3
 		FROM anpr_events ae
-		JOIN organizations lf
-		  ON lf.type = 'LANDFILL'
-		 AND LOWER(lf.name) = ` + cameraLandfillNameExpr + `
+		` + landfillCameraJoin + `
 		JOIN organizations org ON org.id = ae.contractor_id
-		WHERE lf.id = ?
-			AND ae.contractor_id = ?
-			AND org.type = 'CONTRACTOR'
+		WHERE org.type = 'CONTRACTOR'
 			AND org.name NOT ILIKE 'TEST%'
 			AND ae.matched_snow = true
+			AND ae.status IN ?
 			AND ae.event_time >= ?
 			AND ae.event_time < ?
-		ORDER BY event_time ASC
 	`
+		args = args[1:]
+	default:
+		return fmt.Errorf("unsupported report mode %q", mode)
+	}
 
-	var rows []model.TripDetail
-	if err := r.db.WithContext(ctx).Raw(query, landfillID, contractorID, from, to).Scan(&rows).Error; err != nil {
-		return nil, err
+	const order = `
+		ORDER BY ae.event_time ASC, ae.id ASC`
+	db := r.db.WithContext(ctx)
+	if r.paged {
+		return streamTripPages(db, query, order, args, fn)
+	}
+	rows, err := db.Raw(query+order, args...).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trip model.TripDetail
+		if err := db.ScanRows(rows, &trip); err != nil {
+			return err
+		}
+		if err := fn(trip); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// streamTripPages runs a trips query page by page, each page continuing after
+// the event time and ID of the last trip of the previous one. No result set
+// stays open while fn runs.
+func streamTripPages(db *gorm.DB, query, order string, args []interface{}, fn func(model.TripDetail) error) error {
+	var last *model.TripDetail
+	for {
+		pageQuery := query
+		pageArgs := append([]interface{}(nil), args...)
+		if last != nil {
+			pageQuery += `
+			AND (ae.event_time, ae.id) > (?, ?)`
+			pageArgs = append(pageArgs, last.EventTime, last.EventID)
+		}
+		pageQuery += order + `
+		LIMIT ?`
+		pageArgs = append(pageArgs, tripPageSize)
+
+		var page []model.TripDetail
+		if err := db.Raw(pageQuery, pageArgs...).Scan(&page).Error; err != nil {
+			return err
+		}
+		for _, trip := range page {
+			if err := fn(trip); err != nil {
+				return err
+			}
+		}
+		if len(page) < tripPageSize {
+			return nil
+		}
+		last = &page[len(page)-1]
 	}
-	return rows, nil
 }
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+type ScheduledActRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduledActRepository(db *gorm.DB) *ScheduledActRepository {
+	return &ScheduledActRepository{db: db}
+}
+
+// WithLock runs fn while holding the session advisory lock identified by the
+// 64-bit key. If another session holds the lock, fn is not run and WithLock
+// returns false.
+func (r *ScheduledActRepository) WithLock(ctx context.Context, key int64, fn func() error) (bool, error) {
+	locked := false
+	err := r.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Raw(`SELECT pg_try_advisory_lock(?::bigint)`, key).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		// The lock is released on the same connection even if ctx is
+		// already done.
+		defer conn.WithContext(context.Background()).Exec(`SELECT pg_advisory_unlock(?::bigint)`, key)
+		return fn()
+	})
+	return locked, err
+}
+
+// List returns the document records of the act of mode, target and period.
+func (r *ScheduledActRepository) List(ctx context.Context, mode model.ReportMode, targetID uuid.UUID, periodStart, periodEnd time.Time) ([]model.ScheduledAct, error) {
+	var records []model.ScheduledAct
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT id, schedule, act_id, act_number, mode, target_id, period_start, period_end,
+			format, file_name, storage_key, size_bytes, created_at, stored_at
+		FROM scheduled_acts
+		WHERE mode = ? AND target_id = ? AND period_start = ? AND period_end = ?
+		ORDER BY created_at ASC, format ASC
+	`, string(mode), targetID, periodStart, periodEnd).Scan(&records).Error
+	return records, err
+}
+
+// MarkStored records that the document of a record was put into the storage.
+func (r *ScheduledActRepository) MarkStored(ctx context.Context, id uuid.UUID, size int64) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE scheduled_acts SET size_bytes = ?, stored_at = ? WHERE id = ?
+	`, size, time.Now(), id).Error
+}
+
+// insertScheduledAct records a document of a scheduled act before it is
+// stored.
+func insertScheduledAct(tx *gorm.DB, record model.ScheduledAct) error {
+	return tx.Exec(`
+		INSERT INTO scheduled_acts (
+			id, schedule, act_id, act_number, mode, target_id, period_start, period_end,
+			format, file_name, storage_key, size_bytes, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?)
+	`,
+		record.ID, record.Schedule, record.ActID, record.ActNumber, string(record.Mode), record.TargetID,
+		record.PeriodStart, record.PeriodEnd, record.Format, record.FileName, record.StorageKey,
+		record.CreatedAt,
+	).Error
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+type TariffRepository struct {
+	db *gorm.DB
+}
+
+func NewTariffRepository(db *gorm.DB) *TariffRepository {
+	return &TariffRepository{db: db}
+}
+
+const tariffSelect = `
+	SELECT
+		t.id,
+		t.landfill_id,
+		COALESCE(lf.name, '') AS landfill_name,
+		t.contractor_id,
+		COALESCE(ct.name, '') AS contractor_name,
+		t.unit,
+		t.price,
+		t.valid_from,
+		t.valid_to,
+		t.created_at,
+		t.updated_at
+	FROM tariffs t
+	LEFT JOIN organizations lf ON lf.id = t.landfill_id
+	LEFT JOIN organizations ct ON ct.id = t.contractor_id
+`
+
+func (r *TariffRepository) List(ctx context.Context) ([]model.Tariff, error) {
+	var rows []model.Tariff
+	if err := r.db.WithContext(ctx).Raw(tariffSelect + `
+		ORDER BY lf.name ASC NULLS FIRST, ct.name ASC NULLS FIRST, t.valid_from ASC
+	`).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *TariffRepository) Get(ctx context.Context, id uuid.UUID) (*model.Tariff, error) {
+	var row model.Tariff
+	if err := r.db.WithContext(ctx).Raw(tariffSelect+`
+		WHERE t.id = ?
+		LIMIT 1
+	`, id).Scan(&row).Error; err != nil {
+		return nil, err
+	}
+	if row.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &row, nil
+}
+
+func (r *TariffRepository) Create(ctx context.Context, tariff *model.Tariff) error {
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO tariffs (id, landfill_id, contractor_id, unit, price, valid_from, valid_to, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, tariff.ID, tariff.LandfillID, tariff.ContractorID, tariff.Unit, tariff.Price,
+		tariff.ValidFrom, tariff.ValidTo, tariff.CreatedAt, tariff.UpdatedAt).Error
+}
+
+func (r *TariffRepository) Update(ctx context.Context, tariff *model.Tariff) error {
+	result := r.db.WithContext(ctx).Exec(`
+		UPDATE tariffs
+		SET landfill_id = ?, contractor_id = ?, unit = ?, price = ?, valid_from = ?, valid_to = ?, updated_at = ?
+		WHERE id = ?
+	`, tariff.LandfillID, tariff.ContractorID, tariff.Unit, tariff.Price,
+		tariff.ValidFrom, tariff.ValidTo, tariff.UpdatedAt, tariff.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *TariffRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Exec(`DELETE FROM tariffs WHERE id = ?`, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// HasOverlap reports whether another tariff of the same landfill and
+// contractor, nil matching nil, is valid on any day of [from, to]. A nil to
+// means the period is open ended.
+func (r *TariffRepository) HasOverlap(
+	ctx context.Context,
+	landfillID, contractorID *uuid.UUID,
+	from time.Time,
+	to *time.Time,
+	excludeID uuid.UUID,
+) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Raw(`
+		SELECT COUNT(*)
+		FROM tariffs
+		WHERE landfill_id IS NOT DISTINCT FROM CAST(? AS UUID)
+		  AND contractor_id IS NOT DISTINCT FROM CAST(? AS UUID)
+		  AND id <> ?
+		  AND (valid_to IS NULL OR valid_to >= CAST(? AS DATE))
+		  AND (CAST(? AS DATE) IS NULL OR valid_from <= ?)
+	`, landfillID, contractorID, excludeID, from, to, to).Scan(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
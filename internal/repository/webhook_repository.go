@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// webhookSubscriptionRow is a subscription as stored: its events are a
+// comma-separated list.
+type webhookSubscriptionRow struct {
+	ID        uuid.UUID
+	URL       string
+	Secret    string
+	Events    string
+	Active    bool
+	CreatedBy uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (row webhookSubscriptionRow) subscription() model.WebhookSubscription {
+	return model.WebhookSubscription{
+		ID:        row.ID,
+		URL:       row.URL,
+		Secret:    row.Secret,
+		Events:    strings.Split(row.Events, ","),
+		Active:    row.Active,
+		CreatedBy: row.CreatedBy,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+}
+
+const webhookSubscriptionColumns = `id, url, secret, events, active, created_by, created_at, updated_at`
+
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	var rows []webhookSubscriptionRow
+	if err := r.db.WithContext(ctx).Raw(`
+		SELECT ` + webhookSubscriptionColumns + `
+		FROM webhook_subscriptions
+		ORDER BY created_at, id
+	`).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	subscriptions := make([]model.WebhookSubscription, 0, len(rows))
+	for _, row := range rows {
+		subscriptions = append(subscriptions, row.subscription())
+	}
+	return subscriptions, nil
+}
+
+func (r *WebhookRepository) GetSubscription(ctx context.Context, id uuid.UUID) (*model.WebhookSubscription, error) {
+	var rows []webhookSubscriptionRow
+	if err := r.db.WithContext(ctx).Raw(`
+		SELECT `+webhookSubscriptionColumns+`
+		FROM webhook_subscriptions
+		WHERE id = ?
+	`, id).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	subscription := rows[0].subscription()
+	return &subscription, nil
+}
+
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, s *model.WebhookSubscription) error {
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO webhook_subscriptions (`+webhookSubscriptionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.ID, s.URL, s.Secret, strings.Join(s.Events, ","), s.Active, s.CreatedBy, s.CreatedAt, s.UpdatedAt).Error
+}
+
+// UpdateSubscription stores the URL, secret, events and state of s.
+func (r *WebhookRepository) UpdateSubscription(ctx context.Context, s *model.WebhookSubscription) error {
+	result := r.db.WithContext(ctx).Exec(`
+		UPDATE webhook_subscriptions
+		SET url = ?, secret = ?, events = ?, active = ?, updated_at = ?
+		WHERE id = ?
+	`, s.URL, s.Secret, strings.Join(s.Events, ","), s.Active, s.UpdatedAt, s.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteSubscription removes a subscription with its outbox.
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Exec(`DELETE FROM webhook_subscriptions WHERE id = ?`, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// enqueueWebhooks puts event about an act into the outbox of every active
+// subscription to it. It runs in the transaction that changes the act, so
+// the event is recorded exactly when the change is.
+func enqueueWebhooks(tx *gorm.DB, event string, actID uuid.UUID, now time.Time) error {
+	return tx.Exec(`
+		INSERT INTO webhook_outbox (id, subscription_id, event, act_id, status, next_attempt_at, created_at)
+		SELECT gen_random_uuid(), s.id, ?, ?, ?, ?, ?
+		FROM webhook_subscriptions s
+		WHERE s.active AND ? = ANY(string_to_array(s.events, ','))
+	`, event, actID, string(model.WebhookQueued), now, now, event).Error
+}
+
+const webhookDeliveryColumns = `
+	id, subscription_id, event, act_id, status, attempts, response_status, error, next_attempt_at, created_at, sent_at
+`
+
+// ListDeliveries returns the latest outbox entries of a subscription, newest
+// first.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]model.WebhookDelivery, error) {
+	var rows []model.WebhookDelivery
+	if err := r.db.WithContext(ctx).Raw(`
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_outbox
+		WHERE subscription_id = ?
+		ORDER BY created_at DESC, id
+		LIMIT ?
+	`, subscriptionID, limit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Claim takes the queued entry that is due first and postpones its next
+// attempt to leaseUntil, like DeliveryRepository.Claim. Entries of inactive
+// subscriptions wait until they are activated again. It returns nil when
+// nothing is due.
+func (r *WebhookRepository) Claim(ctx context.Context, now, leaseUntil time.Time) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	if err := r.db.WithContext(ctx).Raw(`
+		UPDATE webhook_outbox
+		SET attempts = attempts + 1, next_attempt_at = ?
+		WHERE id = (
+			SELECT o.id
+			FROM webhook_outbox o
+			JOIN webhook_subscriptions s ON s.id = o.subscription_id AND s.active
+			WHERE o.status = ? AND o.next_attempt_at <= ?
+			ORDER BY o.next_attempt_at ASC
+			LIMIT 1
+			FOR UPDATE OF o SKIP LOCKED
+		)
+		RETURNING `+webhookDeliveryColumns,
+		leaseUntil, string(model.WebhookQueued), now,
+	).Scan(&d).Error; err != nil {
+		return nil, err
+	}
+	if d.ID == uuid.Nil {
+		return nil, nil
+	}
+	return &d, nil
+}
+
+func (r *WebhookRepository) MarkSent(ctx context.Context, id uuid.UUID, responseStatus int) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE webhook_outbox
+		SET status = ?, response_status = ?, error = '', sent_at = ?
+		WHERE id = ?
+	`, string(model.WebhookSent), responseStatus, time.Now(), id).Error
+}
+
+// Retry records a failed attempt and schedules the next one.
+func (r *WebhookRepository) Retry(ctx context.Context, id uuid.UUID, responseStatus int, message string, next time.Time) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE webhook_outbox
+		SET response_status = ?, error = ?, next_attempt_at = ?
+		WHERE id = ?
+	`, responseStatus, message, next, id).Error
+}
+
+func (r *WebhookRepository) Fail(ctx context.Context, id uuid.UUID, responseStatus int, message string) error {
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE webhook_outbox
+		SET status = ?, response_status = ?, error = ?
+		WHERE id = ?
+	`, string(model.WebhookFailed), responseStatus, message, id).Error
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// MIMETypeZIP is the content type of batch export archives.
+const MIMETypeZIP = "application/zip"
+
+// batchManifestName is the manifest file inside a batch archive.
+const batchManifestName = "manifest.csv"
+
+// BatchExportInput asks for the acts of several contractors or landfills for
+// one period, packed into one archive.
+type BatchExportInput struct {
+	Mode model.ReportMode
+	// TargetIDs lists the organizations to issue acts to; every contractor or
+	// landfill when empty.
+	TargetIDs   []uuid.UUID
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	// Formats names the document formats of every act; the default format
+	// when empty.
+	Formats         []string
+	Lang            string
+	HideEmptyGroups bool
+	Principal       model.Principal
+}
+
+// batchAct is the act issued to one target of a batch with its report.
+type batchAct struct {
+	act    *model.Act
+	report *model.ActReport
+}
+
+// ExportBatch issues an act to every target of input and returns a ZIP
+// archive with its documents in every requested format and a manifest.csv
+// listing the files with their act numbers, trip counts and volumes. Acts
+// are collected concurrently by at most the configured number of workers and
+// registered together. The archive is streamed: each document is rendered
+// straight into its entry and the trips of an act are dropped once its
+// documents are written. The first failure stops the batch and leaves no
+// issued act behind. Only Akimat and KGU may export batches.
+func (s *ActService) ExportBatch(ctx context.Context, input BatchExportInput) (*GenerateReportResult, error) {
+	if !(input.Principal.IsAkimat() || input.Principal.IsKgu()) {
+		return nil, ErrPermissionDenied
+	}
+	if input.Mode != model.ReportModeContractor && input.Mode != model.ReportModeLandfill {
+		return nil, fmt.Errorf("%w: batch export supports contractor and landfill modes", ErrInvalidInput)
+	}
+	formats, err := s.batchFormats(input.Formats)
+	if err != nil {
+		return nil, err
+	}
+
+	targetIDs := input.TargetIDs
+	if len(targetIDs) == 0 {
+		var targets []model.TripGroup
+		if input.Mode == model.ReportModeContractor {
+			targets, err = s.repo.ListContractors(ctx)
+		} else {
+			targets, err = s.repo.ListLandfills(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range targets {
+			targetIDs = append(targetIDs, target.ID)
+		}
+	}
+	if len(targetIDs) == 0 {
+		return nil, fmt.Errorf("%w: no organizations to export", ErrInvalidInput)
+	}
+
+	// Every target is validated before the first act is issued, so a wrong
+	// ID does not leave half a batch in the registry.
+	scopes := make([]*reportScope, 0, len(targetIDs))
+	seen := make(map[uuid.UUID]bool, len(targetIDs))
+	for _, id := range targetIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		scope, err := s.authorizeReport(ctx, GenerateReportInput{
+			Mode:        input.Mode,
+			TargetID:    id,
+			PeriodStart: input.PeriodStart,
+			PeriodEnd:   input.PeriodEnd,
+			Lang:        input.Lang,
+			Principal:   input.Principal,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %w", id, err)
+		}
+		scopes = append(scopes, scope)
+	}
+
+	acts, err := s.issueBatch(ctx, input, scopes, formats)
+	if err != nil {
+		return nil, err
+	}
+
+	first := scopes[0]
+	return &GenerateReportResult{
+		FileName: fmt.Sprintf("acts-batch-%s-%s-%s.zip",
+			strings.ToLower(string(input.Mode)),
+			first.periodStart.Format("20060102"),
+			first.periodEnd.Format("20060102")),
+		ContentType: MIMETypeZIP,
+		Stream: func(w io.Writer) error {
+			err := s.writeBatchArchive(ctx, w, acts, formats)
+			if err != nil {
+				// The acts are cancelled even if the request was.
+				if cancelErr := s.cancelBatch(context.WithoutCancel(ctx), acts, input.Principal); cancelErr != nil {
+					return errors.Join(err, fmt.Errorf("cancel batch acts: %w", cancelErr))
+				}
+			}
+			return err
+		},
+	}, nil
+}
+
+// batchFormats looks up the requested formats, dropping repeats.
+func (s *ActService) batchFormats(names []string) ([]RenderFormat, error) {
+	if len(names) == 0 {
+		format, err := s.renderers.Default()
+		if err != nil {
+			return nil, err
+		}
+		return []RenderFormat{format}, nil
+	}
+	formats := make([]RenderFormat, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		format, err := s.renderers.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[format.Name] {
+			continue
+		}
+		seen[format.Name] = true
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+// batchCancelReason is the cancel reason of the acts of a batch whose
+// archive could not be rendered or sent.
+const batchCancelReason = "batch export failed before its documents were delivered"
+
+// issueBatch issues the act of every scope and returns the acts in the order
+// of scopes. The reports are collected by a bounded pool of workers and the
+// acts registered in one transaction, so the number sequence is only locked
+// while they are stored. The reports keep their trips for the documents.
+func (s *ActService) issueBatch(ctx context.Context, input BatchExportInput, scopes []*reportScope, formats []RenderFormat) ([]batchAct, error) {
+	reports := make([]*model.ActReport, len(scopes))
+	err := runBatch(ctx, s.batchWorkers, len(scopes), func(ctx context.Context, index int) error {
+		report, err := s.collectReport(ctx, scopes[index])
+		if err == nil {
+			report.Lang = input.Lang
+			report.HideEmptyGroups = input.HideEmptyGroups
+			err = s.prepareIssue(ctx, s.repo, report)
+		}
+		if err != nil {
+			return fmt.Errorf("target %s: %w", scopes[index].target.ID, err)
+		}
+		reports[index] = report
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	acts := make([]*model.Act, len(reports))
+	for i, report := range reports {
+		acts[i] = s.newIssuedAct(input.Principal, report, formats[0].Name, now)
+		report.IssuedAt = acts[i].CreatedAt
+	}
+	if err := s.acts.RegisterBatch(ctx, acts, reports, s.numberPrefix); err != nil {
+		return nil, err
+	}
+
+	batch := make([]batchAct, len(acts))
+	for i := range acts {
+		batch[i] = batchAct{act: acts[i], report: reports[i]}
+	}
+	return batch, nil
+}
+
+// renderTo writes the document of report in format to w, streaming it when
+// the renderer can.
+func renderTo(w io.Writer, report model.ActReport, format RenderFormat) error {
+	if renderer, ok := format.Renderer.(StreamRenderer); ok {
+		return renderer.Write(w, report, report.EachTrip)
+	}
+	content, err := format.Renderer.Generate(report)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// cancelBatch cancels the registered acts of a failed batch.
+func (s *ActService) cancelBatch(ctx context.Context, acts []batchAct, principal model.Principal) error {
+	var errs []error
+	for _, batch := range acts {
+		if err := s.cancelIssued(ctx, batch.act, principal, batchCancelReason); err != nil {
+			errs = append(errs, fmt.Errorf("act %s: %w", batch.act.Number, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runBatch calls fn for every index below n with at most workers calls at a
+// time. The first failure cancels the context of the other calls and is
+// returned.
+func runBatch(ctx context.Context, workers, n int, fn func(ctx context.Context, index int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indexes := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i := 0; i < min(workers, n); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				if err := fn(ctx, index); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for index := 0; index < n; index++ {
+		select {
+		case indexes <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+var batchManifestHeader = []string{"file_name", "act_number", "format", "target_id", "target_name", "trip_count", "volume_m3"}
+
+// writeBatchArchive writes a ZIP archive with the documents of acts in every
+// format and the manifest to w. Each document is rendered straight into its
+// entry, and the trips of an act are dropped once its documents are written,
+// so the archive is never held in memory. Names that repeat, e.g. of
+// organizations whose names only differ in characters dropped from file
+// names, get the act number appended.
+func (s *ActService) writeBatchArchive(ctx context.Context, w io.Writer, acts []batchAct, formats []RenderFormat) error {
+	archive := zip.NewWriter(w)
+
+	var manifest bytes.Buffer
+	// The BOM lets Excel detect the encoding of the manifest.
+	manifest.WriteString("\ufeff")
+	rows := csv.NewWriter(&manifest)
+	rows.UseCRLF = true
+	if err := rows.Write(batchManifestHeader); err != nil {
+		return err
+	}
+
+	used := map[string]bool{batchManifestName: true}
+	for _, act := range acts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		report := act.report
+		for _, format := range formats {
+			name := buildFileName(format.FilePrefix, format.Extension, *report)
+			if used[name] {
+				name = appendToFileName(name, sanitizeFileName(report.Number))
+			}
+			used[name] = true
+
+			entry, err := archive.Create(name)
+			if err != nil {
+				return err
+			}
+			if err := renderTo(entry, *report, format); err != nil {
+				return fmt.Errorf("act %s: %w", report.Number, err)
+			}
+			err = rows.Write([]string{
+				name,
+				report.Number,
+				format.Name,
+				report.Target.ID.String(),
+				report.Target.Name,
+				strconv.FormatInt(report.TotalTrips, 10),
+				strconv.FormatFloat(report.TotalVolumeM3, 'f', 2, 64),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		// The trips are in the act snapshot and the documents; the manifest
+		// only needs the totals.
+		for i := range report.Groups {
+			report.Groups[i].Trips = nil
+		}
+	}
+
+	rows.Flush()
+	if err := rows.Error(); err != nil {
+		return err
+	}
+	entry, err := archive.Create(batchManifestName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(entry, &manifest); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// appendToFileName inserts suffix before the extension of name.
+func appendToFileName(name, suffix string) string {
+	if dot := strings.LastIndex(name, "."); dot > 0 {
+		return name[:dot] + "-" + suffix + name[dot:]
+	}
+	return name + "-" + suffix
+}
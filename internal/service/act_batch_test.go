@@ -0,0 +1,101 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// tripLines renders one line per trip, streaming when asked to.
+type tripLines struct{}
+
+func (tripLines) Generate(report model.ActReport) ([]byte, error) {
+	var buf bytes.Buffer
+	err := tripLines{}.Write(&buf, report, report.EachTrip)
+	return buf.Bytes(), err
+}
+
+func (tripLines) Write(w io.Writer, report model.ActReport, trips model.TripSource) error {
+	return trips(func(groupIndex int, trip model.TripDetail) error {
+		_, err := fmt.Fprintf(w, "%s %s\n", report.Number, trip.EventID)
+		return err
+	})
+}
+
+func TestWriteBatchArchive(t *testing.T) {
+	formats := []RenderFormat{
+		{Name: "txt", Extension: "txt", FilePrefix: "acts", Renderer: tripLines{}},
+		{Name: "count", Extension: "count", FilePrefix: "acts", Renderer: RendererFunc(func(report model.ActReport) ([]byte, error) {
+			return []byte(fmt.Sprint(report.TotalTrips)), nil
+		})},
+	}
+	// Both acts get the same file names, so the second ones must be renamed.
+	target := model.Organization{ID: uuid.New(), Name: "ТОО Север"}
+	var acts []batchAct
+	for k := range 2 {
+		report := &model.ActReport{
+			Number:     fmt.Sprintf("АКТ-2026-%06d", k+1),
+			Mode:       model.ReportModeContractor,
+			Target:     target,
+			TotalTrips: 2,
+			Groups: []model.TripGroup{{
+				Name:      "Полигон",
+				TripCount: 2,
+				Trips:     []model.TripDetail{{EventID: uuid.New()}, {EventID: uuid.New()}},
+			}},
+		}
+		acts = append(acts, batchAct{act: &model.Act{ID: uuid.New()}, report: report})
+	}
+
+	var buf bytes.Buffer
+	if err := (&ActService{}).writeBatchArchive(context.Background(), &buf, acts, formats); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	contents := make(map[string]string)
+	var names []string
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, file.Name)
+		contents[file.Name] = string(content)
+	}
+	if len(names) != 5 || names[4] != batchManifestName {
+		t.Fatalf("entries = %v, want four documents and the manifest", names)
+	}
+	if names[0] == names[2] || !strings.Contains(names[2], "2026-000002") {
+		t.Errorf("repeated name not made unique: %v", names)
+	}
+	if lines := strings.Count(contents[names[2]], "АКТ-2026-000002"); lines != 2 {
+		t.Errorf("streamed document has %d trip lines, want 2:\n%s", lines, contents[names[2]])
+	}
+	if contents[names[1]] != "2" {
+		t.Errorf("generated document = %q, want 2", contents[names[1]])
+	}
+	if rows := strings.Count(contents[batchManifestName], "\r\n"); rows != 5 {
+		t.Errorf("manifest has %d rows, want a header and 4 files:\n%s", rows, contents[batchManifestName])
+	}
+	for _, act := range acts {
+		if act.report.Groups[0].Trips != nil {
+			t.Errorf("act %s kept its trips after they were written", act.report.Number)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/repository"
+)
+
+// Formats of corrective acts.
+const (
+	FormatCorrectiveXLSX = "corrective-xlsx"
+	FormatCorrectivePDF  = "corrective-pdf"
+)
+
+// CreateCorrectiveAct recalculates the period of an issued act and registers
+// a corrective act that references it. The recalculated figures are compared
+// with the last corrective act of the original act, or with the original act
+// if it was not corrected yet, so a difference is billed only once. The
+// document shows the compared figures, the recalculated ones and the
+// difference per group; the act totals hold the difference only. The
+// document is in lang, the default language when empty. Only Akimat and KGU
+// issue corrective acts.
+func (s *ActService) CreateCorrectiveAct(
+	ctx context.Context,
+	originalID uuid.UUID,
+	format, lang string,
+	principal model.Principal,
+) (*GenerateReportResult, error) {
+	if !(principal.IsAkimat() || principal.IsKgu()) {
+		return nil, ErrPermissionDenied
+	}
+	format, err := correctiveFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if lang != "" && !labels.Supported(lang) {
+		return nil, fmt.Errorf("%w: unsupported language %q", ErrInvalidInput, lang)
+	}
+
+	original, err := s.visibleAct(ctx, originalID, principal)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCorrectable(original); err != nil {
+		return nil, err
+	}
+
+	base := original
+	previous, err := s.acts.LatestCorrective(ctx, original.ID)
+	switch {
+	case err == nil:
+		base = previous
+	case err != gorm.ErrRecordNotFound:
+		return nil, err
+	}
+	before, err := s.acts.GetGroupTotals(ctx, base.ID)
+	if err != nil {
+		return nil, err
+	}
+	report, err := s.buildReport(ctx, GenerateReportInput{
+		Mode:        original.Mode,
+		TargetID:    original.TargetID,
+		LandfillID:  uuidValue(original.LandfillID),
+		PeriodStart: original.PeriodStart,
+		PeriodEnd:   original.PeriodEnd,
+		Principal:   principal,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	correction := buildCorrection(original, base, before, reportGroupTotals(report))
+	if correction.Delta() == (model.GroupTotals{}) {
+		return nil, fmt.Errorf("%w: act data has not changed since issuance", ErrConflict)
+	}
+	report.Lang = lang
+	correction.Lang = lang
+	if err := s.prepareIssue(ctx, s.repo, report); err != nil {
+		return nil, err
+	}
+
+	act := s.newIssuedAct(principal, report, format, time.Now())
+	act.Kind = model.ActKindCorrective
+	act.OriginalActID = &original.ID
+	act.BaseActID = &base.ID
+	setCorrectionTotals(act, correction)
+
+	err = s.acts.Register(ctx, act, s.numberPrefix, func(act *model.Act) (*model.ActReport, error) {
+		report.Number = act.Number
+		report.IssuedAt = act.CreatedAt
+		correction.Number = act.Number
+		correction.IssuedAt = act.CreatedAt
+		return report, nil
+	})
+	if err != nil {
+		if repository.IsConflict(err) {
+			return nil, fmt.Errorf("%w: act is being corrected concurrently", ErrConflict)
+		}
+		return nil, err
+	}
+
+	// Rendered after the commit, like an export, so the number sequence is
+	// not locked meanwhile.
+	result, err := s.renderCorrective(correction, format)
+	if err != nil {
+		if cancelErr := s.cancelIssued(context.WithoutCancel(ctx), act, principal, exportCancelReason); cancelErr != nil {
+			return nil, errors.Join(err, fmt.Errorf("cancel act %s: %w", act.Number, cancelErr))
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkCorrectable returns why original cannot be corrected, if it cannot.
+// Only an issued, signed or approved regular act can be: a corrective act is
+// corrected through its original act, so all corrections of an act form one
+// chain and a difference is never billed by two of them.
+func checkCorrectable(original *model.Act) error {
+	if original.Kind == model.ActKindCorrective {
+		return fmt.Errorf("%w: a corrective act cannot be corrected, correct the original act instead", ErrInvalidInput)
+	}
+	switch original.Status {
+	case model.ActStatusIssued, model.ActStatusSigned, model.ActStatusApproved:
+		return nil
+	default:
+		return fmt.Errorf("%w: act in status %s cannot be corrected", ErrConflict, original.Status)
+	}
+}
+
+// renderCorrectiveSnapshot renders a corrective act again from the stored
+// group totals of the act it was compared with and of the corrective act
+// itself, in lang or, when empty, the language it was issued in. reprint
+// marks the document as a reprint.
+func (s *ActService) renderCorrectiveSnapshot(ctx context.Context, act *model.Act, format, lang string, reprint bool) (*GenerateReportResult, error) {
+	format, err := correctiveFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if lang == "" {
+		header, err := s.acts.GetSnapshotHeader(ctx, act.ID)
+		switch {
+		case err == nil:
+			lang = header.Lang
+		case err != gorm.ErrRecordNotFound:
+			return nil, err
+		}
+	}
+	if act.OriginalActID == nil {
+		return nil, fmt.Errorf("%w: original act", ErrNotFound)
+	}
+	original, err := s.acts.Get(ctx, *act.OriginalActID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: original act", ErrNotFound)
+		}
+		return nil, err
+	}
+	base := original
+	if act.BaseActID != nil && *act.BaseActID != original.ID {
+		base, err = s.acts.Get(ctx, *act.BaseActID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, fmt.Errorf("%w: previous corrective act", ErrNotFound)
+			}
+			return nil, err
+		}
+	}
+	before, err := s.acts.GetGroupTotals(ctx, base.ID)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.acts.GetGroupTotals(ctx, act.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	correction := buildCorrection(original, base, before, after)
+	correction.Number = act.Number
+	if act.IssuedAt != nil {
+		correction.IssuedAt = *act.IssuedAt
+	}
+	correction.Reprint = reprint
+	correction.Lang = lang
+	return s.renderCorrective(correction, format)
+}
+
+func (s *ActService) renderCorrective(report model.CorrectiveReport, format string) (*GenerateReportResult, error) {
+	var content []byte
+	var err error
+	extension, contentType := "xlsx", MIMETypeXLSX
+	switch format {
+	case FormatCorrectiveXLSX:
+		content, err = s.excel.GenerateCorrective(report)
+	case FormatCorrectivePDF:
+		content, err = s.pdf.GenerateCorrective(report)
+		extension, contentType = "pdf", MIMETypePDF
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidInput, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	number := sanitizeFileName(report.Number)
+	if number == "" {
+		number = report.OriginalID.String()
+	}
+	return &GenerateReportResult{
+		FileName:    fmt.Sprintf("corrective-%s.%s", number, extension),
+		ContentType: contentType,
+		Content:     content,
+	}, nil
+}
+
+// correctiveFormat maps a requested document format to the corrective
+// variant of it. Empty means Excel.
+func correctiveFormat(format string) (string, error) {
+	switch format {
+	case "", FormatXLSX, FormatCorrectiveXLSX:
+		return FormatCorrectiveXLSX, nil
+	case FormatPDF, FormatCorrectivePDF:
+		return FormatCorrectivePDF, nil
+	default:
+		return "", fmt.Errorf("%w: unsupported corrective act format %q", ErrInvalidInput, format)
+	}
+}
+
+// buildCorrection pairs the groups of base, the original act or its last
+// corrective act, with the recalculated ones. Groups are matched by
+// organization ID and keep the order of base; groups without trips on both
+// sides are left out.
+func buildCorrection(original, base *model.Act, before, after []model.GroupTotals) model.CorrectiveReport {
+	report := model.CorrectiveReport{
+		OriginalID:     original.ID,
+		OriginalNumber: original.Number,
+		Mode:           original.Mode,
+		Target:         model.Organization{ID: original.TargetID, Name: original.TargetName},
+		PeriodStart:    original.PeriodStart,
+		PeriodEnd:      original.PeriodEnd,
+	}
+	if original.IssuedAt != nil {
+		report.OriginalIssuedAt = *original.IssuedAt
+	}
+	if base.ID != original.ID {
+		report.PreviousNumber = base.Number
+		if base.IssuedAt != nil {
+			report.PreviousIssuedAt = *base.IssuedAt
+		}
+	}
+
+	index := make(map[uuid.UUID]int, len(before))
+	rows := make([]model.CorrectionRow, 0, len(before))
+	for _, totals := range before {
+		index[totals.GroupID] = len(rows)
+		rows = append(rows, model.CorrectionRow{GroupName: totals.GroupName, Before: totals})
+	}
+	for _, totals := range after {
+		pos, ok := index[totals.GroupID]
+		if !ok {
+			index[totals.GroupID] = len(rows)
+			rows = append(rows, model.CorrectionRow{GroupName: totals.GroupName, After: totals})
+			continue
+		}
+		rows[pos].After = totals
+		if totals.GroupName != "" {
+			rows[pos].GroupName = totals.GroupName
+		}
+	}
+
+	for _, row := range rows {
+		if row.Before.TripCount == 0 && row.After.TripCount == 0 &&
+			row.Before.GrossAmount == 0 && row.After.GrossAmount == 0 {
+			continue
+		}
+		report.Rows = append(report.Rows, row)
+		report.Before = report.Before.Add(row.Before)
+		report.After = report.After.Add(row.After)
+	}
+	report.Before = roundTotals(report.Before)
+	report.After = roundTotals(report.After)
+	return report
+}
+
+func reportGroupTotals(report *model.ActReport) []model.GroupTotals {
+	totals := make([]model.GroupTotals, 0, len(report.Groups))
+	for i, group := range report.Groups {
+		totals = append(totals, model.GroupTotals{
+			GroupIndex:  i,
+			GroupID:     group.ID,
+			GroupName:   group.Name,
+			TripCount:   group.TripCount,
+			VolumeM3:    group.VolumeM3,
+			NetAmount:   group.NetAmount,
+			VATAmount:   group.VATAmount,
+			GrossAmount: group.GrossAmount,
+		})
+	}
+	return totals
+}
+
+// roundTotals drops the float noise of summed figures, so an unchanged act
+// has an exactly zero difference.
+func roundTotals(t model.GroupTotals) model.GroupTotals {
+	t.GroupIndex = 0
+	t.GroupID = uuid.Nil
+	t.GroupName = ""
+	t.VolumeM3 = math.Round(t.VolumeM3*1000) / 1000
+	t.NetAmount = roundMoney(t.NetAmount)
+	t.VATAmount = roundMoney(t.VATAmount)
+	t.GrossAmount = roundMoney(t.GrossAmount)
+	return t
+}
+
+func setCorrectionTotals(act *model.Act, report model.CorrectiveReport) {
+	delta := report.Delta()
+	act.TotalTrips = delta.TripCount
+	act.TotalVolumeM3 = delta.VolumeM3
+	act.NetAmount = delta.NetAmount
+	act.VATAmount = delta.VATAmount
+	act.GrossAmount = delta.GrossAmount
+}
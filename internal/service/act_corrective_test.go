@@ -0,0 +1,35 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+func TestCheckCorrectable(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   model.ActKind
+		status model.ActStatus
+		want   error
+	}{
+		{"issued", model.ActKindRegular, model.ActStatusIssued, nil},
+		{"signed", model.ActKindRegular, model.ActStatusSigned, nil},
+		{"approved", model.ActKindRegular, model.ActStatusApproved, nil},
+		{"draft", model.ActKindRegular, model.ActStatusDraft, ErrConflict},
+		{"cancelled", model.ActKindRegular, model.ActStatusCancelled, ErrConflict},
+		// Correcting a correction would start a second chain next to the
+		// one of the original act and bill its difference again.
+		{"corrective", model.ActKindCorrective, model.ActStatusIssued, ErrInvalidInput},
+		{"corrective approved", model.ActKindCorrective, model.ActStatusApproved, ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCorrectable(&model.Act{Kind: tt.kind, Status: tt.status})
+			if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("checkCorrectable = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
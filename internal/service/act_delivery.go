@@ -0,0 +1,358 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/config"
+	"github.com/nurpe/snowops-acts/internal/mail"
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/repository"
+	"github.com/nurpe/snowops-acts/internal/textutil"
+)
+
+const (
+	// deliveryLease is how long a claimed email is kept from other workers.
+	deliveryLease = 10 * time.Minute
+	// deliveryFirstRetry is the pause after the first failed attempt; it
+	// doubles with every further attempt up to deliveryMaxRetry.
+	deliveryFirstRetry = time.Minute
+	deliveryMaxRetry   = time.Hour
+	// deliveryErrorLength limits the error stored with a delivery, in
+	// characters.
+	deliveryErrorLength = 500
+)
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, msg mail.Message) error
+}
+
+// DeliveryService emails acts with their documents attached to the
+// recipients of the organizations they are issued to. Emails are queued in
+// Postgres and sent by background workers with retries, so a send survives
+// SMTP outages and restarts.
+type DeliveryService struct {
+	deliveries   *repository.DeliveryRepository
+	acts         *ActService
+	mailer       Mailer
+	from         string
+	formats      []string
+	maxAttempts  int
+	pollInterval time.Duration
+	log          zerolog.Logger
+}
+
+type RecipientInput struct {
+	OrganizationID uuid.UUID
+	Email          string
+	Lang           string
+	Principal      model.Principal
+}
+
+// NewDeliveryService returns the service; a nil mailer disables sending.
+func NewDeliveryService(
+	deliveries *repository.DeliveryRepository,
+	acts *ActService,
+	mailer Mailer,
+	cfg *config.Config,
+	log zerolog.Logger,
+) (*DeliveryService, error) {
+	if _, err := acts.batchFormats(cfg.Mail.Formats); err != nil {
+		return nil, err
+	}
+	return &DeliveryService{
+		deliveries:   deliveries,
+		acts:         acts,
+		mailer:       mailer,
+		from:         cfg.Mail.From,
+		formats:      cfg.Mail.Formats,
+		maxAttempts:  cfg.Mail.MaxAttempts,
+		pollInterval: cfg.Mail.PollInterval,
+		log:          log,
+	}, nil
+}
+
+// Enabled reports whether emails can be sent.
+func (s *DeliveryService) Enabled() bool {
+	return s.mailer != nil
+}
+
+// ListRecipients returns the recipients of an organization. Akimat and KGU
+// manage the recipients of every organization, others only their own.
+func (s *DeliveryService) ListRecipients(ctx context.Context, orgID uuid.UUID, principal model.Principal) ([]model.Recipient, error) {
+	if !canManageRecipients(principal, orgID) {
+		return nil, ErrPermissionDenied
+	}
+	return s.deliveries.ListRecipients(ctx, orgID)
+}
+
+// AddRecipient adds an address to an organization; an address it already
+// has gets the new language.
+func (s *DeliveryService) AddRecipient(ctx context.Context, input RecipientInput) (*model.Recipient, error) {
+	if !canManageRecipients(input.Principal, input.OrganizationID) {
+		return nil, ErrPermissionDenied
+	}
+	email := strings.TrimSpace(input.Email)
+	if address, err := netmail.ParseAddress(email); err != nil || address.Address != email {
+		return nil, fmt.Errorf("%w: invalid email", ErrInvalidInput)
+	}
+	lang := strings.ToLower(strings.TrimSpace(input.Lang))
+	if lang == "" {
+		lang = mail.LangRussian
+	}
+	if !mail.SupportedLang(lang) {
+		return nil, fmt.Errorf("%w: unsupported email language %q", ErrInvalidInput, lang)
+	}
+	if _, err := s.acts.repo.GetOrganization(ctx, input.OrganizationID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	recipient := &model.Recipient{
+		ID:             uuid.New(),
+		OrganizationID: input.OrganizationID,
+		Email:          strings.ToLower(email),
+		Lang:           lang,
+		CreatedAt:      time.Now(),
+	}
+	if err := s.deliveries.CreateRecipient(ctx, recipient); err != nil {
+		return nil, err
+	}
+	return recipient, nil
+}
+
+func (s *DeliveryService) DeleteRecipient(ctx context.Context, orgID, id uuid.UUID, principal model.Principal) error {
+	if !canManageRecipients(principal, orgID) {
+		return ErrPermissionDenied
+	}
+	if err := s.deliveries.DeleteRecipient(ctx, orgID, id); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func canManageRecipients(principal model.Principal, orgID uuid.UUID) bool {
+	if principal.IsAkimat() || principal.IsKgu() {
+		return true
+	}
+	return !principal.IsDriver() && principal.OrgID == orgID
+}
+
+// SendAct queues the emails of an act to every recipient of the
+// organizations it is issued to: the target and, for a pair act, the
+// landfill.
+func (s *DeliveryService) SendAct(ctx context.Context, actID uuid.UUID, principal model.Principal) ([]model.ActDelivery, error) {
+	if !s.Enabled() {
+		return nil, fmt.Errorf("%w: email delivery is not configured", ErrConflict)
+	}
+	act, err := s.acts.visibleAct(ctx, actID, principal)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := s.enqueue(ctx, act)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("%w: the organization has no email recipients", ErrInvalidInput)
+	}
+	return deliveries, nil
+}
+
+// EnqueueAct queues the emails of an act issued without a request, e.g. by a
+// schedule. Acts of organizations without recipients are not emailed.
+func (s *DeliveryService) EnqueueAct(ctx context.Context, actID uuid.UUID) (int, error) {
+	if !s.Enabled() {
+		return 0, nil
+	}
+	act, err := s.acts.acts.Get(ctx, actID)
+	if err != nil {
+		return 0, err
+	}
+	deliveries, err := s.enqueue(ctx, act)
+	return len(deliveries), err
+}
+
+// ListDeliveries returns the emails of an act with their status.
+func (s *DeliveryService) ListDeliveries(ctx context.Context, actID uuid.UUID, principal model.Principal) ([]model.ActDelivery, error) {
+	if _, err := s.acts.visibleAct(ctx, actID, principal); err != nil {
+		return nil, err
+	}
+	return s.deliveries.ListDeliveries(ctx, actID)
+}
+
+func (s *DeliveryService) enqueue(ctx context.Context, act *model.Act) ([]model.ActDelivery, error) {
+	if act.Status == model.ActStatusDraft || act.Status == model.ActStatusCancelled {
+		return nil, fmt.Errorf("%w: act is %s", ErrConflict, act.Status)
+	}
+	orgIDs := []uuid.UUID{act.TargetID}
+	if act.LandfillID != nil {
+		orgIDs = append(orgIDs, *act.LandfillID)
+	}
+	recipients, err := s.deliveries.ListRecipients(ctx, orgIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deliveries := make([]model.ActDelivery, 0, len(recipients))
+	for _, recipient := range recipients {
+		deliveries = append(deliveries, model.ActDelivery{
+			ID:             uuid.New(),
+			ActID:          act.ID,
+			OrganizationID: recipient.OrganizationID,
+			Email:          recipient.Email,
+			Lang:           recipient.Lang,
+			Status:         model.DeliveryQueued,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+	if err := s.deliveries.CreateDeliveries(ctx, deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Run sends queued emails until ctx is done. It returns at once when email
+// delivery is disabled.
+func (s *DeliveryService) Run(ctx context.Context) {
+	if !s.Enabled() {
+		return
+	}
+	for {
+		now := time.Now()
+		delivery, err := s.deliveries.Claim(ctx, now, now.Add(deliveryLease))
+		if err != nil && ctx.Err() == nil {
+			s.log.Error().Err(err).Msg("claim act delivery failed")
+		}
+		if delivery != nil {
+			s.deliver(ctx, delivery)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+func (s *DeliveryService) deliver(ctx context.Context, delivery *model.ActDelivery) {
+	log := s.log.With().
+		Str("delivery_id", delivery.ID.String()).
+		Str("act_id", delivery.ActID.String()).
+		Logger()
+
+	err := s.send(ctx, delivery)
+	if err == nil {
+		if err := s.deliveries.MarkSent(ctx, delivery.ID); err != nil {
+			log.Error().Err(err).Msg("mark act delivery sent failed")
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	message := textutil.Truncate(err.Error(), deliveryErrorLength)
+	if delivery.Attempts >= s.maxAttempts {
+		log.Warn().Err(err).Int("attempts", delivery.Attempts).Msg("act delivery failed")
+		err = s.deliveries.Fail(ctx, delivery.ID, message)
+	} else {
+		log.Info().Err(err).Int("attempts", delivery.Attempts).Msg("act delivery will be retried")
+		next := time.Now().Add(retryBackoff(delivery.Attempts, deliveryFirstRetry, deliveryMaxRetry))
+		err = s.deliveries.Retry(ctx, delivery.ID, message, next)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("update act delivery failed")
+	}
+}
+
+// send renders the act documents in the recipient's language and emails
+// them.
+func (s *DeliveryService) send(ctx context.Context, delivery *model.ActDelivery) error {
+	act, err := s.acts.acts.Get(ctx, delivery.ActID)
+	if err != nil {
+		return err
+	}
+
+	var attachments []mail.Attachment
+	for _, format := range s.formats {
+		result, err := s.acts.renderSnapshot(ctx, act, format, delivery.Lang, false)
+		if err != nil {
+			return err
+		}
+		content := result.Content
+		if result.Stream != nil {
+			var buf bytes.Buffer
+			if err := result.Stream(&buf); err != nil {
+				return err
+			}
+			content = buf.Bytes()
+		}
+		attachments = append(attachments, mail.Attachment{
+			FileName:    result.FileName,
+			ContentType: result.ContentType,
+			Content:     content,
+		})
+	}
+
+	subject, body, err := mail.ActEmail(delivery.Lang, actSummary(act))
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mail.Message{
+		From:        s.from,
+		To:          []string{delivery.Email},
+		Subject:     subject,
+		Body:        body,
+		Attachments: attachments,
+	})
+}
+
+// actSummary returns the header of an act report with the registry totals
+// of act, which are the differences for a corrective act.
+func actSummary(act *model.Act) model.ActReport {
+	report := model.ActReport{
+		Mode:          act.Mode,
+		Number:        act.Number,
+		Target:        model.Organization{ID: act.TargetID, Name: act.TargetName},
+		PeriodStart:   act.PeriodStart,
+		PeriodEnd:     act.PeriodEnd,
+		TotalTrips:    act.TotalTrips,
+		TotalVolumeM3: act.TotalVolumeM3,
+	}
+	if act.LandfillID != nil {
+		report.Landfill = &model.Organization{ID: *act.LandfillID, Name: act.LandfillName}
+	}
+	return report
+}
+
+// retryBackoff returns the pause after the given number of failed attempts:
+// first, doubled with every further attempt up to limit.
+func retryBackoff(attempts int, first, limit time.Duration) time.Duration {
+	pause := first
+	for i := 1; i < attempts && pause < limit; i++ {
+		pause *= 2
+	}
+	return min(pause, limit)
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/repository"
+)
+
+// CheckDrift compares an issued act with the current ANPR data and stores the
+// result.
+func (s *ActService) CheckDrift(ctx context.Context, id uuid.UUID, principal model.Principal) (*model.DriftReport, error) {
+	act, err := s.visibleAct(ctx, id, principal)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkActDrift(ctx, act)
+}
+
+// ExportDrift runs a drift check and renders it as the drift workbook in lang,
+// the default language when empty.
+func (s *ActService) ExportDrift(ctx context.Context, id uuid.UUID, lang string, principal model.Principal) (*GenerateReportResult, error) {
+	if lang != "" && !labels.Supported(lang) {
+		return nil, fmt.Errorf("%w: unsupported language %q", ErrInvalidInput, lang)
+	}
+	report, err := s.CheckDrift(ctx, id, principal)
+	if err != nil {
+		return nil, err
+	}
+	report.Lang = lang
+	content, err := s.excel.GenerateDrift(*report)
+	if err != nil {
+		return nil, err
+	}
+	number := sanitizeFileName(report.ActNumber)
+	if number == "" {
+		number = report.ActID.String()
+	}
+	return &GenerateReportResult{
+		FileName:    fmt.Sprintf("drift-%s.xlsx", number),
+		ContentType: MIMETypeXLSX,
+		Content:     content,
+	}, nil
+}
+
+// ListDrift returns the stored results of the latest checks. Only Akimat and
+// KGU see them, since they decide on corrective acts.
+func (s *ActService) ListDrift(ctx context.Context, onlyDrift bool, principal model.Principal) ([]model.DriftReport, error) {
+	if !(principal.IsAkimat() || principal.IsKgu()) {
+		return nil, ErrPermissionDenied
+	}
+	return s.acts.ListDriftChecks(ctx, onlyDrift)
+}
+
+// CheckAllDrift checks the issued and signed acts whose period ends on or
+// after since; approved and cancelled acts are final and are not checked. A
+// failing act is logged and skipped so one broken snapshot does not stop the
+// run.
+func (s *ActService) CheckAllDrift(ctx context.Context, since time.Time, log zerolog.Logger) (checked, drifted int, err error) {
+	acts, err := s.acts.ListDriftCandidates(ctx, since)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i := range acts {
+		act := &acts[i]
+		if err := ctx.Err(); err != nil {
+			return checked, drifted, err
+		}
+		report, err := s.checkActDrift(ctx, act)
+		if err != nil {
+			log.Warn().Err(err).Str("act_id", act.ID.String()).Msg("drift check failed")
+			continue
+		}
+		checked++
+		if report.HasDrift {
+			drifted++
+		}
+	}
+	return checked, drifted, nil
+}
+
+func (s *ActService) checkActDrift(ctx context.Context, act *model.Act) (*model.DriftReport, error) {
+	if act.Status == model.ActStatusDraft {
+		return nil, fmt.Errorf("%w: draft acts have no snapshot", ErrConflict)
+	}
+	snapshot, err := s.acts.GetSnapshot(ctx, act.ID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: act snapshot", ErrNotFound)
+		}
+		return nil, err
+	}
+	current, err := s.collectReport(ctx, &reportScope{
+		mode:        snapshot.Mode,
+		target:      snapshot.Target,
+		landfill:    snapshot.Landfill,
+		periodStart: snapshot.PeriodStart,
+		periodEnd:   snapshot.PeriodEnd,
+		location:    snapshot.Location(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := diffReports(snapshot, current)
+	report.ActID = act.ID
+	report.ActNumber = act.Number
+	report.IssuedAt = act.IssuedAt
+	report.CheckedAt = time.Now()
+	if err := s.acts.SaveDriftCheck(ctx, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+type driftEvent struct {
+	trip      model.TripDetail
+	groupName string
+}
+
+func indexEvents(report *model.ActReport) map[uuid.UUID]driftEvent {
+	index := make(map[uuid.UUID]driftEvent, report.TotalTrips)
+	for _, group := range report.Groups {
+		for _, trip := range group.Trips {
+			index[trip.EventID] = driftEvent{trip: trip, groupName: group.Name}
+		}
+	}
+	return index
+}
+
+// diffReports lists events added, removed or with a changed volume in current
+// compared with the snapshot the act was issued from.
+func diffReports(snapshot, current *model.ActReport) *model.DriftReport {
+	report := &model.DriftReport{
+		Mode:               snapshot.Mode,
+		TargetID:           snapshot.Target.ID,
+		TargetName:         snapshot.Target.Name,
+		PeriodStart:        snapshot.PeriodStart,
+		PeriodEnd:          snapshot.PeriodEnd,
+		Fingerprint:        fingerprintReport(snapshot),
+		CurrentFingerprint: fingerprintReport(current),
+	}
+	if report.Fingerprint == report.CurrentFingerprint {
+		return report
+	}
+
+	loc := current.Location()
+	before := indexEvents(snapshot)
+	after := indexEvents(current)
+	for id, now := range after {
+		was, ok := before[id]
+		if !ok {
+			report.Added = append(report.Added, driftChange(model.DriftAdded, now, nil, now.trip.SnowVolumeM3, loc))
+			continue
+		}
+		if !sameVolume(was.trip.SnowVolumeM3, now.trip.SnowVolumeM3) {
+			report.Changed = append(report.Changed, driftChange(model.DriftChanged, now, was.trip.SnowVolumeM3, now.trip.SnowVolumeM3, loc))
+		}
+	}
+	for id, was := range before {
+		if _, ok := after[id]; !ok {
+			report.Removed = append(report.Removed, driftChange(model.DriftRemoved, was, was.trip.SnowVolumeM3, nil, loc))
+		}
+	}
+
+	for _, changes := range [][]model.DriftChange{report.Added, report.Removed, report.Changed} {
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].EventTime.Before(changes[j].EventTime)
+		})
+	}
+	report.HasDrift = len(report.Added)+len(report.Removed)+len(report.Changed) > 0
+	return report
+}
+
+// driftChange describes event with its time in loc, the zone of the act.
+func driftChange(kind model.DriftKind, event driftEvent, before, after *float64, loc *time.Location) model.DriftChange {
+	plate := ""
+	if event.trip.Plate != nil {
+		plate = *event.trip.Plate
+	}
+	return model.DriftChange{
+		Kind:         kind,
+		EventID:      event.trip.EventID,
+		EventTime:    event.trip.EventTime.In(loc),
+		Plate:        plate,
+		GroupName:    event.groupName,
+		VolumeBefore: before,
+		VolumeAfter:  after,
+	}
+}
+
+func sameVolume(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// driftLockKey identifies the advisory lock held during a drift check run.
+var driftLockKey = advisoryLockKey("drift-check")
+
+// DriftChecker periodically runs CheckAllDrift in the background over the
+// acts of the last months. A run holds a Postgres advisory lock, so only one
+// replica checks the acts at a time.
+type DriftChecker struct {
+	acts     *ActService
+	locks    *repository.ScheduledActRepository
+	interval time.Duration
+	months   int
+	log      zerolog.Logger
+}
+
+func NewDriftChecker(acts *ActService, locks *repository.ScheduledActRepository, interval time.Duration, months int, log zerolog.Logger) *DriftChecker {
+	return &DriftChecker{acts: acts, locks: locks, interval: interval, months: months, log: log}
+}
+
+// Run blocks until ctx is done. A non-positive interval disables the checker.
+func (c *DriftChecker) Run(ctx context.Context) {
+	if c.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.runOnce(ctx)
+		}
+	}
+}
+
+// runOnce checks the acts whose period ended within the last months, unless
+// another replica is checking them.
+func (c *DriftChecker) runOnce(ctx context.Context) {
+	now := time.Now().In(c.acts.location)
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -c.months, 0)
+	var checked, drifted int
+	locked, err := c.locks.WithLock(ctx, driftLockKey, func() error {
+		var err error
+		checked, drifted, err = c.acts.CheckAllDrift(ctx, since, c.log)
+		return err
+	})
+	switch {
+	case err != nil:
+		c.log.Error().Err(err).Msg("drift check run failed")
+	case !locked:
+		c.log.Info().Msg("drift check skipped, another instance is running it")
+	default:
+		c.log.Info().Int("checked", checked).Int("drifted", drifted).Msg("drift check finished")
+	}
+}
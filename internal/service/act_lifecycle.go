@@ -0,0 +1,399 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/labels"
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// CreateDraft stores an unnumbered act for the requested period. Totals are
+// informational until the act is issued.
+func (s *ActService) CreateDraft(ctx context.Context, input GenerateReportInput) (*model.Act, error) {
+	report, err := s.buildReport(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	act := newAct(input.Principal, report, time.Now())
+	if err := s.acts.CreateDraft(ctx, act); err != nil {
+		return nil, err
+	}
+	return s.acts.Get(ctx, act.ID)
+}
+
+// IssueAct recalculates a draft, assigns its registry number and freezes the
+// totals. The principal needs the same rights as for exporting the act.
+func (s *ActService) IssueAct(ctx context.Context, id uuid.UUID, principal model.Principal) (*model.Act, error) {
+	act, err := s.visibleAct(ctx, id, principal)
+	if err != nil {
+		return nil, err
+	}
+	if act.Status != model.ActStatusDraft {
+		return nil, fmt.Errorf("%w: only draft acts can be issued", ErrConflict)
+	}
+
+	report, err := s.buildReport(ctx, GenerateReportInput{
+		Mode:        act.Mode,
+		TargetID:    act.TargetID,
+		LandfillID:  uuidValue(act.LandfillID),
+		PeriodStart: act.PeriodStart,
+		PeriodEnd:   act.PeriodEnd,
+		Principal:   principal,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.prepareIssue(ctx, s.repo, report); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	setActTotals(act, report)
+	act.Year = now.In(s.location).Year()
+	act.IssuedAt = &now
+	act.IssuedBy = &principal.UserID
+	act.UpdatedAt = now
+	err = s.acts.Issue(ctx, act, s.numberPrefix, func(act *model.Act) (*model.ActReport, error) {
+		report.Number = act.Number
+		report.IssuedAt = now
+		return report, nil
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: act was changed concurrently", ErrConflict)
+		}
+		return nil, err
+	}
+	return s.acts.Get(ctx, act.ID)
+}
+
+// SignAct records the principal's side: the contractor's signature on a
+// contractor act, or acceptance by KGU or by the landfill of a landfill act.
+// The act becomes SIGNED once every required side has signed.
+func (s *ActService) SignAct(ctx context.Context, id uuid.UUID, principal model.Principal) (*model.Act, error) {
+	act, err := s.visibleAct(ctx, id, principal)
+	if err != nil {
+		return nil, err
+	}
+	if act.Status != model.ActStatusIssued {
+		return nil, fmt.Errorf("%w: only issued acts can be signed", ErrConflict)
+	}
+
+	now := time.Now()
+	switch {
+	case principal.IsContractor() && act.NeedsContractorSignature() && act.TargetID == principal.OrgID:
+		if act.ContractorSignedAt != nil {
+			return nil, fmt.Errorf("%w: act is already signed by contractor", ErrConflict)
+		}
+		act.ContractorSignedAt = &now
+		act.ContractorSignedBy = &principal.UserID
+	case principal.IsKgu() ||
+		(principal.IsLandfill() && act.Mode == model.ReportModeLandfill && act.TargetID == principal.OrgID) ||
+		(principal.IsLandfill() && act.Mode == model.ReportModePair && act.CoversOrg(principal.OrgID)):
+		if act.AcceptedAt != nil {
+			return nil, fmt.Errorf("%w: act is already accepted", ErrConflict)
+		}
+		act.AcceptedAt = &now
+		act.AcceptedBy = &principal.UserID
+		act.AcceptedByOrg = &principal.OrgID
+	default:
+		return nil, ErrPermissionDenied
+	}
+
+	if act.FullySigned() {
+		act.Status = model.ActStatusSigned
+	}
+	return s.transition(ctx, act, model.ActStatusIssued, now)
+}
+
+// ApproveAct finalizes a signed act. Only Akimat and KGU may approve.
+func (s *ActService) ApproveAct(ctx context.Context, id uuid.UUID, principal model.Principal) (*model.Act, error) {
+	if !(principal.IsAkimat() || principal.IsKgu()) {
+		return nil, ErrPermissionDenied
+	}
+	act, err := s.visibleAct(ctx, id, principal)
+	if err != nil {
+		return nil, err
+	}
+	if act.Status != model.ActStatusSigned {
+		return nil, fmt.Errorf("%w: only signed acts can be approved", ErrConflict)
+	}
+
+	now := time.Now()
+	act.Status = model.ActStatusApproved
+	act.ApprovedAt = &now
+	act.ApprovedBy = &principal.UserID
+	return s.transition(ctx, act, model.ActStatusSigned, now)
+}
+
+// CancelAct cancels an act that is not approved yet. Akimat and KGU may cancel
+// any such act; other organizations may only cancel their own drafts.
+func (s *ActService) CancelAct(ctx context.Context, id uuid.UUID, reason string, principal model.Principal) (*model.Act, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidInput)
+	}
+	act, err := s.visibleAct(ctx, id, principal)
+	if err != nil {
+		return nil, err
+	}
+
+	from := act.Status
+	switch from {
+	case model.ActStatusDraft, model.ActStatusIssued, model.ActStatusSigned:
+	default:
+		return nil, fmt.Errorf("%w: act in status %s cannot be cancelled", ErrConflict, from)
+	}
+	privileged := principal.IsAkimat() || principal.IsKgu()
+	if !privileged && !(from == model.ActStatusDraft && act.CreatedByOrg == principal.OrgID) {
+		return nil, ErrPermissionDenied
+	}
+
+	now := time.Now()
+	act.Status = model.ActStatusCancelled
+	act.CancelledAt = &now
+	act.CancelledBy = &principal.UserID
+	act.CancelReason = reason
+	return s.transition(ctx, act, from, now)
+}
+
+// ListActs returns acts visible to the principal: all acts for Akimat and KGU,
+// otherwise acts about or created by the principal's organization.
+func (s *ActService) ListActs(ctx context.Context, filter model.ActFilter, principal model.Principal) ([]model.Act, error) {
+	if principal.IsDriver() {
+		return nil, ErrPermissionDenied
+	}
+	if !(principal.IsAkimat() || principal.IsKgu()) {
+		filter.OrgID = principal.OrgID
+	}
+	return s.acts.List(ctx, filter)
+}
+
+func (s *ActService) GetAct(ctx context.Context, id uuid.UUID, principal model.Principal) (*model.Act, error) {
+	return s.visibleAct(ctx, id, principal)
+}
+
+// ReprintAct renders an issued act again from its snapshot, so the document
+// matches the original apart from the reprint marker even if ANPR data has
+// changed since. An empty format reprints the act in its original format and
+// an empty lang in its original language.
+func (s *ActService) ReprintAct(ctx context.Context, id uuid.UUID, format, lang string, principal model.Principal) (*GenerateReportResult, error) {
+	return s.snapshotDocument(ctx, id, format, lang, principal, true)
+}
+
+// ActDocument renders an issued act from its snapshot as it was issued,
+// without the reprint marker, for systems that archive the document itself
+// rather than print it, like the receivers of webhooks.
+func (s *ActService) ActDocument(ctx context.Context, id uuid.UUID, format, lang string, principal model.Principal) (*GenerateReportResult, error) {
+	return s.snapshotDocument(ctx, id, format, lang, principal, false)
+}
+
+func (s *ActService) snapshotDocument(
+	ctx context.Context,
+	id uuid.UUID,
+	format, lang string,
+	principal model.Principal,
+	reprint bool,
+) (*GenerateReportResult, error) {
+	if lang != "" && !labels.Supported(lang) {
+		return nil, fmt.Errorf("%w: unsupported language %q", ErrInvalidInput, lang)
+	}
+	act, err := s.visibleAct(ctx, id, principal)
+	if err != nil {
+		return nil, err
+	}
+	if act.Status == model.ActStatusDraft {
+		return nil, fmt.Errorf("%w: draft acts have no snapshot", ErrConflict)
+	}
+	return s.renderSnapshot(ctx, act, format, lang, reprint)
+}
+
+// renderSnapshot renders the snapshot of an issued act in format, the format
+// the act was issued in when empty, and in lang, the language it was issued
+// in when empty. reprint marks the document as a reprint.
+func (s *ActService) renderSnapshot(ctx context.Context, act *model.Act, format, lang string, reprint bool) (*GenerateReportResult, error) {
+	if format == "" {
+		format = act.Format
+	}
+	if format == "" {
+		format = FormatXLSX
+	}
+
+	if act.Kind == model.ActKindCorrective {
+		return s.renderCorrectiveSnapshot(ctx, act, format, lang, reprint)
+	}
+
+	f, err := s.renderers.Lookup(format)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := f.Renderer.(StreamRenderer); ok {
+		report, err := s.acts.GetSnapshotHeader(ctx, act.ID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, fmt.Errorf("%w: act snapshot", ErrNotFound)
+			}
+			return nil, err
+		}
+		report.Reprint = reprint
+		if lang != "" {
+			report.Lang = lang
+		}
+		return s.streamDocument(ctx, act.ID, *report, f), nil
+	}
+
+	report, err := s.acts.GetSnapshot(ctx, act.ID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: act snapshot", ErrNotFound)
+		}
+		return nil, err
+	}
+	report.Reprint = reprint
+	if lang != "" {
+		report.Lang = lang
+	}
+	return s.render(*report, f)
+}
+
+// writeSnapshot writes the document of an issued act in format and lang, as
+// renderSnapshot renders it, to w and returns its file name and content type.
+// Streamed formats read the trips from the snapshot group by group, and
+// groupDone is called as each group starts and at the end with the number of
+// groups written and their total; other formats are rendered at once and
+// only report the end.
+func (s *ActService) writeSnapshot(
+	ctx context.Context,
+	w io.Writer,
+	act *model.Act,
+	format, lang string,
+	groupDone func(done, total int),
+) (fileName, contentType string, err error) {
+	if format == "" {
+		format = act.Format
+	}
+	f, err := s.renderers.Lookup(format)
+	if err != nil {
+		return "", "", err
+	}
+	renderer, ok := f.Renderer.(StreamRenderer)
+	if !ok || act.Kind == model.ActKindCorrective {
+		result, err := s.renderSnapshot(ctx, act, format, lang, false)
+		if err != nil {
+			return "", "", err
+		}
+		if result.Stream != nil {
+			err = result.Stream(w)
+		} else {
+			_, err = w.Write(result.Content)
+		}
+		if err != nil {
+			return "", "", err
+		}
+		groupDone(1, 1)
+		return result.FileName, result.ContentType, nil
+	}
+
+	report, err := s.acts.GetSnapshotHeader(ctx, act.ID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "", "", fmt.Errorf("%w: act snapshot", ErrNotFound)
+		}
+		return "", "", err
+	}
+	if lang != "" {
+		report.Lang = lang
+	}
+	total := len(report.Groups)
+	current := -1
+	err = renderer.Write(w, *report, func(fn func(groupIndex int, trip model.TripDetail) error) error {
+		return s.acts.StreamSnapshotTrips(ctx, act.ID, func(groupIndex int, trip model.TripDetail) error {
+			if groupIndex != current {
+				current = groupIndex
+				groupDone(groupIndex, total)
+			}
+			return fn(groupIndex, trip)
+		})
+	})
+	if err != nil {
+		return "", "", err
+	}
+	groupDone(total, total)
+	return buildFileName(f.FilePrefix, f.Extension, *report), f.MIMEType, nil
+}
+
+func (s *ActService) visibleAct(ctx context.Context, id uuid.UUID, principal model.Principal) (*model.Act, error) {
+	if principal.IsDriver() {
+		return nil, ErrPermissionDenied
+	}
+	act, err := s.acts.Get(ctx, id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if principal.IsAkimat() || principal.IsKgu() {
+		return act, nil
+	}
+	if act.CoversOrg(principal.OrgID) || act.CreatedByOrg == principal.OrgID {
+		return act, nil
+	}
+	return nil, ErrPermissionDenied
+}
+
+func (s *ActService) transition(ctx context.Context, act *model.Act, from model.ActStatus, now time.Time) (*model.Act, error) {
+	act.UpdatedAt = now
+	if err := s.acts.UpdateLifecycle(ctx, act, from); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: act was changed concurrently", ErrConflict)
+		}
+		return nil, err
+	}
+	return s.acts.Get(ctx, act.ID)
+}
+
+func newAct(principal model.Principal, report *model.ActReport, now time.Time) *model.Act {
+	act := &model.Act{
+		ID:           uuid.New(),
+		Kind:         model.ActKindRegular,
+		Mode:         report.Mode,
+		TargetID:     report.Target.ID,
+		PeriodStart:  report.PeriodStart,
+		PeriodEnd:    report.PeriodEnd,
+		CreatedBy:    principal.UserID,
+		CreatedByOrg: principal.OrgID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if report.Landfill != nil {
+		act.LandfillID = &report.Landfill.ID
+	}
+	setActTotals(act, report)
+	return act
+}
+
+func uuidValue(id *uuid.UUID) uuid.UUID {
+	if id == nil {
+		return uuid.Nil
+	}
+	return *id
+}
+
+func setActTotals(act *model.Act, report *model.ActReport) {
+	act.TotalTrips = report.TotalTrips
+	act.TotalVolumeM3 = report.TotalVolumeM3
+	act.NetAmount = report.NetAmount
+	act.VATAmount = report.VATAmount
+	act.GrossAmount = report.GrossAmount
+	act.Fingerprint = fingerprintReport(report)
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// MaxPreviewPageSize limits the trips returned in one preview page.
+const MaxPreviewPageSize = 500
+
+// PreviewPage selects the trips returned with a preview. A zero PageSize
+// returns no trips.
+type PreviewPage struct {
+	Page     int
+	PageSize int
+}
+
+// PreviewReport builds the report of input like an export does, with the same
+// validation and permissions, but does not register an act. Only the
+// requested page of trips is kept in memory.
+func (s *ActService) PreviewReport(ctx context.Context, input GenerateReportInput, page PreviewPage) (*model.ActPreview, error) {
+	if page.PageSize < 0 || page.PageSize > MaxPreviewPageSize {
+		return nil, fmt.Errorf("%w: page_size must be between 1 and %d", ErrInvalidInput, MaxPreviewPageSize)
+	}
+	if page.PageSize > 0 && page.Page < 1 {
+		return nil, fmt.Errorf("%w: page must be positive", ErrInvalidInput)
+	}
+
+	scope, err := s.authorizeReport(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	type pageTrip struct {
+		groupIndex int
+		trip       model.TripDetail
+	}
+	var (
+		trips []pageTrip
+		seen  int
+	)
+	offset := (page.Page - 1) * page.PageSize
+	sink := func(groupIndex, _ int, trip model.TripDetail) error {
+		if seen >= offset && seen < offset+page.PageSize {
+			trips = append(trips, pageTrip{groupIndex: groupIndex, trip: trip})
+		}
+		seen++
+		return nil
+	}
+	if page.PageSize == 0 {
+		sink = nil
+	}
+
+	report, _, err := s.streamReport(ctx, s.repo, scope, false, sink)
+	if err != nil {
+		return nil, err
+	}
+	report.HideEmptyGroups = input.HideEmptyGroups
+
+	preview := newActPreview(*report)
+	if page.PageSize > 0 {
+		items := make([]model.PreviewTrip, 0, len(trips))
+		for _, t := range trips {
+			group := report.Groups[t.groupIndex]
+			items = append(items, model.PreviewTrip{
+				EventID:      t.trip.EventID,
+				EventTime:    t.trip.EventTime.In(s.location),
+				Plate:        t.trip.Plate,
+				GroupID:      group.ID,
+				GroupName:    group.Name,
+				SnowVolumeM3: t.trip.SnowVolumeM3,
+			})
+		}
+		preview.Trips = &model.PreviewTripPage{
+			Page:     page.Page,
+			PageSize: page.PageSize,
+			Total:    report.TotalTrips,
+			Items:    items,
+		}
+	}
+	return preview, nil
+}
+
+func newActPreview(report model.ActReport) *model.ActPreview {
+	preview := &model.ActPreview{
+		Mode: report.Mode,
+		Target: model.PreviewOrganization{
+			ID:   report.Target.ID,
+			Name: report.Target.Name,
+			BIN:  report.Target.BIN,
+		},
+		PeriodStart:   report.PeriodStart,
+		PeriodEnd:     report.PeriodEnd,
+		Statuses:      report.Statuses,
+		TotalTrips:    report.TotalTrips,
+		TotalVolumeM3: report.TotalVolumeM3,
+		VATRate:       report.VATRate,
+		NetAmount:     report.NetAmount,
+		VATAmount:     report.VATAmount,
+		GrossAmount:   report.GrossAmount,
+		Groups:        make([]model.PreviewGroup, 0, len(report.Groups)),
+	}
+	for _, group := range report.Groups {
+		if !report.Shown(group) {
+			continue
+		}
+		preview.Groups = append(preview.Groups, model.PreviewGroup{
+			ID:            group.ID,
+			Name:          group.Name,
+			TripCount:     group.TripCount,
+			VolumeM3:      group.VolumeM3,
+			UnpricedTrips: group.UnpricedTrips,
+			NetAmount:     group.NetAmount,
+			VATAmount:     group.VATAmount,
+			GrossAmount:   group.GrossAmount,
+		})
+	}
+	return preview
+}
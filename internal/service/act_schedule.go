@@ -0,0 +1,340 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/nurpe/snowops-acts/internal/config"
+	"github.com/nurpe/snowops-acts/internal/cron"
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/repository"
+)
+
+// FileStore keeps the documents of scheduled acts and export jobs.
+type FileStore interface {
+	Put(ctx context.Context, key string, content []byte, contentType string) error
+	// PutReader stores content read from the start, without holding it in
+	// memory.
+	PutReader(ctx context.Context, key string, content io.ReadSeeker, contentType string) error
+	// Open reads the file under key; a missing file gives an error matching
+	// fs.ErrNotExist.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// ActScheduler issues the acts of every contractor and landfill for the
+// previous month on cron schedules and puts their documents into a FileStore.
+// Every act is issued under a Postgres advisory lock of its mode, target and
+// period, so schedules and replicas that would issue the same act exclude
+// each other whatever formats they render, and it is registered together with the records of its
+// documents, so a target whose act for the month is recorded is never issued
+// again; documents that failed to be stored are stored by the next run.
+// Issued acts are emailed to the recipients of their organizations.
+type ActScheduler struct {
+	acts       *ActService
+	records    *repository.ScheduledActRepository
+	store      FileStore
+	deliveries *DeliveryService
+	schedules  []cron.Schedule
+	formats    []string
+	principal  model.Principal
+	log        zerolog.Logger
+}
+
+func NewActScheduler(
+	acts *ActService,
+	records *repository.ScheduledActRepository,
+	store FileStore,
+	deliveries *DeliveryService,
+	cfg *config.Config,
+	log zerolog.Logger,
+) (*ActScheduler, error) {
+	s := &ActScheduler{
+		acts:       acts,
+		records:    records,
+		store:      store,
+		deliveries: deliveries,
+		formats:    cfg.Schedule.Formats,
+		// Scheduled acts are issued to the configured organization on
+		// behalf of KGU; there is no user behind them.
+		principal: model.Principal{OrgID: cfg.Schedule.OrgID, Role: model.UserRoleKguZkhAdmin},
+		log:       log,
+	}
+	for _, expr := range cfg.Schedule.Crons {
+		schedule, err := cron.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		s.schedules = append(s.schedules, schedule)
+	}
+	if _, err := acts.batchFormats(s.formats); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run blocks until ctx is done. Without schedules the scheduler is disabled.
+func (s *ActScheduler) Run(ctx context.Context) {
+	done := make(chan struct{})
+	for _, schedule := range s.schedules {
+		go func() {
+			s.runSchedule(ctx, schedule)
+			done <- struct{}{}
+		}()
+	}
+	for range s.schedules {
+		<-done
+	}
+}
+
+func (s *ActScheduler) runSchedule(ctx context.Context, schedule cron.Schedule) {
+	log := s.log.With().Str("schedule", schedule.String()).Logger()
+	for {
+		next := schedule.Next(time.Now().In(s.acts.location))
+		if next.IsZero() {
+			log.Warn().Msg("schedule never fires")
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		periodStart, periodEnd := previousMonth(next)
+		issued, skipped, failed, err := s.RunPeriod(ctx, schedule.String(), periodStart, periodEnd)
+		log.Info().
+			Str("period_start", periodStart.Format("2006-01-02")).
+			Int("issued", issued).Int("skipped", skipped).Int("failed", failed).
+			Msg("scheduled acts run finished")
+		if err != nil {
+			log.Error().Err(err).Msg("scheduled acts run failed")
+		}
+	}
+}
+
+// RunPeriod issues the acts of every contractor and landfill for the period
+// that are not recorded yet and stores their documents, and stores the
+// documents of recorded acts that are still missing. Targets whose documents
+// are all stored or that another instance is handling are skipped. A target
+// that fails is logged and skipped, so one broken act does not stop the run;
+// it is tried again on the next run.
+func (s *ActScheduler) RunPeriod(ctx context.Context, schedule string, periodStart, periodEnd time.Time) (issued, skipped, failed int, err error) {
+	formats, err := s.acts.batchFormats(s.formats)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	for _, mode := range []model.ReportMode{model.ReportModeContractor, model.ReportModeLandfill} {
+		var targets []model.TripGroup
+		if mode == model.ReportModeContractor {
+			targets, err = s.acts.repo.ListContractors(ctx)
+		} else {
+			targets, err = s.acts.repo.ListLandfills(ctx)
+		}
+		if err != nil {
+			return issued, skipped, failed, err
+		}
+
+		for _, target := range targets {
+			if err := ctx.Err(); err != nil {
+				return issued, skipped, failed, err
+			}
+			var done bool
+			locked, err := s.records.WithLock(ctx, actLockKey(mode, target.ID, periodStart, periodEnd), func() error {
+				var err error
+				done, err = s.runTarget(ctx, schedule, mode, target.ID, periodStart, periodEnd, formats)
+				return err
+			})
+			switch {
+			case err != nil:
+				s.log.Warn().Err(err).
+					Str("mode", string(mode)).
+					Str("target_id", target.ID.String()).
+					Msg("scheduled act failed")
+				failed++
+			case locked && done:
+				issued++
+			default:
+				skipped++
+			}
+		}
+	}
+	return issued, skipped, failed, nil
+}
+
+// runTarget issues the act of one target for the period, or stores the
+// missing documents of the act an earlier run issued. It reports false if
+// there was nothing to do.
+func (s *ActScheduler) runTarget(
+	ctx context.Context,
+	schedule string,
+	mode model.ReportMode,
+	targetID uuid.UUID,
+	periodStart, periodEnd time.Time,
+	formats []RenderFormat,
+) (bool, error) {
+	records, err := s.records.List(ctx, mode, targetID, periodStart, periodEnd)
+	if err != nil {
+		return false, err
+	}
+	if len(records) == 0 {
+		return s.issue(ctx, schedule, mode, targetID, periodStart, periodEnd, formats)
+	}
+
+	var missing []model.ScheduledAct
+	for _, record := range records {
+		if record.StoredAt == nil {
+			missing = append(missing, record)
+		}
+	}
+	if len(missing) == 0 {
+		return false, nil
+	}
+	act, err := s.acts.acts.Get(ctx, missing[0].ActID)
+	if err != nil {
+		return false, err
+	}
+	for _, record := range missing {
+		result, err := s.acts.renderSnapshot(ctx, act, record.Format, "", false)
+		if err != nil {
+			return false, err
+		}
+		if err := s.storeDocument(ctx, record, result); err != nil {
+			return false, err
+		}
+	}
+	s.enqueueDeliveries(ctx, act.ID)
+	return true, nil
+}
+
+// issue issues the act of one target with the records of its documents,
+// puts the documents into the store under <year>-<month>/<act number>-<file
+// name> and marks them stored. It reports false if the act was issued
+// concurrently.
+func (s *ActScheduler) issue(
+	ctx context.Context,
+	schedule string,
+	mode model.ReportMode,
+	targetID uuid.UUID,
+	periodStart, periodEnd time.Time,
+	formats []RenderFormat,
+) (bool, error) {
+	scope, err := s.acts.authorizeReport(ctx, GenerateReportInput{
+		Mode:        mode,
+		TargetID:    targetID,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Principal:   s.principal,
+	})
+	if err != nil {
+		return false, err
+	}
+	report, err := s.acts.collectReport(ctx, scope)
+	if err != nil {
+		return false, err
+	}
+	if err := s.acts.prepareIssue(ctx, s.acts.repo, report); err != nil {
+		return false, err
+	}
+
+	act := s.acts.newIssuedAct(s.principal, report, formats[0].Name, time.Now())
+	report.IssuedAt = act.CreatedAt
+	var records []model.ScheduledAct
+	err = s.acts.acts.RegisterScheduled(ctx, act, report, s.acts.numberPrefix, func(act *model.Act) []model.ScheduledAct {
+		records = make([]model.ScheduledAct, 0, len(formats))
+		for _, format := range formats {
+			fileName := buildFileName(format.FilePrefix, format.Extension, *report)
+			records = append(records, model.ScheduledAct{
+				ID:          uuid.New(),
+				Schedule:    schedule,
+				ActID:       act.ID,
+				ActNumber:   act.Number,
+				Mode:        mode,
+				TargetID:    targetID,
+				PeriodStart: scope.periodStart,
+				PeriodEnd:   scope.periodEnd,
+				Format:      format.Name,
+				FileName:    fileName,
+				StorageKey:  fmt.Sprintf("%s/%s-%s", scope.periodStart.Format("2006-01"), sanitizeFileName(act.Number), fileName),
+				CreatedAt:   act.CreatedAt,
+			})
+		}
+		return records
+	})
+	if err != nil {
+		if repository.IsConflict(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for i, record := range records {
+		result, err := s.acts.render(*report, formats[i])
+		if err != nil {
+			return false, err
+		}
+		if err := s.storeDocument(ctx, record, result); err != nil {
+			return false, err
+		}
+	}
+	s.enqueueDeliveries(ctx, act.ID)
+	return true, nil
+}
+
+// storeDocument puts the document of record into the store and marks it
+// stored.
+func (s *ActScheduler) storeDocument(ctx context.Context, record model.ScheduledAct, result *GenerateReportResult) error {
+	content := result.Content
+	if result.Stream != nil {
+		var buf bytes.Buffer
+		if err := result.Stream(&buf); err != nil {
+			return err
+		}
+		content = buf.Bytes()
+	}
+	if err := s.store.Put(ctx, record.StorageKey, content, result.ContentType); err != nil {
+		return fmt.Errorf("store act %s: %w", record.ActNumber, err)
+	}
+	return s.records.MarkStored(ctx, record.ID, int64(len(content)))
+}
+
+func (s *ActScheduler) enqueueDeliveries(ctx context.Context, actID uuid.UUID) {
+	if _, err := s.deliveries.EnqueueAct(ctx, actID); err != nil {
+		s.log.Warn().Err(err).Str("act_id", actID.String()).Msg("queue scheduled act emails failed")
+	}
+}
+
+// previousMonth returns the first and last day of the month before the one
+// of t, as dates.
+func previousMonth(t time.Time) (periodStart, periodEnd time.Time) {
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, -1, 0), first.AddDate(0, 0, -1)
+}
+
+// actLockKey identifies the advisory lock of the act of mode, target and
+// period. The formats are left out: one act is issued for the month whatever
+// documents a schedule renders of it.
+func actLockKey(mode model.ReportMode, targetID uuid.UUID, periodStart, periodEnd time.Time) int64 {
+	return advisoryLockKey("scheduled-act", string(mode), targetID.String(),
+		periodStart.Format("2006-01-02"), periodEnd.Format("2006-01-02"))
+}
+
+// advisoryLockKey hashes parts into the 64-bit key of a Postgres advisory
+// lock, so distinct acts practically never share a lock.
+func advisoryLockKey(parts ...string) int64 {
+	h := fnv.New64a()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return int64(h.Sum64())
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -10,22 +12,34 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/nurpe/snowops-acts/internal/config"
+	"github.com/nurpe/snowops-acts/internal/labels"
 	"github.com/nurpe/snowops-acts/internal/model"
 	"github.com/nurpe/snowops-acts/internal/repository"
 )
 
+// ExcelGenerator renders the workbooks that are not acts themselves. Acts are
+// rendered by the formats in the RendererRegistry.
 type ExcelGenerator interface {
-	Generate(report model.ActReport) ([]byte, error)
+	GenerateDrift(report model.DriftReport) ([]byte, error)
+	GenerateCorrective(report model.CorrectiveReport) ([]byte, error)
 }
 
 type PDFGenerator interface {
-	Generate(report model.ActReport) ([]byte, error)
+	GenerateCorrective(report model.CorrectiveReport) ([]byte, error)
 }
 
 type ActService struct {
-	repo  *repository.ReportRepository
-	excel ExcelGenerator
-	pdf   PDFGenerator
+	repo            *repository.ReportRepository
+	acts            *repository.ActRepository
+	renderers       *RendererRegistry
+	excel           ExcelGenerator
+	pdf             PDFGenerator
+	numberPrefix    string
+	vatRate         float64
+	workDescription string
+	location        *time.Location
+	// batchWorkers limits the acts of a batch export generated at once.
+	batchWorkers int
 }
 
 type GenerateReportInput struct {
@@ -33,68 +47,332 @@ type GenerateReportInput struct {
 	TargetID    uuid.UUID
 	PeriodStart time.Time
 	PeriodEnd   time.Time
-	Principal   model.Principal
+	// LandfillID is the landfill of a pair act.
+	LandfillID uuid.UUID
+	// Lang is the document language; empty means the default language.
+	Lang string
+	// HideEmptyGroups leaves groups without trips out of the documents.
+	HideEmptyGroups bool
+	// ActID is the ID to register the act under; a new one when nil.
+	ActID     uuid.UUID
+	Principal model.Principal
 }
 
+// Names of the built-in document formats of the RendererRegistry.
+const (
+	FormatXLSX              = "xlsx"
+	FormatPDF               = "pdf"
+	FormatCompletedWorksPDF = "completed-works-pdf"
+	FormatHTML              = "html"
+)
+
 type GenerateReportResult struct {
-	FileName string
-	Content  []byte
+	FileName    string
+	ContentType string
+	// Inline asks the client to show the document rather than save it.
+	Inline  bool
+	Content []byte
+	// Stream, when set, writes the document instead of Content, so large
+	// documents never have to be held in memory.
+	Stream func(w io.Writer) error
 }
 
-func NewActService(repo *repository.ReportRepository, excel ExcelGenerator, pdf PDFGenerator, cfg *config.Config) *ActService {
+func NewActService(
+	repo *repository.ReportRepository,
+	acts *repository.ActRepository,
+	renderers *RendererRegistry,
+	excel ExcelGenerator,
+	pdf PDFGenerator,
+	cfg *config.Config,
+) *ActService {
 	return &ActService{
-		repo:  repo,
-		excel: excel,
-		pdf:   pdf,
+		repo:            repo,
+		acts:            acts,
+		renderers:       renderers,
+		excel:           excel,
+		pdf:             pdf,
+		numberPrefix:    cfg.Acts.NumberPrefix,
+		vatRate:         cfg.Acts.VATRate,
+		workDescription: cfg.Acts.WorkDescription,
+		location:        cfg.Acts.Location,
+		batchWorkers:    cfg.Acts.BatchWorkers,
 	}
 }
 
-func (s *ActService) GenerateReport(ctx context.Context, input GenerateReportInput) (*GenerateReportResult, error) {
-	report, err := s.buildReport(ctx, input)
+// Location returns the reporting time zone. Dates of requests are calendar
+// days in it.
+func (s *ActService) Location() *time.Location {
+	return s.location
+}
+
+// Export registers an act for input and renders it in the named format.
+// Formats with a StreamRenderer are exported as a stream: trips are read
+// through a database cursor straight into the act snapshot, and the document
+// is written from the snapshot while the result is streamed, so neither step
+// holds every trip in memory.
+func (s *ActService) Export(ctx context.Context, input GenerateReportInput, format string) (*GenerateReportResult, error) {
+	f, err := s.renderers.Lookup(format)
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := f.Renderer.(StreamRenderer); ok {
+		return s.exportStreamed(ctx, input, f)
+	}
+	return s.export(ctx, input, f)
+}
 
-	content, err := s.excel.Generate(*report)
+// NegotiateFormat returns the format named in a request or, when none is
+// named, the one the Accept header asks for.
+func (s *ActService) NegotiateFormat(name, accept string) (string, error) {
+	if name != "" {
+		f, err := s.renderers.Lookup(name)
+		return f.Name, err
+	}
+	f, err := s.renderers.Negotiate(accept)
+	return f.Name, err
+}
+
+func (s *ActService) exportStreamed(ctx context.Context, input GenerateReportInput, format RenderFormat) (*GenerateReportResult, error) {
+	act, report, err := s.issueStreamed(ctx, input, format.Name)
 	if err != nil {
 		return nil, err
 	}
+	return s.streamDocument(ctx, act.ID, *report, format), nil
+}
+
+// issueStreamed registers the act input describes, writing its trips into
+// the snapshot while they are read, and returns the act with the report
+// header. The act records format as its format.
+func (s *ActService) issueStreamed(ctx context.Context, input GenerateReportInput, format string) (*model.Act, *model.ActReport, error) {
+	scope, err := s.authorizeReport(ctx, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	act := s.newIssuedAct(input.Principal, &model.ActReport{
+		Mode:        scope.mode,
+		Target:      scope.target,
+		Landfill:    scope.landfill,
+		PeriodStart: scope.periodStart,
+		PeriodEnd:   scope.periodEnd,
+	}, format, time.Now())
+	if input.ActID != uuid.Nil {
+		act.ID = input.ActID
+	}
+
+	var report *model.ActReport
+	err = s.acts.RegisterStreamed(ctx, act, s.numberPrefix, s.repo, func(
+		reports *repository.ReportRepository,
+		add func(groupIndex, position int, trip model.TripDetail) error,
+	) (*model.ActReport, error) {
+		collected, fingerprint, err := s.streamReport(ctx, reports, scope, false, add)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.prepareIssue(ctx, reports, collected); err != nil {
+			return nil, err
+		}
+		collected.IssuedAt = act.CreatedAt
+		collected.Lang = input.Lang
+		collected.HideEmptyGroups = input.HideEmptyGroups
+		setActTotals(act, collected)
+		act.Fingerprint = fingerprint
+		report = collected
+		return collected, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return act, report, nil
+}
 
-	fileName := s.buildFileName(*report)
+// streamDocument returns a result that writes the document from the act
+// snapshot when it is streamed. format must have a StreamRenderer.
+func (s *ActService) streamDocument(ctx context.Context, actID uuid.UUID, report model.ActReport, format RenderFormat) *GenerateReportResult {
+	renderer := format.Renderer.(StreamRenderer)
 	return &GenerateReportResult{
-		FileName: fileName,
-		Content:  content,
-	}, nil
+		FileName:    buildFileName(format.FilePrefix, format.Extension, report),
+		ContentType: format.MIMEType,
+		Inline:      format.Inline,
+		Stream: func(w io.Writer) error {
+			return renderer.Write(w, report, func(fn func(groupIndex int, trip model.TripDetail) error) error {
+				return s.acts.StreamSnapshotTrips(ctx, actID, fn)
+			})
+		},
+	}
 }
 
-func (s *ActService) GenerateReportPDF(ctx context.Context, input GenerateReportInput) (*GenerateReportResult, error) {
+// export builds the report, registers it as an issued act and renders it in
+// the requested format.
+func (s *ActService) export(ctx context.Context, input GenerateReportInput, format RenderFormat) (*GenerateReportResult, error) {
 	report, err := s.buildReport(ctx, input)
 	if err != nil {
 		return nil, err
 	}
+	report.Lang = input.Lang
+	report.HideEmptyGroups = input.HideEmptyGroups
 
-	content, err := s.pdf.Generate(*report)
+	act, err := s.registerAct(ctx, input.Principal, input.ActID, report, format.Name)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.render(*report, format)
 	if err != nil {
+		// The act is cancelled even if the request was.
+		if cancelErr := s.cancelIssued(context.WithoutCancel(ctx), act, input.Principal, exportCancelReason); cancelErr != nil {
+			return nil, errors.Join(err, fmt.Errorf("cancel act %s: %w", act.Number, cancelErr))
+		}
 		return nil, err
 	}
+	return result, nil
+}
 
-	fileName := s.buildPDFFileName(*report)
+func (s *ActService) render(report model.ActReport, format RenderFormat) (*GenerateReportResult, error) {
+	content, err := format.Renderer.Generate(report)
+	if err != nil {
+		return nil, err
+	}
 	return &GenerateReportResult{
-		FileName: fileName,
-		Content:  content,
+		FileName:    buildFileName(format.FilePrefix, format.Extension, report),
+		ContentType: format.MIMEType,
+		Inline:      format.Inline,
+		Content:     content,
 	}, nil
 }
 
+// loadCounterparties loads full organization details of groups with trips
+// through repo, all in one query.
+func (s *ActService) loadCounterparties(ctx context.Context, repo *repository.ReportRepository, report *model.ActReport) error {
+	var ids []uuid.UUID
+	for _, group := range report.Groups {
+		if group.TripCount > 0 && group.ID != uuid.Nil {
+			ids = append(ids, group.ID)
+		}
+	}
+	orgs, err := repo.ListOrganizations(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]*model.Organization, len(orgs))
+	for i := range orgs {
+		byID[orgs[i].ID] = &orgs[i]
+	}
+	for i := range report.Groups {
+		group := &report.Groups[i]
+		if org, ok := byID[group.ID]; ok && group.TripCount > 0 {
+			group.Organization = org
+		}
+	}
+	return nil
+}
+
+// prepareIssue completes report with everything any document format needs,
+// so the snapshot taken at issuance can later be rendered in every format.
+func (s *ActService) prepareIssue(ctx context.Context, repo *repository.ReportRepository, report *model.ActReport) error {
+	report.WorkDescription = s.workDescription
+	return s.loadCounterparties(ctx, repo, report)
+}
+
+// exportCancelReason is the cancel reason of an exported act whose document
+// could not be rendered.
+const exportCancelReason = "export failed after the act was registered"
+
+// registerAct issues the next registry number for report and stores the
+// report as the act snapshot under actID, a new ID when nil. The document is
+// rendered by the caller after the transaction commits, so the lock on the
+// number sequence is never held while a document is rendered. It returns the
+// registered act, with the number set on report.
+func (s *ActService) registerAct(
+	ctx context.Context,
+	principal model.Principal,
+	actID uuid.UUID,
+	report *model.ActReport,
+	format string,
+) (*model.Act, error) {
+	if err := s.prepareIssue(ctx, s.repo, report); err != nil {
+		return nil, err
+	}
+
+	act := s.newIssuedAct(principal, report, format, time.Now())
+	if actID != uuid.Nil {
+		act.ID = actID
+	}
+	err := s.acts.Register(ctx, act, s.numberPrefix, func(act *model.Act) (*model.ActReport, error) {
+		report.Number = act.Number
+		report.IssuedAt = act.CreatedAt
+		return report, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return act, nil
+}
+
+// cancelIssued cancels an issued act whose document could not be delivered,
+// so the registry shows why its number has no document.
+func (s *ActService) cancelIssued(ctx context.Context, act *model.Act, principal model.Principal, reason string) error {
+	now := time.Now()
+	act.Status = model.ActStatusCancelled
+	act.CancelledAt = &now
+	act.CancelledBy = &principal.UserID
+	act.CancelReason = reason
+	act.UpdatedAt = now
+	return s.acts.UpdateLifecycle(ctx, act, model.ActStatusIssued)
+}
+
+// newIssuedAct returns an act issued at now. Its year, which numbers it, is
+// the year of now in the reporting time zone, not the server's.
+func (s *ActService) newIssuedAct(principal model.Principal, report *model.ActReport, format string, now time.Time) *model.Act {
+	act := newAct(principal, report, now)
+	act.Year = now.In(s.location).Year()
+	act.Format = format
+	act.IssuedAt = &now
+	act.IssuedBy = &principal.UserID
+	return act
+}
+
 func (s *ActService) buildReport(ctx context.Context, input GenerateReportInput) (*model.ActReport, error) {
+	scope, err := s.authorizeReport(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return s.collectReport(ctx, scope)
+}
+
+// reportScope is what a report covers once the request is validated.
+type reportScope struct {
+	mode   model.ReportMode
+	target model.Organization
+	// landfill is the landfill of a pair act.
+	landfill    *model.Organization
+	periodStart time.Time
+	periodEnd   time.Time
+	// location is the zone the period days are counted in.
+	location *time.Location
+}
+
+func (s *reportScope) landfillID() uuid.UUID {
+	if s.landfill == nil {
+		return uuid.Nil
+	}
+	return s.landfill.ID
+}
+
+// authorizeReport validates input and checks that the principal may build the
+// requested report.
+func (s *ActService) authorizeReport(ctx context.Context, input GenerateReportInput) (*reportScope, error) {
 	if input.Principal.IsDriver() {
 		return nil, ErrPermissionDenied
 	}
-	if input.TargetID == uuid.Nil {
+	if input.TargetID == uuid.Nil && input.Mode != model.ReportModeCity {
 		return nil, fmt.Errorf("%w: target_id is required", ErrInvalidInput)
 	}
 	if input.PeriodStart.IsZero() || input.PeriodEnd.IsZero() {
 		return nil, fmt.Errorf("%w: period dates are required", ErrInvalidInput)
 	}
+	if input.Lang != "" && !labels.Supported(input.Lang) {
+		return nil, fmt.Errorf("%w: unsupported language %q", ErrInvalidInput, input.Lang)
+	}
 
 	periodStart := dateOnly(input.PeriodStart)
 	periodEnd := dateOnly(input.PeriodEnd)
@@ -102,11 +380,7 @@ func (s *ActService) buildReport(ctx context.Context, input GenerateReportInput)
 		return nil, fmt.Errorf("%w: period_start must be before or equal to period_end", ErrInvalidInput)
 	}
 
-	endExclusive := periodEnd.Add(24 * time.Hour)
-
-	var target *model.Organization
-	var groups []model.TripGroup
-	var landfillID uuid.UUID
+	var target, landfill *model.Organization
 
 	switch input.Mode {
 	case model.ReportModeContractor:
@@ -126,16 +400,6 @@ func (s *ActService) buildReport(ctx context.Context, input GenerateReportInput)
 		}
 		target = org
 
-		landfills, err := s.repo.ListLandfills(ctx)
-		if err != nil {
-			return nil, err
-		}
-		counts, err := s.repo.EventCountsByLandfill(ctx, input.TargetID, periodStart, endExclusive)
-		if err != nil {
-			return nil, err
-		}
-		groups = mergeGroups(landfills, counts)
-
 	case model.ReportModeLandfill:
 		if !(input.Principal.IsAkimat() || input.Principal.IsKgu() || input.Principal.IsLandfill()) {
 			return nil, ErrPermissionDenied
@@ -154,80 +418,163 @@ func (s *ActService) buildReport(ctx context.Context, input GenerateReportInput)
 			return nil, ErrPermissionDenied
 		}
 		target = org
-		landfillID = org.ID
-		contractors, err := s.repo.ListContractors(ctx)
+
+	case model.ReportModePair:
+		if input.LandfillID == uuid.Nil {
+			return nil, fmt.Errorf("%w: landfill_id is required", ErrInvalidInput)
+		}
+		if !(input.Principal.IsAkimat() || input.Principal.IsKgu() ||
+			(input.Principal.IsContractor() && input.Principal.OrgID == input.TargetID) ||
+			(input.Principal.IsLandfill() && input.Principal.OrgID == input.LandfillID)) {
+			return nil, ErrPermissionDenied
+		}
+		contractor, err := s.repo.GetOrganization(ctx, input.TargetID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, ErrNotFound
+			}
+			return nil, err
+		}
+		if !strings.EqualFold(contractor.Type, "CONTRACTOR") {
+			return nil, fmt.Errorf("%w: target_id must be CONTRACTOR organization", ErrInvalidInput)
+		}
+		pairLandfill, err := s.repo.GetOrganization(ctx, input.LandfillID)
 		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, ErrNotFound
+			}
 			return nil, err
 		}
-		counts, err := s.repo.EventCountsByContractor(ctx, landfillID, periodStart, endExclusive)
+		if !strings.EqualFold(pairLandfill.Type, "LANDFILL") {
+			return nil, fmt.Errorf("%w: landfill_id must be LANDFILL organization", ErrInvalidInput)
+		}
+		target = contractor
+		landfill = pairLandfill
+
+	case model.ReportModeCity:
+		// The city-wide act is issued to the customer: the principal's own
+		// organization unless another one is named.
+		if !(input.Principal.IsAkimat() || input.Principal.IsKgu()) {
+			return nil, ErrPermissionDenied
+		}
+		customerID := input.TargetID
+		if customerID == uuid.Nil {
+			customerID = input.Principal.OrgID
+		}
+		org, err := s.repo.GetOrganization(ctx, customerID)
 		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, ErrNotFound
+			}
 			return nil, err
 		}
-		groups = mergeGroups(contractors, counts)
+		target = org
 
 	default:
 		return nil, fmt.Errorf("%w: invalid report mode", ErrInvalidInput)
 	}
 
-	totalTrips := int64(0)
-	for _, group := range groups {
-		totalTrips += group.TripCount
-	}
-	for i := range groups {
-		switch input.Mode {
-		case model.ReportModeContractor:
-			if groups[i].ID == uuid.Nil {
-				continue
-			}
-			trips, err := s.repo.ListEventsByLandfill(ctx, input.TargetID, groups[i].ID, periodStart, endExclusive)
-			if err != nil {
-				return nil, err
-			}
-			groups[i].Trips = trips
-		case model.ReportModeLandfill:
-			if groups[i].ID == uuid.Nil {
-				continue
-			}
-			trips, err := s.repo.ListEventsByContractor(ctx, groups[i].ID, landfillID, periodStart, endExclusive)
-			if err != nil {
-				return nil, err
-			}
-			groups[i].Trips = trips
+	return &reportScope{
+		mode:        input.Mode,
+		target:      *target,
+		landfill:    landfill,
+		periodStart: periodStart,
+		periodEnd:   periodEnd,
+		location:    s.location,
+	}, nil
+}
+
+// collectReport loads groups, trips and amounts of an already authorized
+// report.
+func (s *ActService) collectReport(ctx context.Context, scope *reportScope) (*model.ActReport, error) {
+	report, _, err := s.streamReport(ctx, s.repo, scope, true, nil)
+	return report, err
+}
+
+// streamReport reads the trips of an authorized report through repo and
+// builds the report from them. Every trip is passed to sink, if
+// set, with its group index and position; the trips are only kept in the
+// report groups with keepTrips. The period is made of whole days in the scope
+// location, and trips are priced by their day there. It also returns the
+// report fingerprint.
+func (s *ActService) streamReport(
+	ctx context.Context,
+	repo *repository.ReportRepository,
+	scope *reportScope,
+	keepTrips bool,
+	sink func(groupIndex, position int, trip model.TripDetail) error,
+) (*model.ActReport, string, error) {
+	mode, periodStart, periodEnd, loc := scope.mode, scope.periodStart, scope.periodEnd, scope.location
+	from, to := periodBounds(periodStart, periodEnd, loc)
+
+	var base []model.TripGroup
+	var err error
+	switch mode {
+	case model.ReportModeContractor:
+		base, err = repo.ListLandfills(ctx)
+	case model.ReportModeLandfill, model.ReportModeCity:
+		base, err = repo.ListContractors(ctx)
+	case model.ReportModePair:
+		if scope.landfill == nil {
+			return nil, "", fmt.Errorf("%w: pair act without landfill", ErrInvalidInput)
 		}
+		base = []model.TripGroup{{ID: scope.landfill.ID, Name: scope.landfill.Name}}
+	default:
+		return nil, "", fmt.Errorf("%w: invalid report mode", ErrInvalidInput)
+	}
+	if err != nil {
+		return nil, "", err
 	}
 
-	report := model.ActReport{
-		Mode:        input.Mode,
-		Target:      *target,
-		PeriodStart: periodStart,
-		PeriodEnd:   periodEnd,
-		TotalTrips:  totalTrips,
-		Groups:      groups,
+	tariffs, err := repo.ListTariffs(ctx, periodStart, periodEnd.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, "", err
 	}
 
-	return &report, nil
+	builder := newReportBuilder(mode, base, tariffBook{tariffs: tariffs, location: loc}, keepTrips)
+	err = repo.StreamTrips(ctx, mode, scope.target.ID, scope.landfillID(), from, to, func(trip model.TripDetail) error {
+		groupIndex, position := builder.add(trip)
+		if sink == nil {
+			return nil
+		}
+		return sink(groupIndex, position, trip)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	report := builder.report(s.vatRate)
+	report.Mode = mode
+	report.Target = scope.target
+	report.Landfill = scope.landfill
+	report.PeriodStart = periodStart
+	report.PeriodEnd = periodEnd
+	report.TimeZone = loc.String()
+	report.Statuses = repo.ValidStatuses()
+	return &report, builder.fingerprint.sum(&report), nil
 }
 
-func (s *ActService) buildFileName(report model.ActReport) string {
+// buildFileName names a document after the mode, organization and period of
+// report, e.g. acts-contractor-<name>-20260101-20260131.xlsx.
+func buildFileName(prefix, extension string, report model.ActReport) string {
 	mode := strings.ToLower(string(report.Mode))
 	target := sanitizeFileName(report.Target.Name)
 	if target == "" {
 		target = report.Target.ID.String()
 	}
 	period := fmt.Sprintf("%s-%s", report.PeriodStart.Format("20060102"), report.PeriodEnd.Format("20060102"))
-	return fmt.Sprintf("acts-%s-%s-%s.xlsx", mode, target, period)
+	return fmt.Sprintf("%s-%s-%s-%s.%s", prefix, mode, target, period, extension)
 }
 
-func (s *ActService) buildPDFFileName(report model.ActReport) string {
-	mode := strings.ToLower(string(report.Mode))
-	target := sanitizeFileName(report.Target.Name)
-	if target == "" {
-		target = report.Target.ID.String()
-	}
-	period := fmt.Sprintf("%s-%s", report.PeriodStart.Format("20060102"), report.PeriodEnd.Format("20060102"))
-	return fmt.Sprintf("acts-%s-%s-%s.pdf", mode, target, period)
+// periodBounds returns the moments [from, to) that the inclusive dates
+// periodStart to periodEnd cover as calendar days in loc.
+func periodBounds(periodStart, periodEnd time.Time, loc *time.Location) (from, to time.Time) {
+	from = time.Date(periodStart.Year(), periodStart.Month(), periodStart.Day(), 0, 0, 0, 0, loc)
+	to = time.Date(periodEnd.Year(), periodEnd.Month(), periodEnd.Day()+1, 0, 0, 0, 0, loc)
+	return from, to
 }
 
+// dateOnly returns the calendar date of t in t's own location as UTC midnight.
 func dateOnly(t time.Time) time.Time {
 	if t.IsZero() {
 		return t
@@ -255,26 +602,10 @@ func sanitizeFileName(input string) string {
 	return strings.Trim(string(result), "-")
 }
 
-func mergeGroups(base []model.TripGroup, counts []model.TripGroup) []model.TripGroup {
-	result := make([]model.TripGroup, 0, len(base)+len(counts))
-	index := make(map[uuid.UUID]int, len(base))
-
-	for _, group := range base {
-		result = append(result, group)
-		index[group.ID] = len(result) - 1
-	}
-
-	for _, group := range counts {
-		if pos, ok := index[group.ID]; ok {
-			result[pos].TripCount = group.TripCount
-			if result[pos].Name == "" {
-				result[pos].Name = group.Name
-			}
-			continue
-		}
-		result = append(result, group)
-		index[group.ID] = len(result) - 1
+func sumTripVolume(groups []model.TripGroup) float64 {
+	total := 0.0
+	for _, group := range groups {
+		total += group.VolumeM3
 	}
-
-	return result
+	return total
 }
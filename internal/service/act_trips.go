@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/tripexport"
+)
+
+// Trip export formats: one row per trip instead of a rendered act.
+const (
+	FormatTripsCSV   = "csv"
+	FormatTripsJSONL = "jsonl"
+)
+
+var tripsContentTypes = map[string]string{
+	FormatTripsCSV:   "text/csv; charset=utf-8",
+	FormatTripsJSONL: "application/x-ndjson",
+}
+
+// tripsFormats lists the trip export formats for negotiation, the default
+// first.
+var tripsFormats = []string{FormatTripsCSV, FormatTripsJSONL}
+
+// NegotiateTripsFormat returns the trip export format named in a request or,
+// when none is named, the one the Accept header asks for, like
+// NegotiateFormat does for acts.
+func (s *ActService) NegotiateTripsFormat(name, accept string) (string, error) {
+	if name != "" {
+		if _, ok := tripsContentTypes[name]; !ok {
+			return "", fmt.Errorf("%w: unsupported format %q", ErrInvalidInput, name)
+		}
+		return name, nil
+	}
+	mimeTypes := make([]string, len(tripsFormats))
+	for i, format := range tripsFormats {
+		mimeTypes[i] = tripsContentTypes[format]
+	}
+	i, err := negotiateMIMEType(accept, mimeTypes)
+	if err != nil {
+		return "", err
+	}
+	return tripsFormats[i], nil
+}
+
+// TripExportOptions tunes the trip export.
+type TripExportOptions struct {
+	// Delimiter separates CSV fields; ',' when zero.
+	Delimiter rune
+}
+
+// ExportTrips exports the trips of the report input describes as CSV or JSON
+// Lines, with the same validation and permissions as an act export. The
+// trips are streamed from the database while the result is written, so any
+// period can be exported. No act is registered.
+func (s *ActService) ExportTrips(ctx context.Context, input GenerateReportInput, format string, opts TripExportOptions) (*GenerateReportResult, error) {
+	delimiter := opts.Delimiter
+	if delimiter == 0 {
+		delimiter = ','
+	}
+	switch format {
+	case FormatTripsCSV:
+		if delimiter != ',' && delimiter != ';' {
+			return nil, fmt.Errorf("%w: unsupported delimiter %q", ErrInvalidInput, delimiter)
+		}
+	case FormatTripsJSONL:
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidInput, format)
+	}
+
+	scope, err := s.authorizeReport(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := periodBounds(scope.periodStart, scope.periodEnd, scope.location)
+	stream := func(w io.Writer) error {
+		var out tripexport.Writer
+		if format == FormatTripsCSV {
+			csv, err := tripexport.NewCSV(w, delimiter)
+			if err != nil {
+				return err
+			}
+			out = csv
+		} else {
+			out = tripexport.NewJSONL(w)
+		}
+		err := s.repo.StreamTrips(ctx, scope.mode, scope.target.ID, scope.landfillID(), from, to, func(trip model.TripDetail) error {
+			return out.Write(tripRow(scope.mode, trip, scope.location))
+		})
+		if err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+
+	return &GenerateReportResult{
+		FileName: buildFileName("trips", format, model.ActReport{
+			Mode:        scope.mode,
+			Target:      scope.target,
+			PeriodStart: scope.periodStart,
+			PeriodEnd:   scope.periodEnd,
+		}),
+		ContentType: tripsContentTypes[format],
+		Stream:      stream,
+	}, nil
+}
+
+// tripRow describes trip with its event time in loc.
+func tripRow(mode model.ReportMode, trip model.TripDetail, loc *time.Location) tripexport.Row {
+	_, groupName := tripGroupKey(mode, trip)
+	return tripexport.Row{
+		EventID:      trip.EventID,
+		EventTime:    trip.EventTime.In(loc),
+		GroupName:    groupName,
+		Plate:        stringValue(trip.Plate),
+		Landfill:     stringValue(trip.PolygonName),
+		Contractor:   stringValue(trip.ContractorName),
+		SnowVolumeM3: trip.SnowVolumeM3,
+	}
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
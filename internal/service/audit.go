@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/config"
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/repository"
+)
+
+const (
+	// auditDefaultLimit and auditMaxLimit bound a page of the audit log.
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+)
+
+// AuditService keeps the log of act exports and access attempts. Entries
+// are recorded by the HTTP layer, which knows the outcome, duration and the
+// bytes sent; the log is read by Akimat admins.
+type AuditService struct {
+	audit    *repository.AuditRepository
+	acts     *repository.ActRepository
+	location *time.Location
+	log      zerolog.Logger
+}
+
+func NewAuditService(audit *repository.AuditRepository, acts *ActService, cfg *config.Config, log zerolog.Logger) *AuditService {
+	return &AuditService{audit: audit, acts: acts.acts, location: cfg.Acts.Location, log: log}
+}
+
+// Record stores an entry. An entry about an act gets the mode, target and
+// period of the act, so attempts to read another organization's act show
+// whose act it was.
+func (s *AuditService) Record(ctx context.Context, entry *model.AuditEntry) error {
+	if entry.ActID != nil && entry.TargetID == nil {
+		act, err := s.acts.Get(ctx, *entry.ActID)
+		switch {
+		case err == nil:
+			entry.Mode = act.Mode
+			entry.TargetID = &act.TargetID
+			entry.LandfillID = act.LandfillID
+			entry.PeriodStart = &act.PeriodStart
+			entry.PeriodEnd = &act.PeriodEnd
+		case err != gorm.ErrRecordNotFound:
+			return err
+		}
+	}
+	if entry.PeriodStart != nil {
+		start := dateOnly(*entry.PeriodStart)
+		entry.PeriodStart = &start
+	}
+	if entry.PeriodEnd != nil {
+		end := dateOnly(*entry.PeriodEnd)
+		entry.PeriodEnd = &end
+	}
+	entry.ForeignTarget = foreignTarget(entry)
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	return s.audit.Create(ctx, entry)
+}
+
+// foreignTarget reports whether the entry is a request of an organization
+// other than Akimat and KGU about acts of another organization.
+func foreignTarget(entry *model.AuditEntry) bool {
+	principal := model.Principal{OrgID: entry.OrgID, Role: entry.Role}
+	if principal.IsAkimat() || principal.IsKgu() {
+		return false
+	}
+	if entry.TargetID == nil && entry.LandfillID == nil {
+		return false
+	}
+	if entry.TargetID != nil && *entry.TargetID == principal.OrgID {
+		return false
+	}
+	return entry.LandfillID == nil || *entry.LandfillID != principal.OrgID
+}
+
+// ListAudit returns a page of the audit log, newest first.
+func (s *AuditService) ListAudit(ctx context.Context, filter model.AuditFilter, principal model.Principal) ([]model.AuditEntry, error) {
+	if !principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = auditDefaultLimit
+	}
+	if filter.Limit > auditMaxLimit {
+		return nil, fmt.Errorf("%w: limit must not exceed %d", ErrInvalidInput, auditMaxLimit)
+	}
+	return s.audit.List(ctx, filter)
+}
+
+var auditCSVHeader = []string{
+	"created_at", "action", "outcome", "status_code", "user_id", "org_id", "role", "foreign_target",
+	"mode", "target_id", "landfill_id", "act_id", "job_id", "period_start", "period_end", "format",
+	"duration_ms", "size_bytes", "checksum", "client_ip",
+}
+
+// ExportAuditCSV streams the audit log as CSV. Without a limit in filter
+// every matching entry is written.
+func (s *AuditService) ExportAuditCSV(ctx context.Context, filter model.AuditFilter, principal model.Principal) (*GenerateReportResult, error) {
+	if !principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	return &GenerateReportResult{
+		FileName:    "act-audit-" + time.Now().In(s.location).Format("20060102-150405") + ".csv",
+		ContentType: tripsContentTypes[FormatTripsCSV],
+		Stream: func(w io.Writer) error {
+			if _, err := io.WriteString(w, "\ufeff"); err != nil {
+				return err
+			}
+			rows := csv.NewWriter(w)
+			rows.UseCRLF = true
+			if err := rows.Write(auditCSVHeader); err != nil {
+				return err
+			}
+			err := s.audit.Stream(ctx, filter, func(entry model.AuditEntry) error {
+				return rows.Write(s.auditCSVRow(entry))
+			})
+			if err != nil {
+				return err
+			}
+			rows.Flush()
+			return rows.Error()
+		},
+	}, nil
+}
+
+func (s *AuditService) auditCSVRow(entry model.AuditEntry) []string {
+	id := func(value *uuid.UUID) string {
+		if value == nil {
+			return ""
+		}
+		return value.String()
+	}
+	date := func(value *time.Time) string {
+		if value == nil {
+			return ""
+		}
+		return value.Format("2006-01-02")
+	}
+	return []string{
+		entry.CreatedAt.In(s.location).Format("2006-01-02 15:04:05"),
+		entry.Action,
+		string(entry.Outcome),
+		strconv.Itoa(entry.StatusCode),
+		entry.UserID.String(),
+		entry.OrgID.String(),
+		string(entry.Role),
+		strconv.FormatBool(entry.ForeignTarget),
+		string(entry.Mode),
+		id(entry.TargetID),
+		id(entry.LandfillID),
+		id(entry.ActID),
+		id(entry.JobID),
+		date(entry.PeriodStart),
+		date(entry.PeriodEnd),
+		entry.Format,
+		strconv.FormatInt(entry.DurationMs, 10),
+		strconv.FormatInt(entry.SizeBytes, 10),
+		entry.Checksum,
+		entry.ClientIP,
+	}
+}
@@ -6,4 +6,8 @@ var (
 	ErrNotFound         = errors.New("not found")
 	ErrPermissionDenied = errors.New("permission denied")
 	ErrInvalidInput     = errors.New("invalid input")
+	ErrConflict         = errors.New("conflict")
+	// ErrNotAcceptable means no format of the export matches the Accept
+	// header of the request.
+	ErrNotAcceptable = errors.New("not acceptable")
 )
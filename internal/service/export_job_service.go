@@ -0,0 +1,337 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/repository"
+)
+
+const (
+	// jobStaleAfter is how long a running job may go without a heartbeat
+	// before another worker takes it over.
+	jobStaleAfter = 5 * time.Minute
+	// jobMaxAttempts limits how often an interrupted job is restarted.
+	jobMaxAttempts = 3
+)
+
+// Progress of a job: the act is issued by jobProgressIssued and its document
+// written group by group up to jobProgressRendered; storing the document
+// completes the job.
+const (
+	jobProgressStarted  = 10
+	jobProgressIssued   = 40
+	jobProgressRendered = 90
+)
+
+// ExportJobService runs act exports in the background. Jobs are stored in
+// Postgres, so queued and interrupted jobs are picked up again after a restart
+// by any instance. Documents are written to a temporary file and kept in the
+// file store, so neither the worker nor the database holds them in memory.
+type ExportJobService struct {
+	jobs         *repository.ExportJobRepository
+	acts         *ActService
+	store        FileStore
+	workers      int
+	pollInterval time.Duration
+	log          zerolog.Logger
+}
+
+func NewExportJobService(
+	jobs *repository.ExportJobRepository,
+	acts *ActService,
+	store FileStore,
+	workers int,
+	pollInterval time.Duration,
+	log zerolog.Logger,
+) *ExportJobService {
+	return &ExportJobService{
+		jobs:         jobs,
+		acts:         acts,
+		store:        store,
+		workers:      workers,
+		pollInterval: pollInterval,
+		log:          log,
+	}
+}
+
+// Enqueue validates the export like a direct request and queues it.
+func (s *ExportJobService) Enqueue(ctx context.Context, input GenerateReportInput, format string) (*model.ExportJob, error) {
+	if _, err := s.acts.renderers.Lookup(format); err != nil {
+		return nil, err
+	}
+	scope, err := s.acts.authorizeReport(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &model.ExportJob{
+		ID:              uuid.New(),
+		Status:          model.ExportJobQueued,
+		Format:          format,
+		Lang:            input.Lang,
+		Mode:            scope.mode,
+		TargetID:        scope.target.ID,
+		PeriodStart:     scope.periodStart,
+		PeriodEnd:       scope.periodEnd,
+		HideEmptyGroups: input.HideEmptyGroups,
+		CreatedBy:       input.Principal.UserID,
+		CreatedByOrg:    input.Principal.OrgID,
+		CreatedByRole:   input.Principal.Role,
+		CreatedAt:       time.Now(),
+		ActID:           uuid.New(),
+	}
+	if scope.landfill != nil {
+		landfillID := scope.landfill.ID
+		job.LandfillID = &landfillID
+	}
+	if err := s.jobs.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	return s.jobs.Get(ctx, job.ID)
+}
+
+// Get returns a job of the principal's organization.
+func (s *ExportJobService) Get(ctx context.Context, id uuid.UUID, principal model.Principal) (*model.ExportJob, error) {
+	job, err := s.jobs.Get(ctx, id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if job.CreatedByOrg != principal.OrgID {
+		return nil, ErrPermissionDenied
+	}
+	return job, nil
+}
+
+// File returns the document of a finished job.
+func (s *ExportJobService) File(ctx context.Context, id uuid.UUID, principal model.Principal) (*GenerateReportResult, error) {
+	job, err := s.Get(ctx, id, principal)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != model.ExportJobDone {
+		return nil, fmt.Errorf("%w: job is %s", ErrConflict, job.Status)
+	}
+	result := &GenerateReportResult{FileName: job.FileName}
+	if format, err := s.acts.renderers.Lookup(job.Format); err == nil {
+		result.ContentType = format.MIMEType
+		result.Inline = format.Inline
+	}
+
+	if job.StorageKey == "" {
+		result.Content, err = s.jobs.GetFile(ctx, job.ID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, fmt.Errorf("%w: job file", ErrNotFound)
+			}
+			return nil, err
+		}
+		return result, nil
+	}
+	file, err := s.store.Open(ctx, job.StorageKey)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: job file", ErrNotFound)
+		}
+		return nil, err
+	}
+	result.Stream = func(w io.Writer) error {
+		defer file.Close()
+		_, err := io.Copy(w, file)
+		return err
+	}
+	return result, nil
+}
+
+// Run starts the worker pool and blocks until ctx is done.
+func (s *ExportJobService) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *ExportJobService) work(ctx context.Context) {
+	for {
+		job, err := s.jobs.Claim(ctx, time.Now().Add(-jobStaleAfter), jobMaxAttempts)
+		if err != nil && ctx.Err() == nil {
+			s.log.Error().Err(err).Msg("claim export job failed")
+		}
+		if job != nil {
+			s.runJob(ctx, job)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+func (s *ExportJobService) runJob(ctx context.Context, job *model.ExportJob) {
+	log := s.log.With().Str("job_id", job.ID.String()).Logger()
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go s.heartbeat(heartbeatCtx, job)
+
+	// Progress is only stored when it grows, so a document of many small
+	// groups does not update the job for every one of them.
+	last := 0
+	progress := func(value int) {
+		if value <= last {
+			return
+		}
+		last = value
+		if err := s.jobs.SetProgress(ctx, job.ID, job.Attempts, value); err != nil {
+			log.Warn().Err(err).Msg("update export job progress failed")
+		}
+	}
+
+	progress(jobProgressStarted)
+	fileName, storageKey, size, err := s.export(ctx, job, progress)
+	if err != nil {
+		// An instance stopping mid-export leaves the job running; it is
+		// restarted once its heartbeat goes stale.
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn().Err(err).Msg("export job failed")
+		s.finished(log, s.jobs.Fail(ctx, job.ID, job.Attempts, jobErrorMessage(err)))
+		return
+	}
+	s.finished(log, s.jobs.Complete(ctx, job.ID, job.Attempts, fileName, storageKey, size))
+}
+
+// finished logs the outcome of storing the result of a job.
+func (s *ExportJobService) finished(log zerolog.Logger, err error) {
+	switch {
+	case err == nil:
+	case err == gorm.ErrRecordNotFound:
+		log.Warn().Msg("export job was taken over by another attempt, result dropped")
+	default:
+		log.Error().Err(err).Msg("store export job result failed")
+	}
+}
+
+// export issues the act of the job, writes its document from the act
+// snapshot into a temporary file, reporting progress as each group is
+// written, and puts the file into the store under jobs/<job id>/<file name>.
+func (s *ExportJobService) export(ctx context.Context, job *model.ExportJob, progress func(int)) (fileName, storageKey string, size int64, err error) {
+	act, err := s.issuedAct(ctx, job)
+	if err != nil {
+		return "", "", 0, err
+	}
+	progress(jobProgressIssued)
+
+	file, err := os.CreateTemp("", "export-job-*")
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+
+	w := bufio.NewWriter(file)
+	fileName, contentType, err := s.acts.writeSnapshot(ctx, w, act, job.Format, job.Lang, func(done, total int) {
+		if total > 0 {
+			progress(jobProgressIssued + (jobProgressRendered-jobProgressIssued)*done/total)
+		}
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return "", "", 0, err
+	}
+	if size, err = file.Seek(0, io.SeekCurrent); err != nil {
+		return "", "", 0, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", "", 0, err
+	}
+
+	storageKey = fmt.Sprintf("jobs/%s/%s", job.ID, fileName)
+	if err := s.store.PutReader(ctx, storageKey, file, contentType); err != nil {
+		return "", "", 0, fmt.Errorf("store job document: %w", err)
+	}
+	return fileName, storageKey, size, nil
+}
+
+// issuedAct returns the act of the job, registered under job.ActID. If an
+// earlier attempt already registered it, that act is returned, so a
+// restarted job never issues a second act.
+func (s *ExportJobService) issuedAct(ctx context.Context, job *model.ExportJob) (*model.Act, error) {
+	act, err := s.acts.acts.Get(ctx, job.ActID)
+	if err != gorm.ErrRecordNotFound {
+		return act, err
+	}
+
+	act, _, err = s.acts.issueStreamed(ctx, GenerateReportInput{
+		Mode:            job.Mode,
+		TargetID:        job.TargetID,
+		LandfillID:      uuidValue(job.LandfillID),
+		PeriodStart:     job.PeriodStart,
+		PeriodEnd:       job.PeriodEnd,
+		Lang:            job.Lang,
+		HideEmptyGroups: job.HideEmptyGroups,
+		ActID:           job.ActID,
+		Principal:       job.Principal(),
+	}, job.Format)
+	if err != nil && repository.IsConflict(err) {
+		// Another attempt registered the act meanwhile.
+		return s.acts.acts.Get(ctx, job.ActID)
+	}
+	return act, err
+}
+
+func (s *ExportJobService) heartbeat(ctx context.Context, job *model.ExportJob) {
+	ticker := time.NewTicker(jobStaleAfter / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.jobs.SetProgress(ctx, job.ID, job.Attempts, 0); err != nil && ctx.Err() == nil {
+				s.log.Warn().Err(err).Str("job_id", job.ID.String()).Msg("export job heartbeat failed")
+			}
+		}
+	}
+}
+
+// jobErrorMessage keeps internal error details out of the job status.
+func jobErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrPermissionDenied),
+		errors.Is(err, ErrNotFound),
+		errors.Is(err, ErrInvalidInput),
+		errors.Is(err, ErrConflict):
+		return err.Error()
+	default:
+		return "export failed"
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// fingerprintReport hashes what an act contains: mode, target, the landfill of
+// a pair act, period and the sorted list of event IDs with their volumes. Equal fingerprints mean the
+// same events were billed with the same volumes.
+func fingerprintReport(report *model.ActReport) string {
+	f := fingerprinter{lines: make([]string, 0, report.TotalTrips)}
+	for _, group := range report.Groups {
+		for _, trip := range group.Trips {
+			f.add(trip)
+		}
+	}
+	return f.sum(report)
+}
+
+// fingerprinter collects the fingerprint of trips fed one at a time. Only the
+// event ID and volume of each trip are kept.
+type fingerprinter struct {
+	lines []string
+}
+
+func (f *fingerprinter) add(trip model.TripDetail) {
+	volume := "null"
+	if trip.SnowVolumeM3 != nil {
+		volume = strconv.FormatFloat(*trip.SnowVolumeM3, 'f', -1, 64)
+	}
+	f.lines = append(f.lines, trip.EventID.String()+":"+volume)
+}
+
+func (f *fingerprinter) sum(report *model.ActReport) string {
+	sort.Strings(f.lines)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%s\n",
+		report.Mode,
+		report.Target.ID,
+		report.PeriodStart.Format("2006-01-02"),
+		report.PeriodEnd.Format("2006-01-02"),
+	)
+	if report.Landfill != nil {
+		fmt.Fprintf(h, "landfill|%s\n", report.Landfill.ID)
+	}
+	for _, line := range f.lines {
+		fmt.Fprintln(h, line)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/repository"
+)
+
+// LandfillCameraService manages the camera-to-landfill mapping used by every
+// report query. Only Akimat admins may read or change it.
+type LandfillCameraService struct {
+	cameras *repository.LandfillCameraRepository
+	reports *repository.ReportRepository
+}
+
+type LandfillCameraInput struct {
+	CameraID   string
+	LandfillID uuid.UUID
+	ValidFrom  time.Time
+	ValidTo    *time.Time
+	Principal  model.Principal
+}
+
+func NewLandfillCameraService(
+	cameras *repository.LandfillCameraRepository,
+	reports *repository.ReportRepository,
+) *LandfillCameraService {
+	return &LandfillCameraService{cameras: cameras, reports: reports}
+}
+
+func (s *LandfillCameraService) List(ctx context.Context, principal model.Principal) ([]model.LandfillCamera, error) {
+	if !principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	return s.cameras.List(ctx)
+}
+
+func (s *LandfillCameraService) Create(ctx context.Context, input LandfillCameraInput) (*model.LandfillCamera, error) {
+	if !input.Principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+
+	now := time.Now()
+	camera := &model.LandfillCamera{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.apply(ctx, camera, input); err != nil {
+		return nil, err
+	}
+	if err := s.cameras.Create(ctx, camera); err != nil {
+		return nil, cameraStoreError(camera, err)
+	}
+	return s.cameras.Get(ctx, camera.ID)
+}
+
+func (s *LandfillCameraService) Update(ctx context.Context, id uuid.UUID, input LandfillCameraInput) (*model.LandfillCamera, error) {
+	if !input.Principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+
+	camera, err := s.cameras.Get(ctx, id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	camera.UpdatedAt = time.Now()
+	if err := s.apply(ctx, camera, input); err != nil {
+		return nil, err
+	}
+	if err := s.cameras.Update(ctx, camera); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, cameraStoreError(camera, err)
+	}
+	return s.cameras.Get(ctx, camera.ID)
+}
+
+func (s *LandfillCameraService) Delete(ctx context.Context, id uuid.UUID, principal model.Principal) error {
+	if !principal.IsAkimatAdmin() {
+		return ErrPermissionDenied
+	}
+	if err := s.cameras.Delete(ctx, id); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// cameraStoreError maps a mapping that overlaps one stored concurrently to
+// ErrConflict.
+func cameraStoreError(camera *model.LandfillCamera, err error) error {
+	if repository.IsConflict(err) {
+		return fmt.Errorf("%w: camera %s is already mapped in this period", ErrConflict, camera.CameraID)
+	}
+	return err
+}
+
+// apply validates input and copies it into camera. Camera IDs are stored in
+// lower case because report queries match them against LOWER(camera_id).
+func (s *LandfillCameraService) apply(ctx context.Context, camera *model.LandfillCamera, input LandfillCameraInput) error {
+	cameraID := strings.ToLower(strings.TrimSpace(input.CameraID))
+	if cameraID == "" {
+		return fmt.Errorf("%w: camera_id is required", ErrInvalidInput)
+	}
+	if input.LandfillID == uuid.Nil {
+		return fmt.Errorf("%w: landfill_id is required", ErrInvalidInput)
+	}
+	if input.ValidFrom.IsZero() {
+		return fmt.Errorf("%w: valid_from is required", ErrInvalidInput)
+	}
+	if input.ValidTo != nil && !input.ValidTo.After(input.ValidFrom) {
+		return fmt.Errorf("%w: valid_to must be after valid_from", ErrInvalidInput)
+	}
+
+	org, err := s.reports.GetOrganization(ctx, input.LandfillID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("%w: landfill not found", ErrInvalidInput)
+		}
+		return err
+	}
+	if !strings.EqualFold(org.Type, "LANDFILL") {
+		return fmt.Errorf("%w: landfill_id must be LANDFILL organization", ErrInvalidInput)
+	}
+
+	// The check gives a clear error early; the exclusion constraint of the
+	// table rejects overlaps stored concurrently.
+	overlap, err := s.cameras.HasOverlap(ctx, cameraID, input.ValidFrom, input.ValidTo, camera.ID)
+	if err != nil {
+		return err
+	}
+	if overlap {
+		return fmt.Errorf("%w: camera %s is already mapped in this period", ErrConflict, cameraID)
+	}
+
+	camera.CameraID = cameraID
+	camera.LandfillID = input.LandfillID
+	camera.ValidFrom = input.ValidFrom
+	camera.ValidTo = input.ValidTo
+	return nil
+}
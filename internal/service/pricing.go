@@ -0,0 +1,118 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// tariffBook selects the tariff applicable to a single trip. Pricing is done
+// trip by trip on the trip's date, so a tariff change in the middle of the
+// period splits the group into separate charge lines. The trip's date is its
+// calendar day in location.
+type tariffBook struct {
+	tariffs  []model.Tariff
+	location *time.Location
+}
+
+func (b tariffBook) find(landfillID, contractorID *uuid.UUID, day time.Time) *model.Tariff {
+	var best *model.Tariff
+	bestScore := -1
+	for i := range b.tariffs {
+		t := &b.tariffs[i]
+		if day.Before(t.ValidFrom) || (t.ValidTo != nil && day.After(*t.ValidTo)) {
+			continue
+		}
+		score := 0
+		if t.ContractorID != nil {
+			if contractorID == nil || *t.ContractorID != *contractorID {
+				continue
+			}
+			score += 2
+		}
+		if t.LandfillID != nil {
+			if landfillID == nil || *t.LandfillID != *landfillID {
+				continue
+			}
+			score++
+		}
+		if score > bestScore || (score == bestScore && t.ValidFrom.After(best.ValidFrom)) {
+			best = t
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// charge prices one trip of group on the trip's date and returns the index of
+// its charge line in the group, -1 for an unpriced trip, and the unrounded
+// amount of the trip. lines indexes the group's charge lines by tariff.
+func (b tariffBook) charge(group *model.TripGroup, lines map[uuid.UUID]int, trip model.TripDetail) (int, float64) {
+	day := dateOnly(trip.EventTime.In(b.location))
+	tariff := b.find(trip.PolygonID, trip.ContractorID, day)
+	if tariff == nil {
+		group.UnpricedTrips++
+		return -1, 0
+	}
+
+	pos, ok := lines[tariff.ID]
+	if !ok {
+		group.Charges = append(group.Charges, model.ChargeLine{
+			TariffID:  tariff.ID,
+			Unit:      tariff.Unit,
+			Price:     tariff.Price,
+			FirstDate: day,
+		})
+		pos = len(group.Charges) - 1
+		lines[tariff.ID] = pos
+	}
+
+	line := &group.Charges[pos]
+	line.TripCount++
+	line.LastDate = day
+	quantity := 0.0
+	switch tariff.Unit {
+	case model.TariffUnitTrip:
+		quantity = 1
+	case model.TariffUnitM3:
+		if trip.SnowVolumeM3 != nil {
+			quantity = *trip.SnowVolumeM3
+		}
+	}
+	line.Quantity += quantity
+	return pos, quantity * tariff.Price
+}
+
+// settleAmounts fills the amounts of every group and the report totals from
+// the charge lines. VAT is calculated per group on the rounded net amount.
+func settleAmounts(report *model.ActReport, vatRate float64) {
+	report.VATRate = vatRate
+	report.NetAmount, report.VATAmount, report.GrossAmount = 0, 0, 0
+
+	for i := range report.Groups {
+		group := &report.Groups[i]
+		net := 0.0
+		for j := range group.Charges {
+			group.Charges[j].NetAmount = roundMoney(group.Charges[j].Price * group.Charges[j].Quantity)
+			net += group.Charges[j].NetAmount
+		}
+		group.NetAmount = roundMoney(net)
+		group.VATAmount = roundMoney(group.NetAmount * vatRate / 100)
+		group.GrossAmount = roundMoney(group.NetAmount + group.VATAmount)
+
+		report.NetAmount += group.NetAmount
+		report.VATAmount += group.VATAmount
+		report.GrossAmount += group.GrossAmount
+	}
+
+	report.NetAmount = roundMoney(report.NetAmount)
+	report.VATAmount = roundMoney(report.VATAmount)
+	report.GrossAmount = roundMoney(report.GrossAmount)
+}
+
+func roundMoney(value float64) float64 {
+	return math.Round(value*100) / 100
+}
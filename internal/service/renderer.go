@@ -0,0 +1,161 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// MIME types of the built-in document formats.
+const (
+	MIMETypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	MIMETypePDF  = "application/pdf"
+	MIMETypeHTML = "text/html; charset=utf-8"
+)
+
+// Renderer renders an act report into a document.
+type Renderer interface {
+	Generate(report model.ActReport) ([]byte, error)
+}
+
+// StreamRenderer is a Renderer that can also write the document while the
+// trips are read. Acts in such formats are exported without holding every
+// trip in memory.
+type StreamRenderer interface {
+	Renderer
+	Write(w io.Writer, report model.ActReport, trips model.TripSource) error
+}
+
+// RendererFunc adapts a plain function to Renderer.
+type RendererFunc func(report model.ActReport) ([]byte, error)
+
+func (f RendererFunc) Generate(report model.ActReport) ([]byte, error) {
+	return f(report)
+}
+
+// RenderFormat describes a document format acts can be exported in.
+type RenderFormat struct {
+	// Name is the format key used in requests and stored in acts.format.
+	Name      string
+	MIMEType  string
+	Extension string
+	// FilePrefix starts the file name; "acts" when empty.
+	FilePrefix string
+	// Inline documents are shown by the browser instead of being downloaded.
+	Inline   bool
+	Renderer Renderer
+}
+
+// RendererRegistry holds the document formats by name. The first registered
+// format is the default.
+type RendererRegistry struct {
+	formats []RenderFormat
+	byName  map[string]int
+}
+
+func NewRendererRegistry() *RendererRegistry {
+	return &RendererRegistry{byName: make(map[string]int)}
+}
+
+// Register adds a format, replacing a format of the same name.
+func (r *RendererRegistry) Register(format RenderFormat) {
+	if format.FilePrefix == "" {
+		format.FilePrefix = "acts"
+	}
+	if i, ok := r.byName[format.Name]; ok {
+		r.formats[i] = format
+		return
+	}
+	r.byName[format.Name] = len(r.formats)
+	r.formats = append(r.formats, format)
+}
+
+// Lookup returns the format registered under name.
+func (r *RendererRegistry) Lookup(name string) (RenderFormat, error) {
+	i, ok := r.byName[name]
+	if !ok {
+		return RenderFormat{}, fmt.Errorf("%w: unsupported format %q", ErrInvalidInput, name)
+	}
+	return r.formats[i], nil
+}
+
+// Default returns the first registered format.
+func (r *RendererRegistry) Default() (RenderFormat, error) {
+	if len(r.formats) == 0 {
+		return RenderFormat{}, fmt.Errorf("no document formats registered")
+	}
+	return r.formats[0], nil
+}
+
+// Negotiate picks the format for an Accept header: the acceptable media type
+// with the highest quality that a format is registered for. An empty header
+// and "*/*" give the default format; a header that matches no format gives
+// ErrNotAcceptable.
+func (r *RendererRegistry) Negotiate(accept string) (RenderFormat, error) {
+	if len(r.formats) == 0 {
+		return r.Default()
+	}
+	mimeTypes := make([]string, len(r.formats))
+	for i, format := range r.formats {
+		mimeTypes[i] = format.MIMEType
+	}
+	i, err := negotiateMIMEType(accept, mimeTypes)
+	if err != nil {
+		return RenderFormat{}, err
+	}
+	return r.formats[i], nil
+}
+
+// negotiateMIMEType returns the index of the media type in mimeTypes that the
+// Accept header asks for with the highest quality. Parameters of mimeTypes,
+// such as charset, are ignored. An empty header and "*/*" accept the first
+// type, "type/*" the first type of that kind.
+func negotiateMIMEType(accept string, mimeTypes []string) (int, error) {
+	if strings.TrimSpace(accept) == "" {
+		return 0, nil
+	}
+	type mediaRange struct {
+		mimeType string
+		quality  float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mimeType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mimeType == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(key, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, mediaRange{mimeType: mimeType, quality: quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+
+	for _, media := range ranges {
+		if media.mimeType == "*/*" {
+			return 0, nil
+		}
+		kind, wildcard := strings.CutSuffix(media.mimeType, "/*")
+		for i, mimeType := range mimeTypes {
+			base, _, _ := strings.Cut(mimeType, ";")
+			base = strings.ToLower(strings.TrimSpace(base))
+			if media.mimeType == base || (wildcard && strings.HasPrefix(base, kind+"/")) {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: %q matches none of %s", ErrNotAcceptable, accept, strings.Join(mimeTypes, ", "))
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRendererRegistryNegotiate(t *testing.T) {
+	registry := NewRendererRegistry()
+	registry.Register(RenderFormat{Name: FormatXLSX, MIMEType: MIMETypeXLSX})
+	registry.Register(RenderFormat{Name: FormatPDF, MIMEType: MIMETypePDF})
+	registry.Register(RenderFormat{Name: FormatHTML, MIMEType: MIMETypeHTML})
+
+	tests := []struct {
+		accept string
+		want   string
+		err    error
+	}{
+		{accept: "", want: FormatXLSX},
+		{accept: "*/*", want: FormatXLSX},
+		{accept: "application/pdf", want: FormatPDF},
+		{accept: "Text/HTML", want: FormatHTML},
+		{accept: "text/html;q=0.5, application/pdf", want: FormatPDF},
+		{accept: "application/json, application/pdf;q=0.1", want: FormatPDF},
+		{accept: "text/*", want: FormatHTML},
+		{accept: "application/json, */*;q=0.1", want: FormatXLSX},
+		{accept: "application/json", err: ErrNotAcceptable},
+		{accept: "text/csv", err: ErrNotAcceptable},
+		{accept: "application/pdf;q=0", err: ErrNotAcceptable},
+	}
+	for _, tt := range tests {
+		format, err := registry.Negotiate(tt.accept)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Negotiate(%q) = %q, %v, want %v", tt.accept, format.Name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || format.Name != tt.want {
+			t.Errorf("Negotiate(%q) = %q, %v, want %q", tt.accept, format.Name, err, tt.want)
+		}
+	}
+}
+
+func TestNegotiateTripsFormat(t *testing.T) {
+	s := &ActService{}
+	tests := []struct {
+		name, accept string
+		want         string
+		err          error
+	}{
+		{accept: "", want: FormatTripsCSV},
+		{accept: "*/*", want: FormatTripsCSV},
+		{accept: "application/x-ndjson", want: FormatTripsJSONL},
+		{accept: "text/csv", want: FormatTripsCSV},
+		{name: FormatTripsJSONL, accept: "text/csv", want: FormatTripsJSONL},
+		{name: FormatXLSX, err: ErrInvalidInput},
+		{accept: MIMETypeXLSX, err: ErrNotAcceptable},
+	}
+	for _, tt := range tests {
+		format, err := s.NegotiateTripsFormat(tt.name, tt.accept)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("NegotiateTripsFormat(%q, %q) = %q, %v, want %v", tt.name, tt.accept, format, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || format != tt.want {
+			t.Errorf("NegotiateTripsFormat(%q, %q) = %q, %v, want %q", tt.name, tt.accept, format, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// reportBuilder assembles a report from trips fed one at a time in event time
+// order: every trip is assigned to its group, counted, priced and
+// fingerprinted. Unless keepTrips is set the trips themselves are dropped, so
+// a report over any period fits in memory.
+type reportBuilder struct {
+	mode        model.ReportMode
+	book        tariffBook
+	keepTrips   bool
+	groups      []model.TripGroup
+	index       map[uuid.UUID]int
+	lines       []map[uuid.UUID]int
+	fingerprint fingerprinter
+	// matrix is only collected for city-wide acts.
+	matrix *matrixBuilder
+}
+
+// newReportBuilder starts with the base groups, so groups without trips are
+// listed too. Groups of trips missing from base are appended as they appear.
+func newReportBuilder(mode model.ReportMode, base []model.TripGroup, book tariffBook, keepTrips bool) *reportBuilder {
+	b := &reportBuilder{
+		mode:      mode,
+		book:      book,
+		keepTrips: keepTrips,
+		groups:    make([]model.TripGroup, len(base)),
+		index:     make(map[uuid.UUID]int, len(base)),
+		lines:     make([]map[uuid.UUID]int, len(base)),
+	}
+	if mode == model.ReportModeCity {
+		b.matrix = newMatrixBuilder()
+	}
+	copy(b.groups, base)
+	for i, group := range base {
+		b.index[group.ID] = i
+		b.lines[i] = make(map[uuid.UUID]int)
+	}
+	return b
+}
+
+// add accounts for trip and returns the index of its group and its position
+// within the group.
+func (b *reportBuilder) add(trip model.TripDetail) (groupIndex, position int) {
+	id, name := tripGroupKey(b.mode, trip)
+	groupIndex, ok := b.index[id]
+	if !ok {
+		groupIndex = len(b.groups)
+		b.groups = append(b.groups, model.TripGroup{ID: id, Name: name})
+		b.index[id] = groupIndex
+		b.lines = append(b.lines, make(map[uuid.UUID]int))
+	}
+
+	group := &b.groups[groupIndex]
+	if group.Name == "" {
+		group.Name = name
+	}
+	position = int(group.TripCount)
+	group.TripCount++
+	if trip.SnowVolumeM3 != nil {
+		group.VolumeM3 += *trip.SnowVolumeM3
+	}
+	line, amount := b.book.charge(group, b.lines[groupIndex], trip)
+	if b.matrix != nil {
+		b.matrix.add(id, trip, line, amount)
+	}
+	if b.keepTrips {
+		group.Trips = append(group.Trips, trip)
+	}
+	b.fingerprint.add(trip)
+	return groupIndex, position
+}
+
+// report returns the collected groups with their totals and amounts.
+func (b *reportBuilder) report(vatRate float64) model.ActReport {
+	report := model.ActReport{Groups: b.groups}
+	for _, group := range b.groups {
+		report.TotalTrips += group.TripCount
+	}
+	report.TotalVolumeM3 = sumTripVolume(b.groups)
+	settleAmounts(&report, vatRate)
+	if b.matrix != nil {
+		report.Matrix = b.matrix.build(report.Groups)
+	}
+	return report
+}
+
+// matrixBuilder collects the contractor × landfill breakdown of a city-wide
+// act. The amounts of a cell are derived from the rounded amounts of its
+// group: every charge line is split over the landfills of the group in
+// proportion to what was charged there, and the group VAT in proportion to
+// the split net amounts, so the rows add up to the group totals and the
+// columns to the act totals to the kopeck.
+type matrixBuilder struct {
+	landfills map[uuid.UUID]*matrixEntry
+	cells     map[[2]uuid.UUID]*matrixEntry
+}
+
+type matrixEntry struct {
+	name     string
+	trips    int64
+	volumeM3 float64
+	// charged holds the unrounded amounts of a cell by charge line of its
+	// group.
+	charged map[int]float64
+}
+
+func newMatrixBuilder() *matrixBuilder {
+	return &matrixBuilder{
+		landfills: make(map[uuid.UUID]*matrixEntry),
+		cells:     make(map[[2]uuid.UUID]*matrixEntry),
+	}
+}
+
+// add accounts for trip of the contractor, charged amount unrounded on charge
+// line of the contractor's group; line is -1 for an unpriced trip.
+func (m *matrixBuilder) add(contractorID uuid.UUID, trip model.TripDetail, line int, amount float64) {
+	var landfillID uuid.UUID
+	if trip.PolygonID != nil {
+		landfillID = *trip.PolygonID
+	}
+	landfill, ok := m.landfills[landfillID]
+	if !ok {
+		landfill = &matrixEntry{}
+		m.landfills[landfillID] = landfill
+	}
+	if landfill.name == "" && trip.PolygonName != nil {
+		landfill.name = *trip.PolygonName
+	}
+	key := [2]uuid.UUID{contractorID, landfillID}
+	cell, ok := m.cells[key]
+	if !ok {
+		cell = &matrixEntry{charged: make(map[int]float64)}
+		m.cells[key] = cell
+	}
+
+	for _, e := range []*matrixEntry{landfill, cell} {
+		e.trips++
+		if trip.SnowVolumeM3 != nil {
+			e.volumeM3 += *trip.SnowVolumeM3
+		}
+	}
+	if line >= 0 {
+		cell.charged[line] += amount
+	}
+}
+
+// build returns the matrix with landfills sorted by name and cells in the
+// order of groups. groups must already have their amounts settled.
+func (m *matrixBuilder) build(groups []model.TripGroup) *model.CityMatrix {
+	matrix := &model.CityMatrix{}
+	for id, e := range m.landfills {
+		matrix.Landfills = append(matrix.Landfills, model.MatrixTotal{
+			ID:        id,
+			Name:      e.name,
+			TripCount: e.trips,
+			VolumeM3:  e.volumeM3,
+		})
+	}
+	sort.Slice(matrix.Landfills, func(i, j int) bool {
+		a, b := matrix.Landfills[i], matrix.Landfills[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID.String() < b.ID.String()
+	})
+
+	// Amounts are split in kopecks.
+	netKop := make([]int64, len(matrix.Landfills))
+	vatKop := make([]int64, len(matrix.Landfills))
+	for _, group := range groups {
+		var columns []int
+		var entries []*matrixEntry
+		for i, landfill := range matrix.Landfills {
+			if e, ok := m.cells[[2]uuid.UUID{group.ID, landfill.ID}]; ok {
+				columns = append(columns, i)
+				entries = append(entries, e)
+			}
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		net := make([]int64, len(entries))
+		weights := make([]float64, len(entries))
+		for line, charge := range group.Charges {
+			for k, e := range entries {
+				weights[k] = e.charged[line]
+			}
+			for k, share := range apportion(kopecks(charge.NetAmount), weights) {
+				net[k] += share
+			}
+		}
+		for k := range entries {
+			weights[k] = float64(net[k])
+		}
+		vat := apportion(kopecks(group.VATAmount), weights)
+
+		for k, e := range entries {
+			netKop[columns[k]] += net[k]
+			vatKop[columns[k]] += vat[k]
+			matrix.Cells = append(matrix.Cells, model.MatrixCell{
+				ContractorID: group.ID,
+				LandfillID:   matrix.Landfills[columns[k]].ID,
+				TripCount:    e.trips,
+				VolumeM3:     e.volumeM3,
+				NetAmount:    float64(net[k]) / 100,
+			})
+		}
+	}
+	for i := range matrix.Landfills {
+		total := &matrix.Landfills[i]
+		total.NetAmount = float64(netKop[i]) / 100
+		total.VATAmount = float64(vatKop[i]) / 100
+		total.GrossAmount = float64(netKop[i]+vatKop[i]) / 100
+	}
+	return matrix
+}
+
+// kopecks converts a rounded amount to kopecks.
+func kopecks(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// apportion splits total in proportion to weights by the largest remainder
+// method, so the shares add up to total exactly. Without any weight the whole
+// total goes to the first share.
+func apportion(total int64, weights []float64) []int64 {
+	shares := make([]int64, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+	if sum <= 0 {
+		shares[0] = total
+		return shares
+	}
+
+	order := make([]int, len(weights))
+	remainders := make([]float64, len(weights))
+	left := total
+	for i, w := range weights {
+		exact := float64(total) * w / sum
+		shares[i] = int64(math.Floor(exact))
+		remainders[i] = exact - float64(shares[i])
+		left -= shares[i]
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for k := 0; left != 0; k++ {
+		i := order[k%len(order)]
+		if left > 0 {
+			shares[i]++
+			left--
+		} else {
+			shares[i]--
+			left++
+		}
+	}
+	return shares
+}
+
+func tripGroupKey(mode model.ReportMode, trip model.TripDetail) (uuid.UUID, string) {
+	var id *uuid.UUID
+	var name *string
+	if mode == model.ReportModeLandfill || mode == model.ReportModeCity {
+		id, name = trip.ContractorID, trip.ContractorName
+	} else {
+		id, name = trip.PolygonID, trip.PolygonName
+	}
+	var groupID uuid.UUID
+	var groupName string
+	if id != nil {
+		groupID = *id
+	}
+	if name != nil {
+		groupName = *name
+	}
+	return groupID, groupName
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurpe/snowops-acts/internal/excel"
+	"github.com/nurpe/snowops-acts/internal/model"
+)
+
+// season is a synthetic snow season of a landfill act: tripsPerDay trips a
+// day from November to March, spread evenly over the contractors.
+type season struct {
+	start       time.Time
+	days        int
+	tripsPerDay int
+	contractors []model.TripGroup
+	plates      []string
+	volume      float64
+}
+
+func newSeason(contractors, tripsPerDay int) *season {
+	s := &season{
+		start:       time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC),
+		days:        151,
+		tripsPerDay: tripsPerDay,
+		volume:      12.5,
+	}
+	for i := 0; i < contractors; i++ {
+		s.contractors = append(s.contractors, model.TripGroup{ID: uuid.New(), Name: fmt.Sprintf("ТОО Подрядчик %d", i+1)})
+		s.plates = append(s.plates, fmt.Sprintf("%03dABC02", i+1))
+	}
+	return s
+}
+
+func (s *season) trips() int {
+	return s.days * s.tripsPerDay
+}
+
+// trip returns the k-th trip of the season in event time order.
+func (s *season) trip(k int) model.TripDetail {
+	contractor := &s.contractors[k%len(s.contractors)]
+	step := 24 * time.Hour / time.Duration(s.tripsPerDay)
+	return model.TripDetail{
+		EventID:        uuid.UUID{byte(k >> 24), byte(k >> 16), byte(k >> 8), byte(k)},
+		EventTime:      s.start.Add(time.Duration(k) * step),
+		Plate:          &s.plates[k%len(s.plates)],
+		ContractorID:   &contractor.ID,
+		ContractorName: &contractor.Name,
+		SnowVolumeM3:   &s.volume,
+	}
+}
+
+// each passes the trips to fn in event time order, as the trips query does.
+func (s *season) each(fn func(model.TripDetail)) {
+	for k := 0; k < s.trips(); k++ {
+		fn(s.trip(k))
+	}
+}
+
+// snapshot passes the trips group by group, as the act snapshot does.
+func (s *season) snapshot(fn func(groupIndex int, trip model.TripDetail) error) error {
+	for g := range s.contractors {
+		for k := g; k < s.trips(); k += len(s.contractors) {
+			if err := fn(g, s.trip(k)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tariff is the one tariff of the season, valid all along.
+func (s *season) tariff() model.Tariff {
+	return model.Tariff{
+		ID:        uuid.UUID{0xff},
+		Unit:      model.TariffUnitM3,
+		Price:     850,
+		ValidFrom: s.start.AddDate(-1, 0, 0),
+	}
+}
+
+func (s *season) report(keepTrips bool) model.ActReport {
+	book := tariffBook{tariffs: []model.Tariff{s.tariff()}, location: time.UTC}
+	builder := newReportBuilder(model.ReportModeLandfill, s.contractors, book, keepTrips)
+	s.each(func(trip model.TripDetail) {
+		builder.add(trip)
+	})
+	// The VAT rate is in percent.
+	report := builder.report(12)
+	report.Mode = model.ReportModeLandfill
+	report.Target = model.Organization{ID: uuid.New(), Name: "Полигон Северный"}
+	report.PeriodStart = s.start
+	report.PeriodEnd = s.start.AddDate(0, 0, s.days-1)
+	return report
+}
+
+// benchSeason runs fn b.N times and reports, besides the allocations, the
+// peak heap in use while it ran, sampled every few milliseconds.
+func benchSeason(b *testing.B, s *season, fn func() error) {
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	done := make(chan struct{})
+	peak := make(chan uint64)
+	go func() {
+		var max uint64
+		var stats runtime.MemStats
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > max {
+				max = stats.HeapInuse
+			}
+			select {
+			case <-done:
+				peak <- max
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := fn(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	close(done)
+	// The heap may end up below where it started, so the difference is
+	// signed.
+	b.ReportMetric(float64(int64(<-peak)-int64(before.HeapInuse))/(1<<20), "peak-heap-MB")
+	b.ReportMetric(float64(s.trips()), "trips/op")
+}
+
+// BenchmarkSeasonExport exports a landfill act over a season of 151,000 trips
+// to Excel. "streamed" is the path of exportStreamed: one pass over the trips
+// builds the report without keeping them, and the workbook is written from a
+// trip source, as it is from the act snapshot. "in-memory" keeps every trip
+// in the report and renders it with Generate.
+func BenchmarkSeasonExport(b *testing.B) {
+	s := newSeason(40, 1000)
+	generator := excel.NewGenerator()
+
+	b.Run("streamed", func(b *testing.B) {
+		benchSeason(b, s, func() error {
+			return generator.Write(io.Discard, s.report(false), s.snapshot)
+		})
+	})
+	b.Run("in-memory", func(b *testing.B) {
+		benchSeason(b, s, func() error {
+			_, err := generator.Generate(s.report(true))
+			return err
+		})
+	})
+}
+
+// BenchmarkSeasonReport measures the single pass that groups, counts and
+// prices a season of trips, which replaced a query per group. "builder" feeds
+// the trips straight to the report builder; "repository" reads them, and
+// everything else the report needs, through the ReportRepository from a
+// database served by seasonDB and also reports the queries it took.
+func BenchmarkSeasonReport(b *testing.B) {
+	s := newSeason(40, 1000)
+
+	b.Run("builder", func(b *testing.B) {
+		benchSeason(b, s, func() error {
+			s.report(false)
+			return nil
+		})
+	})
+	b.Run("repository", func(b *testing.B) {
+		db := &seasonDB{season: s}
+		repo := db.repository(b)
+		benchSeason(b, s, func() error {
+			_, err := seasonReport(context.Background(), s, repo)
+			return err
+		})
+		b.ReportMetric(float64(db.queries.Load())/float64(b.N), "queries/op")
+	})
+}
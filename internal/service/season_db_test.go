@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/repository"
+)
+
+// seasonDB serves a season through database/sql as the report queries of a
+// landfill act read it: the contractors, one tariff, the trips and the
+// organizations of the contractors. It counts the queries it answers, so a
+// test can tell how many round-trips a report takes.
+type seasonDB struct {
+	season  *season
+	queries atomic.Int64
+}
+
+func (d *seasonDB) Connect(context.Context) (driver.Conn, error) { return seasonConn{d}, nil }
+func (d *seasonDB) Driver() driver.Driver                        { return d }
+func (d *seasonDB) Open(string) (driver.Conn, error)             { return seasonConn{d}, nil }
+
+// repository returns a report repository reading from d.
+func (d *seasonDB) repository(tb testing.TB) *repository.ReportRepository {
+	tb.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(d)}), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return repository.NewReportRepository(db, []string{"CONFIRMED"})
+}
+
+type seasonConn struct{ db *seasonDB }
+
+func (c seasonConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("season db: prepared statements are not supported")
+}
+func (c seasonConn) Close() error              { return nil }
+func (c seasonConn) Begin() (driver.Tx, error) { return nil, fmt.Errorf("season db: read only") }
+
+func (c seasonConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.db.queries.Add(1)
+	s := c.db.season
+	switch {
+	case strings.Contains(query, "FROM anpr_events"):
+		columns := []string{"event_id", "event_time", "plate", "polygon_id", "polygon_name", "contractor_id", "contractor_name", "snow_volume_m3"}
+		return &seasonRows{columns: columns, count: s.trips(), row: func(k int, dest []driver.Value) {
+			trip := s.trip(k)
+			dest[0], dest[1], dest[2] = trip.EventID.String(), trip.EventTime, *trip.Plate
+			dest[3], dest[4] = nil, nil
+			dest[5], dest[6], dest[7] = trip.ContractorID.String(), *trip.ContractorName, *trip.SnowVolumeM3
+		}}, nil
+	case strings.Contains(query, "FROM tariffs"):
+		columns := []string{"id", "landfill_id", "contractor_id", "unit", "price", "valid_from", "valid_to"}
+		return &seasonRows{columns: columns, count: 1, row: func(_ int, dest []driver.Value) {
+			tariff := s.tariff()
+			dest[0], dest[1], dest[2] = tariff.ID.String(), nil, nil
+			dest[3], dest[4], dest[5], dest[6] = string(tariff.Unit), tariff.Price, tariff.ValidFrom, nil
+		}}, nil
+	case strings.Contains(query, "type = 'CONTRACTOR'"):
+		return &seasonRows{columns: []string{"id", "name", "trip_count"}, count: len(s.contractors), row: func(k int, dest []driver.Value) {
+			dest[0], dest[1], dest[2] = s.contractors[k].ID.String(), s.contractors[k].Name, int64(0)
+		}}, nil
+	case strings.Contains(query, "FROM organizations"):
+		columns := []string{"id", "name", "type", "bin", "head_full_name", "address", "phone"}
+		return &seasonRows{columns: columns, count: len(s.contractors), row: func(k int, dest []driver.Value) {
+			dest[0], dest[1], dest[2] = s.contractors[k].ID.String(), s.contractors[k].Name, "CONTRACTOR"
+			dest[3], dest[4], dest[5], dest[6] = fmt.Sprintf("%012d", k+1), "", "", ""
+		}}, nil
+	}
+	return nil, fmt.Errorf("season db: unexpected query %q", query)
+}
+
+// seasonRows returns count rows filled in by row.
+type seasonRows struct {
+	columns []string
+	count   int
+	next    int
+	row     func(k int, dest []driver.Value)
+}
+
+func (r *seasonRows) Columns() []string { return r.columns }
+func (r *seasonRows) Close() error      { return nil }
+
+func (r *seasonRows) Next(dest []driver.Value) error {
+	if r.next == r.count {
+		return io.EOF
+	}
+	r.row(r.next, dest)
+	r.next++
+	return nil
+}
+
+// seasonReport builds the landfill act of s through repo, as an export does
+// before it is registered.
+func seasonReport(ctx context.Context, s *season, repo *repository.ReportRepository) (*model.ActReport, error) {
+	service := &ActService{vatRate: 12, location: time.UTC}
+	scope := &reportScope{
+		mode:        model.ReportModeLandfill,
+		target:      model.Organization{ID: uuid.New(), Name: "Полигон Северный"},
+		periodStart: s.start,
+		periodEnd:   s.start.AddDate(0, 0, s.days-1),
+		location:    time.UTC,
+	}
+	report, _, err := service.streamReport(ctx, repo, scope, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.prepareIssue(ctx, repo, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// TestSeasonReportQueries checks that a report takes the same few queries
+// however many groups it has: contractors, tariffs, trips and the
+// counterparties of the groups with trips.
+func TestSeasonReportQueries(t *testing.T) {
+	for _, contractors := range []int{1, 40} {
+		t.Run(fmt.Sprintf("%d contractors", contractors), func(t *testing.T) {
+			s := newSeason(contractors, 24)
+			db := &seasonDB{season: s}
+			report, err := seasonReport(context.Background(), s, db.repository(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := db.queries.Load(); got != 4 {
+				t.Errorf("queries = %d, want 4", got)
+			}
+			if report.TotalTrips != int64(s.trips()) {
+				t.Errorf("trips = %d, want %d", report.TotalTrips, s.trips())
+			}
+			for _, group := range report.Groups {
+				if group.Organization == nil || group.Organization.BIN == "" {
+					t.Errorf("group %s has no counterparty", group.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/repository"
+)
+
+// maxTariffPrice is the first price that does not fit NUMERIC(14, 2).
+const maxTariffPrice = 1e12
+
+// TariffService manages the tariffs acts are priced with. Only Akimat admins
+// may read or change them.
+type TariffService struct {
+	tariffs *repository.TariffRepository
+	reports *repository.ReportRepository
+}
+
+type TariffInput struct {
+	LandfillID   *uuid.UUID
+	ContractorID *uuid.UUID
+	Unit         model.TariffUnit
+	Price        float64
+	ValidFrom    time.Time
+	ValidTo      *time.Time
+	Principal    model.Principal
+}
+
+func NewTariffService(
+	tariffs *repository.TariffRepository,
+	reports *repository.ReportRepository,
+) *TariffService {
+	return &TariffService{tariffs: tariffs, reports: reports}
+}
+
+func (s *TariffService) List(ctx context.Context, principal model.Principal) ([]model.Tariff, error) {
+	if !principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	return s.tariffs.List(ctx)
+}
+
+func (s *TariffService) Create(ctx context.Context, input TariffInput) (*model.Tariff, error) {
+	if !input.Principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+
+	now := time.Now()
+	tariff := &model.Tariff{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.apply(ctx, tariff, input); err != nil {
+		return nil, err
+	}
+	if err := s.tariffs.Create(ctx, tariff); err != nil {
+		return nil, tariffStoreError(err)
+	}
+	return s.tariffs.Get(ctx, tariff.ID)
+}
+
+func (s *TariffService) Update(ctx context.Context, id uuid.UUID, input TariffInput) (*model.Tariff, error) {
+	if !input.Principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+
+	tariff, err := s.tariffs.Get(ctx, id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	tariff.UpdatedAt = time.Now()
+	if err := s.apply(ctx, tariff, input); err != nil {
+		return nil, err
+	}
+	if err := s.tariffs.Update(ctx, tariff); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, tariffStoreError(err)
+	}
+	return s.tariffs.Get(ctx, tariff.ID)
+}
+
+func (s *TariffService) Delete(ctx context.Context, id uuid.UUID, principal model.Principal) error {
+	if !principal.IsAkimatAdmin() {
+		return ErrPermissionDenied
+	}
+	if err := s.tariffs.Delete(ctx, id); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// tariffStoreError maps a tariff that overlaps one stored concurrently to
+// ErrConflict.
+func tariffStoreError(err error) error {
+	if repository.IsConflict(err) {
+		return fmt.Errorf("%w: a tariff for this landfill and contractor is already valid in this period", ErrConflict)
+	}
+	return err
+}
+
+// apply validates input and copies it into tariff. Both ends of the period
+// are dates and are included in it.
+func (s *TariffService) apply(ctx context.Context, tariff *model.Tariff, input TariffInput) error {
+	unit := model.TariffUnit(strings.ToUpper(strings.TrimSpace(string(input.Unit))))
+	if unit != model.TariffUnitM3 && unit != model.TariffUnitTrip {
+		return fmt.Errorf("%w: unit must be M3 or TRIP", ErrInvalidInput)
+	}
+	if math.IsNaN(input.Price) || input.Price < 0 || input.Price >= maxTariffPrice {
+		return fmt.Errorf("%w: price must be between 0 and %.0f", ErrInvalidInput, maxTariffPrice)
+	}
+	if input.ValidFrom.IsZero() {
+		return fmt.Errorf("%w: valid_from is required", ErrInvalidInput)
+	}
+	if input.ValidTo != nil && input.ValidTo.Before(input.ValidFrom) {
+		return fmt.Errorf("%w: valid_to must not be before valid_from", ErrInvalidInput)
+	}
+	if err := s.checkOrganization(ctx, input.LandfillID, "landfill_id", "LANDFILL"); err != nil {
+		return err
+	}
+	if err := s.checkOrganization(ctx, input.ContractorID, "contractor_id", "CONTRACTOR"); err != nil {
+		return err
+	}
+
+	// The check gives a clear error early; the exclusion constraint of the
+	// table rejects overlaps stored concurrently.
+	overlap, err := s.tariffs.HasOverlap(ctx, input.LandfillID, input.ContractorID, input.ValidFrom, input.ValidTo, tariff.ID)
+	if err != nil {
+		return err
+	}
+	if overlap {
+		return fmt.Errorf("%w: a tariff for this landfill and contractor is already valid in this period", ErrConflict)
+	}
+
+	tariff.LandfillID = input.LandfillID
+	tariff.ContractorID = input.ContractorID
+	tariff.Unit = unit
+	tariff.Price = input.Price
+	tariff.ValidFrom = input.ValidFrom
+	tariff.ValidTo = input.ValidTo
+	return nil
+}
+
+// checkOrganization checks that id, when set, is an organization of orgType.
+func (s *TariffService) checkOrganization(ctx context.Context, id *uuid.UUID, field, orgType string) error {
+	if id == nil {
+		return nil
+	}
+	org, err := s.reports.GetOrganization(ctx, *id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("%w: %s not found", ErrInvalidInput, field)
+		}
+		return err
+	}
+	if !strings.EqualFold(org.Type, orgType) {
+		return fmt.Errorf("%w: %s must be %s organization", ErrInvalidInput, field, orgType)
+	}
+	return nil
+}
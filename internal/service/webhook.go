@@ -0,0 +1,422 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/nurpe/snowops-acts/internal/config"
+	"github.com/nurpe/snowops-acts/internal/model"
+	"github.com/nurpe/snowops-acts/internal/repository"
+	"github.com/nurpe/snowops-acts/internal/textutil"
+)
+
+const (
+	// webhookLease is how long a claimed event is kept from other workers.
+	webhookLease = 2 * time.Minute
+	// webhookFirstRetry is the pause after the first failed attempt; it
+	// doubles with every further attempt up to webhookMaxRetry.
+	webhookFirstRetry = 30 * time.Second
+	webhookMaxRetry   = 6 * time.Hour
+	// webhookErrorLength limits the error stored with an event, in
+	// characters.
+	webhookErrorLength = 500
+	// webhookSecretMinLength is the shortest secret a subscription accepts.
+	webhookSecretMinLength = 16
+	// webhookDeliveryLimit is how many events of a subscription are listed.
+	webhookDeliveryLimit = 100
+)
+
+// Headers of webhook requests.
+const (
+	WebhookEventHeader     = "X-Snowops-Event"
+	WebhookDeliveryHeader  = "X-Snowops-Delivery"
+	WebhookSignatureHeader = "X-Snowops-Signature"
+)
+
+// WebhookService manages webhook subscriptions and posts the events queued in
+// their outbox. Events are queued by the act registry in the transaction that
+// issues an act; workers post them signed with HMAC-SHA256 and retry failures
+// with exponential backoff. Subscriptions are managed by Akimat admins.
+type WebhookService struct {
+	webhooks     *repository.WebhookRepository
+	acts         *repository.ActRepository
+	client       *http.Client
+	publicURL    string
+	maxAttempts  int
+	pollInterval time.Duration
+	log          zerolog.Logger
+}
+
+type WebhookInput struct {
+	URL string
+	// Secret signs the events; a random one is generated when a
+	// subscription is created without it, and an update without it keeps
+	// the current one.
+	Secret string
+	// Events defaults to every event.
+	Events []string
+	// Active defaults to true for a new subscription and is kept on update.
+	Active    *bool
+	Principal model.Principal
+}
+
+func NewWebhookService(
+	webhooks *repository.WebhookRepository,
+	acts *ActService,
+	cfg *config.Config,
+	log zerolog.Logger,
+) *WebhookService {
+	return &WebhookService{
+		webhooks:     webhooks,
+		acts:         acts.acts,
+		client:       &http.Client{Timeout: cfg.Webhooks.Timeout},
+		publicURL:    cfg.Webhooks.PublicURL,
+		maxAttempts:  cfg.Webhooks.MaxAttempts,
+		pollInterval: cfg.Webhooks.PollInterval,
+		log:          log,
+	}
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context, principal model.Principal) ([]model.WebhookSubscription, error) {
+	if !principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	subscriptions, err := s.webhooks.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return subscriptions, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id uuid.UUID, principal model.Principal) (*model.WebhookSubscription, error) {
+	if !principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	subscription, err := s.getSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = ""
+	return subscription, nil
+}
+
+// CreateWebhook adds a subscription. The result carries the secret; it is
+// not shown again.
+func (s *WebhookService) CreateWebhook(ctx context.Context, input WebhookInput) (*model.WebhookSubscription, error) {
+	if !input.Principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	now := time.Now()
+	subscription := &model.WebhookSubscription{
+		ID:        uuid.New(),
+		Active:    true,
+		CreatedBy: input.Principal.UserID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := applyWebhookInput(subscription, input); err != nil {
+		return nil, err
+	}
+	if subscription.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		subscription.Secret = secret
+	}
+	if err := s.webhooks.CreateSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// UpdateWebhook replaces the URL and events of a subscription and, when
+// given, its secret and state. The result carries the secret only when it
+// was changed.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id uuid.UUID, input WebhookInput) (*model.WebhookSubscription, error) {
+	if !input.Principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	subscription, err := s.getSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyWebhookInput(subscription, input); err != nil {
+		return nil, err
+	}
+	subscription.UpdatedAt = time.Now()
+	if err := s.webhooks.UpdateSubscription(ctx, subscription); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if input.Secret == "" {
+		subscription.Secret = ""
+	}
+	return subscription, nil
+}
+
+// DeleteWebhook removes a subscription together with its queued events.
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uuid.UUID, principal model.Principal) error {
+	if !principal.IsAkimatAdmin() {
+		return ErrPermissionDenied
+	}
+	if err := s.webhooks.DeleteSubscription(ctx, id); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the latest events of a subscription with
+// their status.
+func (s *WebhookService) ListWebhookDeliveries(ctx context.Context, id uuid.UUID, principal model.Principal) ([]model.WebhookDelivery, error) {
+	if !principal.IsAkimatAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	if _, err := s.getSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.webhooks.ListDeliveries(ctx, id, webhookDeliveryLimit)
+}
+
+func (s *WebhookService) getSubscription(ctx context.Context, id uuid.UUID) (*model.WebhookSubscription, error) {
+	subscription, err := s.webhooks.GetSubscription(ctx, id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// applyWebhookInput validates input and copies it to subscription.
+func applyWebhookInput(subscription *model.WebhookSubscription, input WebhookInput) error {
+	endpoint := strings.TrimSpace(input.URL)
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidInput)
+	}
+
+	events := make([]string, 0, len(input.Events))
+	for _, event := range input.Events {
+		event = strings.ToLower(strings.TrimSpace(event))
+		if !slices.Contains(model.WebhookEvents, event) {
+			return fmt.Errorf("%w: unsupported event %q", ErrInvalidInput, event)
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		events = append(events, model.WebhookEvents...)
+	}
+
+	if input.Secret != "" {
+		if len(input.Secret) < webhookSecretMinLength {
+			return fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidInput, webhookSecretMinLength)
+		}
+		subscription.Secret = input.Secret
+	}
+
+	subscription.URL = endpoint
+	subscription.Events = events
+	if input.Active != nil {
+		subscription.Active = *input.Active
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// Run posts queued events until ctx is done.
+func (s *WebhookService) Run(ctx context.Context) {
+	for {
+		now := time.Now()
+		delivery, err := s.webhooks.Claim(ctx, now, now.Add(webhookLease))
+		if err != nil && ctx.Err() == nil {
+			s.log.Error().Err(err).Msg("claim webhook event failed")
+		}
+		if delivery != nil {
+			s.deliver(ctx, delivery)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+func (s *WebhookService) deliver(ctx context.Context, delivery *model.WebhookDelivery) {
+	log := s.log.With().
+		Str("webhook_delivery_id", delivery.ID.String()).
+		Str("subscription_id", delivery.SubscriptionID.String()).
+		Str("act_id", delivery.ActID.String()).
+		Logger()
+
+	status, err := s.send(ctx, delivery)
+	if err == nil {
+		if err := s.webhooks.MarkSent(ctx, delivery.ID, status); err != nil {
+			log.Error().Err(err).Msg("mark webhook event sent failed")
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	message := textutil.Truncate(err.Error(), webhookErrorLength)
+	if delivery.Attempts >= s.maxAttempts {
+		log.Warn().Err(err).Int("attempts", delivery.Attempts).Msg("webhook event failed")
+		err = s.webhooks.Fail(ctx, delivery.ID, status, message)
+	} else {
+		log.Info().Err(err).Int("attempts", delivery.Attempts).Msg("webhook event will be retried")
+		next := time.Now().Add(retryBackoff(delivery.Attempts, webhookFirstRetry, webhookMaxRetry))
+		err = s.webhooks.Retry(ctx, delivery.ID, status, message, next)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("update webhook event failed")
+	}
+}
+
+// send posts the event and returns the response status. Any 2xx status
+// accepts the event.
+func (s *WebhookService) send(ctx context.Context, delivery *model.WebhookDelivery) (int, error) {
+	subscription, err := s.webhooks.GetSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return 0, err
+	}
+	act, err := s.acts.Get(ctx, delivery.ActID)
+	if err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(s.actEvent(delivery, act))
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "snowops-acts")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID.String())
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(subscription.Secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s: %s", resp.Status, strings.TrimSpace(string(snippet)))
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of body with secret, the value of
+// the signature header after "sha256=".
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookEvent is the body of a webhook request. ID stays the same across
+// retries, so receivers can drop repeated events.
+type webhookEvent struct {
+	ID         uuid.UUID       `json:"id"`
+	Event      string          `json:"event"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       webhookActEvent `json:"data"`
+}
+
+type webhookOrganization struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+type webhookActEvent struct {
+	ActID         uuid.UUID            `json:"act_id"`
+	Number        string               `json:"number"`
+	Kind          model.ActKind        `json:"kind"`
+	Mode          model.ReportMode     `json:"mode"`
+	Target        webhookOrganization  `json:"target"`
+	Landfill      *webhookOrganization `json:"landfill,omitempty"`
+	PeriodStart   string               `json:"period_start"`
+	PeriodEnd     string               `json:"period_end"`
+	TotalTrips    int64                `json:"total_trips"`
+	TotalVolumeM3 float64              `json:"total_volume_m3"`
+	NetAmount     float64              `json:"net_amount"`
+	VATAmount     float64              `json:"vat_amount"`
+	GrossAmount   float64              `json:"gross_amount"`
+	// DownloadURL is the document of the act as it was issued, without the
+	// reprint marker, in the format it was issued in; it needs the same
+	// authorization as other requests.
+	DownloadURL string `json:"download_url"`
+}
+
+func (s *WebhookService) actEvent(delivery *model.WebhookDelivery, act *model.Act) webhookEvent {
+	data := webhookActEvent{
+		ActID:         act.ID,
+		Number:        act.Number,
+		Kind:          act.Kind,
+		Mode:          act.Mode,
+		Target:        webhookOrganization{ID: act.TargetID, Name: act.TargetName},
+		PeriodStart:   act.PeriodStart.Format("2006-01-02"),
+		PeriodEnd:     act.PeriodEnd.Format("2006-01-02"),
+		TotalTrips:    act.TotalTrips,
+		TotalVolumeM3: act.TotalVolumeM3,
+		NetAmount:     act.NetAmount,
+		VATAmount:     act.VATAmount,
+		GrossAmount:   act.GrossAmount,
+		DownloadURL:   s.publicURL + "/acts/" + act.ID.String() + "/document",
+	}
+	if act.LandfillID != nil {
+		data.Landfill = &webhookOrganization{ID: *act.LandfillID, Name: act.LandfillName}
+	}
+	if act.Format != "" {
+		data.DownloadURL += "?" + url.Values{"format": {act.Format}}.Encode()
+	}
+	return webhookEvent{
+		ID:         delivery.ID,
+		Event:      delivery.Event,
+		OccurredAt: delivery.CreatedAt,
+		Data:       data,
+	}
+}
@@ -0,0 +1,76 @@
+// Package storage keeps generated documents on disk or in an S3-compatible
+// object store.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Disk stores files under a root directory. Keys are slash-separated paths
+// relative to the root.
+type Disk struct {
+	root string
+}
+
+func NewDisk(root string) *Disk {
+	return &Disk{root: root}
+}
+
+// Put writes content under key, creating the directories it needs.
+func (d *Disk) Put(ctx context.Context, key string, content []byte, contentType string) error {
+	return d.PutReader(ctx, key, bytes.NewReader(content), contentType)
+}
+
+// PutReader copies content under key, creating the directories it needs. The
+// file is written to a temporary name first, so a half-written file never
+// appears under key.
+func (d *Disk) PutReader(_ context.Context, key string, content io.ReadSeeker, _ string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Open opens the file under key. A missing file gives an error matching
+// fs.ErrNotExist.
+func (d *Disk) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (d *Disk) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(d.root, clean), nil
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDiskPutReaderOpen(t *testing.T) {
+	ctx := context.Background()
+	disk := NewDisk(t.TempDir())
+	if err := disk.PutReader(ctx, "jobs/1/act.xlsx", strings.NewReader("workbook"), ""); err != nil {
+		t.Fatal(err)
+	}
+	file, err := disk.Open(ctx, "jobs/1/act.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if content, _ := io.ReadAll(file); string(content) != "workbook" {
+		t.Errorf("content = %q, want workbook", content)
+	}
+	if _, err := disk.Open(ctx, "jobs/2/act.xlsx"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("open missing file: %v, want fs.ErrNotExist", err)
+	}
+	if _, err := disk.Open(ctx, "../act.xlsx"); err == nil {
+		t.Error("open outside the root succeeded")
+	}
+}
+
+func TestS3PutReaderOpen(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		objects = map[string]string{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(body)
+			if r.ContentLength != int64(len(body)) || len(r.TransferEncoding) > 0 {
+				http.Error(w, "length required", http.StatusLengthRequired)
+				return
+			}
+			if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+				http.Error(w, "content hash mismatch", http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = string(body)
+		case http.MethodGet:
+			content, ok := objects[r.URL.Path]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			io.WriteString(w, content)
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewS3(server.URL, "acts", "", "key", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for key, content := range map[string]string{"jobs/1/act.xlsx": "workbook", "jobs/1/empty.csv": ""} {
+		if err := store.PutReader(ctx, key, strings.NewReader(content), "text/plain"); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+		object, err := store.Open(ctx, key)
+		if err != nil {
+			t.Fatalf("open %s: %v", key, err)
+		}
+		got, _ := io.ReadAll(object)
+		object.Close()
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", key, got, content)
+		}
+	}
+	if _, err := store.Open(ctx, "jobs/2/act.xlsx"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("open missing object: %v, want fs.ErrNotExist", err)
+	}
+}
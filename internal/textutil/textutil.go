@@ -0,0 +1,16 @@
+// Package textutil holds string helpers shared by the document generators
+// and the services.
+package textutil
+
+// Truncate cuts s to at most n characters without splitting a multibyte one,
+// so Cyrillic sheet names and error texts stay valid UTF-8.
+func Truncate(s string, n int) string {
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
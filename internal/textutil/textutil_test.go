@@ -0,0 +1,23 @@
+package textutil
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"", 3, ""},
+		{"abc", 3, "abc"},
+		{"abcdef", 3, "abc"},
+		{"Почтовый ящик", 8, "Почтовый"},
+		{"Акт", 0, ""},
+		{"Акт", 10, "Акт"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
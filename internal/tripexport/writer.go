@@ -0,0 +1,103 @@
+// Package tripexport writes act trips as flat CSV or JSON Lines rows, one row
+// per trip, for loading into external tools.
+package tripexport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Row is one trip of an act.
+type Row struct {
+	EventID      uuid.UUID `json:"event_id"`
+	EventTime    time.Time `json:"event_time"`
+	GroupName    string    `json:"group_name"`
+	Plate        string    `json:"plate"`
+	Landfill     string    `json:"landfill"`
+	Contractor   string    `json:"contractor"`
+	SnowVolumeM3 *float64  `json:"snow_volume_m3"`
+}
+
+// Writer writes rows one at a time. Flush must be called after the last row.
+type Writer interface {
+	Write(row Row) error
+	Flush() error
+}
+
+var csvHeader = []string{"event_id", "event_time", "group_name", "plate", "landfill", "contractor", "snow_volume_m3"}
+
+// utf8BOM lets Excel detect the encoding of the CSV file.
+const utf8BOM = "\ufeff"
+
+type csvWriter struct {
+	w            *csv.Writer
+	decimalComma bool
+}
+
+// NewCSV writes a UTF-8 CSV file with a BOM and a header row. With a
+// semicolon delimiter volumes use a decimal comma, as Excel expects in
+// locales where the semicolon is the list separator.
+func NewCSV(w io.Writer, delimiter rune) (Writer, error) {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = delimiter
+	cw.UseCRLF = true
+	if err := cw.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: cw, decimalComma: delimiter == ';'}, nil
+}
+
+func (c *csvWriter) Write(row Row) error {
+	volume := ""
+	if row.SnowVolumeM3 != nil {
+		volume = strconv.FormatFloat(*row.SnowVolumeM3, 'f', -1, 64)
+		if c.decimalComma {
+			volume = strings.Replace(volume, ".", ",", 1)
+		}
+	}
+	return c.w.Write([]string{
+		row.EventID.String(),
+		row.EventTime.Format("2006-01-02 15:04:05"),
+		row.GroupName,
+		row.Plate,
+		row.Landfill,
+		row.Contractor,
+		volume,
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewJSONL writes one JSON object per line.
+func NewJSONL(w io.Writer) Writer {
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	return &jsonlWriter{buf: buf, enc: enc}
+}
+
+func (j *jsonlWriter) Write(row Row) error {
+	return j.enc.Encode(row)
+}
+
+func (j *jsonlWriter) Flush() error {
+	return j.buf.Flush()
+}